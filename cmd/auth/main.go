@@ -22,6 +22,8 @@ type configuration struct {
 	WriteTimeout    time.Duration `envconfig:"WRITE_TIMEOUT" default:"5s"`
 	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"5s"`
 	AllowedOrigins  []string      `envconfig:"ALLOWED_ORIGINS" required:"true" default:"*"`
+	CartServiceURL  string        `envconfig:"CART_SERVICE_URL" default:"http://localhost:8081"`
+	CartTimeout     time.Duration `envconfig:"CART_TIMEOUT" default:"3s"`
 }
 
 func main() {
@@ -42,6 +44,8 @@ func main() {
 		ShutdownTimeout: cfg.ShutdownTimeout,
 		MetricPrefix:    metricPrefix,
 		AllowedOrigins:  cfg.AllowedOrigins,
+		CartServiceURL:  cfg.CartServiceURL,
+		CartTimeout:     cfg.CartTimeout,
 	})
 	if err != nil {
 		level.Error(logger).Log("msg", "failed to create api server", "err", err)
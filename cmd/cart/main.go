@@ -7,6 +7,8 @@ import (
 	"time"
 
 	cart "github.com/anabiozz/core/lapkins/pkg/cartsvc"
+	"github.com/anabiozz/core/lapkins/pkg/payment"
+	"github.com/anabiozz/core/lapkins/pkg/shipping"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/kelseyhightower/envconfig"
@@ -22,6 +24,16 @@ type configuration struct {
 	WriteTimeout    time.Duration `envconfig:"WRITE_TIMEOUT" default:"5s"`
 	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"5s"`
 	AllowedOrigins  []string      `envconfig:"ALLOWED_ORIGINS" required:"true" default:"*"`
+	PaymentProvider string        `envconfig:"PAYMENT_PROVIDER" required:"true"`
+	PaymentSecret   string        `envconfig:"PAYMENT_WEBHOOK_SECRET" required:"true"`
+	PaymentFakeMode string        `envconfig:"PAYMENT_FAKE_MODE" default:"succeed"`
+	DevMode         bool          `envconfig:"DEV_MODE" default:"false"`
+	ShippingRates   string        `envconfig:"SHIPPING_RATES"`
+	ReservationTTL  time.Duration `envconfig:"RESERVATION_TTL" default:"15m"`
+	SweepInterval   time.Duration `envconfig:"RESERVATION_SWEEP_INTERVAL" default:"1m"`
+	CartExpiryEvery time.Duration `envconfig:"CART_EXPIRY_INTERVAL" default:"10m"`
+	CartExpireAfter time.Duration `envconfig:"CART_EXPIRE_AFTER" default:"72h"`
+	CartRetention   time.Duration `envconfig:"CART_RETENTION" default:"720h"`
 }
 
 func main() {
@@ -42,6 +54,22 @@ func main() {
 		ShutdownTimeout: cfg.ShutdownTimeout,
 		MetricPrefix:    metricPrefix,
 		AllowedOrigins:  cfg.AllowedOrigins,
+		Payment: payment.Config{
+			Provider:      cfg.PaymentProvider,
+			WebhookSecret: cfg.PaymentSecret,
+			FakeMode:      cfg.PaymentFakeMode,
+			DevMode:       cfg.DevMode,
+		},
+		Shipping: shipping.Config{
+			RatesFile: cfg.ShippingRates,
+		},
+		ReservationTTL: cfg.ReservationTTL,
+		SweepInterval:  cfg.SweepInterval,
+		CartExpiry: cart.CartExpiryConfig{
+			Interval:    cfg.CartExpiryEvery,
+			ExpireAfter: cfg.CartExpireAfter,
+			Retention:   cfg.CartRetention,
+		},
 	})
 	if err != nil {
 		level.Error(logger).Log("msg", "failed to create api server", "err", err)
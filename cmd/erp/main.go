@@ -7,6 +7,7 @@ import (
 	"time"
 
 	erp "github.com/anabiozz/core/lapkins/pkg/erpsvc"
+	"github.com/anabiozz/core/lapkins/pkg/payment"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/kelseyhightower/envconfig"
@@ -22,6 +23,11 @@ type configuration struct {
 	WriteTimeout    time.Duration `envconfig:"WRITE_TIMEOUT" default:"5s"`
 	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"5s"`
 	AllowedOrigins  []string      `envconfig:"ALLOWED_ORIGINS" required:"true" default:"*"`
+	PaymentProvider string        `envconfig:"PAYMENT_PROVIDER" required:"true"`
+	PaymentSecret   string        `envconfig:"PAYMENT_WEBHOOK_SECRET" required:"true"`
+	PaymentFakeMode string        `envconfig:"PAYMENT_FAKE_MODE" default:"succeed"`
+	DevMode         bool          `envconfig:"DEV_MODE" default:"false"`
+	SearchRefresh   time.Duration `envconfig:"SEARCH_REFRESH_INTERVAL" default:"5m"`
 }
 
 func main() {
@@ -42,6 +48,13 @@ func main() {
 		ShutdownTimeout: cfg.ShutdownTimeout,
 		MetricPrefix:    metricPrefix,
 		AllowedOrigins:  cfg.AllowedOrigins,
+		Payment: payment.Config{
+			Provider:      cfg.PaymentProvider,
+			WebhookSecret: cfg.PaymentSecret,
+			FakeMode:      cfg.PaymentFakeMode,
+			DevMode:       cfg.DevMode,
+		},
+		SearchRefreshInterval: cfg.SearchRefresh,
 	})
 	if err != nil {
 		level.Error(logger).Log("msg", "failed to create api server", "err", err)
@@ -67,4 +80,4 @@ func main() {
 	}
 
 	level.Info(logger).Log("msg", "goodbye")
-}
\ No newline at end of file
+}
@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -10,11 +14,19 @@ import (
 
 var JwtKey = []byte("secret")
 
+// ErrNotStaff is returned for valid tokens of customers on staff only endpoints.
+var ErrNotStaff = errors.New("staff only")
+
+// ErrInvalidTmpUserID is returned for tmp-user-id cookies that were not issued by us.
+var ErrInvalidTmpUserID = errors.New("invalid tmp user id")
+
 // Claims Create a struct that will be encoded to a JWT.
 // We add jwt.StandardClaims as an embedded type, to provide fields like expiry time
 type Claims struct {
 	Subject string `json:"subject"`
 	UserID  string `json:"user_id"`
+	// Staff is set for the users allowed to the admin endpoints.
+	Staff bool `json:"staff,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -33,13 +45,26 @@ func Check(token string) (*Claims, error) {
 	return tkn.Claims.(*Claims), nil
 }
 
+// CheckStaff checks the token like Check and lets only staff tokens through.
+func CheckStaff(token string) (*Claims, error) {
+	claims, err := Check(token)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.Staff {
+		return nil, ErrNotStaff
+	}
+	return claims, nil
+}
+
 func GetUserID(r *http.Request) (string, bool, error) {
 	token, err := GetToken(r)
 	if err != nil {
 		if err == http.ErrNoCookie {
-			userID, err := cookies.GetCookieValue(r, "tmp-user-id")
+			// A forged cookie is treated like a missing one, the user gets a new id.
+			userID, err := GetTmpUserID(r)
 			if err != nil {
-				if err == http.ErrNoCookie {
+				if err == http.ErrNoCookie || err == ErrInvalidTmpUserID {
 					return "", false, nil
 				}
 				return "", false, err
@@ -59,6 +84,40 @@ func GetUserID(r *http.Request) (string, bool, error) {
 	return "", false, nil
 }
 
+// SignTmpUserID returns the tmp-user-id cookie value of the anonymous user.
+// The id is signed, so a client can't pass itself off as another user
+// to take over their cart and orders on login.
+func SignTmpUserID(userID string) string {
+	return userID + "." + tmpUserIDSignature(userID)
+}
+
+// GetTmpUserID returns the anonymous user id of the signed tmp-user-id cookie,
+// http.ErrNoCookie if there is none.
+func GetTmpUserID(r *http.Request) (string, error) {
+	value, err := cookies.GetCookieValue(r, "tmp-user-id")
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", http.ErrNoCookie
+	}
+	i := strings.LastIndexByte(value, '.')
+	if i <= 0 {
+		return "", ErrInvalidTmpUserID
+	}
+	userID, signature := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(tmpUserIDSignature(userID))) {
+		return "", ErrInvalidTmpUserID
+	}
+	return userID, nil
+}
+
+func tmpUserIDSignature(userID string) string {
+	mac := hmac.New(sha256.New, JwtKey)
+	mac.Write([]byte("tmp-user-id:" + userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
 func GetToken(r *http.Request) (string, error) {
 	token := stripBearerPrefixFromTokenString(r.Header.Get("Authorization"))
 	if token == "" {
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetTmpUserID(t *testing.T) {
+	signed := SignTmpUserID("5f8d0d55b54764421b7156c3")
+	tests := []struct {
+		name    string
+		cookie  string
+		want    string
+		wantErr error
+	}{
+		{"signed", signed, "5f8d0d55b54764421b7156c3", nil},
+		{"no cookie", "", "", http.ErrNoCookie},
+		{"unsigned", "5f8d0d55b54764421b7156c3", "", ErrInvalidTmpUserID},
+		{"other id", "5f8d0d55b54764421b7156c4" + signed[len("5f8d0d55b54764421b7156c3"):], "", ErrInvalidTmpUserID},
+		{"no id", signed[len("5f8d0d55b54764421b7156c3"):], "", ErrInvalidTmpUserID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "tmp-user-id", Value: tt.cookie})
+			}
+			got, err := GetTmpUserID(r)
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("got %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+
+			userID, isLoggedIn, err := GetUserID(r)
+			if userID != tt.want || isLoggedIn || err != nil {
+				t.Errorf("GetUserID: got %q, %v, %v, want %q", userID, isLoggedIn, err, tt.want)
+			}
+		})
+	}
+}
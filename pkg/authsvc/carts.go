@@ -0,0 +1,73 @@
+package erpsvc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/auth"
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+// CartService is the part of the cart service the auth service depends on.
+type CartService interface {
+	// MergeCarts moves the anonymous cart into the cart of the user the token belongs to.
+	MergeCarts(ctx context.Context, token string, tmpUserID string) error
+}
+
+// cartClient is an HTTP client of the cart service.
+type cartClient struct {
+	merge endpoint.Endpoint
+}
+
+func newCartClient(rawURL string, timeout time.Duration) (*cartClient, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: timeout}
+
+	c := &cartClient{
+		merge: kithttp.NewClient(
+			http.MethodPost,
+			base.ResolveReference(&url.URL{Path: "/api/v1/card/merge"}),
+			encodeMergeCartsRequest,
+			decodeMergeCartsResponse,
+			kithttp.SetClient(client),
+		).Endpoint(),
+	}
+	return c, nil
+}
+
+func (c *cartClient) MergeCarts(ctx context.Context, token string, tmpUserID string) error {
+	_, err := c.merge(ctx, mergeCartsRequest{Token: token, TmpUserID: tmpUserID})
+	return err
+}
+
+type mergeCartsRequest struct {
+	Token     string
+	TmpUserID string
+}
+
+func encodeMergeCartsRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(mergeCartsRequest)
+	r.Header.Set("Authorization", "Bearer "+req.Token)
+	r.AddCookie(&http.Cookie{Name: "tmp-user-id", Value: auth.SignTmpUserID(req.TmpUserID)})
+	return nil
+}
+
+func decodeMergeCartsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		return nil, decodeError(r)
+	}
+	return true, nil
+}
+
+func decodeError(r *http.Response) error {
+	e := &erp.ServiceError{}
+	e.Decode(r)
+	return e
+}
@@ -51,15 +51,60 @@ func (mw *LoggingMiddleware) RefreshToken(ctx context.Context, token string) (*e
 	return resp, err
 }
 
-func (mw *LoggingMiddleware) GetUsers(ctx context.Context) ([]*erp.User, error) {
+func (mw *LoggingMiddleware) GetUsers(ctx context.Context, page *erp.PageRequest) (*erp.UserPage, error) {
 	begin := time.Now()
-	resp, err := mw.next.GetUsers(ctx)
+	resp, err := mw.next.GetUsers(ctx, page)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "GetUsers", "err", err, "took", time.Since(begin))
 	}
 	return resp, err
 }
 
+func (mw *LoggingMiddleware) GetAddresses(ctx context.Context, userID string) ([]*erp.Address, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetAddresses(ctx, userID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetAddresses", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) CreateAddress(ctx context.Context, userID string, input *erp.AddressInput) (*erp.Address, error) {
+	begin := time.Now()
+	resp, err := mw.next.CreateAddress(ctx, userID, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "CreateAddress", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) UpdateAddress(ctx context.Context, userID string, id string, input *erp.AddressInput) (*erp.Address, error) {
+	begin := time.Now()
+	resp, err := mw.next.UpdateAddress(ctx, userID, id, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "UpdateAddress", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) SetDefaultAddress(ctx context.Context, userID string, id string) error {
+	begin := time.Now()
+	err := mw.next.SetDefaultAddress(ctx, userID, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "SetDefaultAddress", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) DeleteAddress(ctx context.Context, userID string, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteAddress(ctx, userID, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeleteAddress", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
 func NewInstrumentingMiddleware(next Service, prefix string) *InstrumentingMiddleware {
 	return &InstrumentingMiddleware{
 		next: next,
@@ -109,10 +154,50 @@ func (mw *InstrumentingMiddleware) RefreshToken(ctx context.Context, token strin
 	return resp, err
 }
 
-func (mw *InstrumentingMiddleware) GetUsers(ctx context.Context) ([]*erp.User, error) {
+func (mw *InstrumentingMiddleware) GetUsers(ctx context.Context, page *erp.PageRequest) (*erp.UserPage, error) {
 	begin := time.Now()
-	resp, err := mw.next.GetUsers(ctx)
+	resp, err := mw.next.GetUsers(ctx, page)
 	labels := []string{"method", "GetUsers", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return resp, err
 }
+
+func (mw *InstrumentingMiddleware) GetAddresses(ctx context.Context, userID string) ([]*erp.Address, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetAddresses(ctx, userID)
+	labels := []string{"method", "GetAddresses", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) CreateAddress(ctx context.Context, userID string, input *erp.AddressInput) (*erp.Address, error) {
+	begin := time.Now()
+	resp, err := mw.next.CreateAddress(ctx, userID, input)
+	labels := []string{"method", "CreateAddress", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) UpdateAddress(ctx context.Context, userID string, id string, input *erp.AddressInput) (*erp.Address, error) {
+	begin := time.Now()
+	resp, err := mw.next.UpdateAddress(ctx, userID, id, input)
+	labels := []string{"method", "UpdateAddress", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) SetDefaultAddress(ctx context.Context, userID string, id string) error {
+	begin := time.Now()
+	err := mw.next.SetDefaultAddress(ctx, userID, id)
+	labels := []string{"method", "SetDefaultAddress", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) DeleteAddress(ctx context.Context, userID string, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteAddress(ctx, userID, id)
+	labels := []string{"method", "DeleteAddress", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
@@ -2,6 +2,7 @@ package erpsvc
 
 import (
 	"context"
+	"github.com/anabiozz/core/lapkins/pkg/storage/mongo"
 	"github.com/gorilla/handlers"
 	"net/http"
 	"net/http/pprof"
@@ -23,6 +24,8 @@ type ServerConfig struct {
 	ShutdownTimeout time.Duration
 	MetricPrefix    string
 	AllowedOrigins  []string
+	CartServiceURL  string
+	CartTimeout     time.Duration
 }
 
 // Server is a service server.
@@ -33,14 +36,32 @@ type Server struct {
 
 // NewServer creates a new server.
 func NewServer(cfg ServerConfig) (*Server, error) {
-	var svc Service
-	svc, err := newService(&ServiceConfig{
+	storage, err := mongo.New(mongo.Config{
 		Logger: cfg.Logger,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	cartTimeout := cfg.CartTimeout
+	if cartTimeout == 0 {
+		cartTimeout = 3 * time.Second
+	}
+	carts, err := newCartClient(cfg.CartServiceURL, cartTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var svc Service
+	svc, err = newService(&ServiceConfig{
+		Logger:  cfg.Logger,
+		Storage: storage,
+		Carts:   carts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	svc = NewLoggingMiddleware(svc, cfg.Logger)
 	svc = NewInstrumentingMiddleware(svc, cfg.MetricPrefix+"_api")
 
@@ -119,5 +140,40 @@ func makeHandler(svc Service) http.Handler {
 		opts...,
 	))
 
+	router.Path("/api/v1/user/addresses").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetAddressesEndpoint(svc),
+		decodeGetAddressesRequest,
+		encodeGetAddressesResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/user/addresses").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeCreateAddressEndpoint(svc),
+		decodeCreateAddressRequest,
+		encodeCreateAddressResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/user/addresses/{id}").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeUpdateAddressEndpoint(svc),
+		decodeUpdateAddressRequest,
+		encodeUpdateAddressResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/user/addresses/{id}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeleteAddressEndpoint(svc),
+		decodeDeleteAddressRequest,
+		encodeDeleteAddressResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/user/addresses/{id}/default").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeSetDefaultAddressEndpoint(svc),
+		decodeSetDefaultAddressRequest,
+		encodeSetDefaultAddressResponse,
+		opts...,
+	))
+
 	return router
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/anabiozz/core/lapkins/pkg/erp"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 	"strconv"
@@ -14,25 +15,40 @@ import (
 
 type Storage interface {
 	RegisterUser(ctx context.Context, user *erp.User) (string, error)
-	Login(ctx context.Context, email string, phone int64, tmpUserID string) (*erp.User, error)
-	GetUsers(ctx context.Context) ([]*erp.User, error)
+	Login(ctx context.Context, email string, phone int64) (*erp.User, error)
+	GetUsers(ctx context.Context, page *erp.PageRequest) (*erp.UserPage, error)
+	GetAddresses(ctx context.Context, userID string) ([]*erp.Address, error)
+	GetAddress(ctx context.Context, userID string, id string) (*erp.Address, error)
+	CreateAddress(ctx context.Context, address *erp.Address) error
+	UpdateAddress(ctx context.Context, address *erp.Address) error
+	SetDefaultAddress(ctx context.Context, userID string, id string) error
+	DeleteAddress(ctx context.Context, userID string, id string) error
+	GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error)
+	MergeOrders(ctx context.Context, tmpUserID string, userID string) error
 }
 
 type Service interface {
 	Register(ctx context.Context, input *erp.UserInput) (*erp.UserOutput, error)
 	Login(ctx context.Context, input *erp.UserInput, tmpUserID string) (*erp.UserOutput, bool, error)
 	RefreshToken(ctx context.Context, token string) (*erp.UserOutput, error)
-	GetUsers(ctx context.Context) ([]*erp.User, error)
+	GetUsers(ctx context.Context, page *erp.PageRequest) (*erp.UserPage, error)
+	GetAddresses(ctx context.Context, userID string) ([]*erp.Address, error)
+	CreateAddress(ctx context.Context, userID string, input *erp.AddressInput) (*erp.Address, error)
+	UpdateAddress(ctx context.Context, userID string, id string, input *erp.AddressInput) (*erp.Address, error)
+	SetDefaultAddress(ctx context.Context, userID string, id string) error
+	DeleteAddress(ctx context.Context, userID string, id string) error
 }
 
 type service struct {
 	logger  log.Logger
 	storage Storage
+	carts   CartService
 }
 
 type ServiceConfig struct {
 	Logger  log.Logger
 	Storage Storage
+	Carts   CartService
 }
 
 func newService(cfg *ServiceConfig) (*service, error) {
@@ -44,6 +60,7 @@ func newService(cfg *ServiceConfig) (*service, error) {
 	svc := &service{
 		logger:  logger,
 		storage: cfg.Storage,
+		carts:   cfg.Carts,
 	}
 
 	return svc, nil
@@ -105,7 +122,7 @@ func (s *service) Login(ctx context.Context, input *erp.UserInput, tmpUserID str
 		return nil, false, erp.ErrBadRequest("validation error: %v", err)
 	}
 
-	result, err := s.storage.Login(ctx, input.Email, input.Phone, tmpUserID)
+	result, err := s.storage.Login(ctx, input.Email, input.Phone)
 	if err != nil {
 		return nil, false, erp.ErrBadRequest("%s", err)
 	}
@@ -126,6 +143,7 @@ func (s *service) Login(ctx context.Context, input *erp.UserInput, tmpUserID str
 	claims := &auth.Claims{
 		Subject:        claimSubject,
 		UserID:         result.ID.Hex(),
+		Staff:          result.IsStaff,
 		StandardClaims: jwt.StandardClaims{
 			//ExpiresAt: expirationTime.Unix(),
 		},
@@ -141,9 +159,20 @@ func (s *service) Login(ctx context.Context, input *erp.UserInput, tmpUserID str
 	userOutput.ID = result.ID.Hex()
 	userOutput.Token = tokenString
 
+	// The tmp user id comes from the signed cookie, so the cart and the orders
+	// are the ones of the anonymous user of this browser. They are kept with
+	// the cookie if the merge fails and are merged on the next login.
 	var unsetTmpUserIDCookie bool
 	if tmpUserID != "" {
-		unsetTmpUserIDCookie = true
+		cartErr := s.carts.MergeCarts(ctx, tokenString, tmpUserID)
+		if cartErr != nil {
+			level.Error(s.logger).Log("msg", "failed to merge carts", "user", userOutput.ID, "err", cartErr)
+		}
+		orderErr := s.storage.MergeOrders(ctx, tmpUserID, userOutput.ID)
+		if orderErr != nil {
+			level.Error(s.logger).Log("msg", "failed to merge orders", "user", userOutput.ID, "err", orderErr)
+		}
+		unsetTmpUserIDCookie = cartErr == nil && orderErr == nil
 	}
 
 	return userOutput, unsetTmpUserIDCookie, nil
@@ -184,10 +213,97 @@ func (s *service) RefreshToken(ctx context.Context, token string) (*erp.UserOutp
 	return userOutput, nil
 }
 
-func (s *service) GetUsers(ctx context.Context) ([]*erp.User, error) {
-	users, err := s.storage.GetUsers(ctx)
+func (s *service) GetUsers(ctx context.Context, page *erp.PageRequest) (*erp.UserPage, error) {
+	users, err := s.storage.GetUsers(ctx, page)
 	if err != nil {
+		if err == erp.ErrInvalidCursor {
+			return nil, erp.ErrBadRequest("%s", err)
+		}
 		return nil, erp.ErrInternal("%s", err)
 	}
 	return users, nil
 }
+
+// GetAddresses returns the address book of the user, the default address first.
+func (s *service) GetAddresses(ctx context.Context, userID string) ([]*erp.Address, error) {
+	addresses, err := s.storage.GetAddresses(ctx, userID)
+	if err != nil {
+		return nil, erp.ErrInternal("%s", err)
+	}
+	return addresses, nil
+}
+
+// CreateAddress adds an address to the address book of the user,
+// the first address becomes the default one.
+func (s *service) CreateAddress(ctx context.Context, userID string, input *erp.AddressInput) (*erp.Address, error) {
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkAddressLocation(ctx, input); err != nil {
+		return nil, err
+	}
+	address := input.Address(primitive.NewObjectID().Hex(), userID, time.Now())
+	if err := s.storage.CreateAddress(ctx, address); err != nil {
+		return nil, erp.ErrInternal("%s", err)
+	}
+	return address, nil
+}
+
+// UpdateAddress replaces an address of the user. Orders shipped to the address
+// keep the copy they were placed with.
+func (s *service) UpdateAddress(ctx context.Context, userID string, id string, input *erp.AddressInput) (*erp.Address, error) {
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkAddressLocation(ctx, input); err != nil {
+		return nil, err
+	}
+	address := input.Address(id, userID, time.Now())
+	if err := s.storage.UpdateAddress(ctx, address); err != nil {
+		return nil, addressError(err, id)
+	}
+	return address, nil
+}
+
+// SetDefaultAddress makes the address the default shipping address of the user.
+func (s *service) SetDefaultAddress(ctx context.Context, userID string, id string) error {
+	if err := s.storage.SetDefaultAddress(ctx, userID, id); err != nil {
+		return addressError(err, id)
+	}
+	return nil
+}
+
+// DeleteAddress deletes an address of the user, another address becomes
+// the default one when the default address is deleted.
+func (s *service) DeleteAddress(ctx context.Context, userID string, id string) error {
+	if err := s.storage.DeleteAddress(ctx, userID, id); err != nil {
+		return addressError(err, id)
+	}
+	return nil
+}
+
+// checkAddressLocation requires the location of the address once delivery zones are set up,
+// checkout can't find the zone of an address without one.
+func (s *service) checkAddressLocation(ctx context.Context, input *erp.AddressInput) error {
+	if input.Location != nil {
+		return nil
+	}
+	zones, err := s.storage.GetDeliveryZones(ctx)
+	if err != nil {
+		return erp.ErrInternal("%s", err)
+	}
+	var v erp.Validation
+	if len(zones) > 0 {
+		v.Add("location", "is required to check the delivery zone")
+	}
+	return v.Err()
+}
+
+func addressError(err error, id string) error {
+	if err == erp.ErrAddressNotFound {
+		return erp.ErrNotFound("address %s not found", id)
+	}
+	return erp.ErrInternal("%s", err)
+}
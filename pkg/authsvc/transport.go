@@ -4,12 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/anabiozz/core/lapkins/pkg/auth"
-	"github.com/anabiozz/core/lapkins/pkg/cookies"
 	"github.com/anabiozz/core/lapkins/pkg/erp"
 	"net/http"
 	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/gorilla/mux"
 )
 
 type registerRequest struct {
@@ -65,18 +65,111 @@ func makeRefreshTokenEndpoint(s Service) endpoint.Endpoint {
 	}
 }
 
+type getUsersRequest struct {
+	Page *erp.PageRequest
+}
+
 type getUsersResponse struct {
-	Users []*erp.User
+	Users *erp.UserPage
 	Err   error
 }
 
 func makeGetUsersEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		users, err := s.GetUsers(ctx)
+		req := request.(getUsersRequest)
+		users, err := s.GetUsers(ctx, req.Page)
 		return getUsersResponse{Users: users, Err: err}, nil
 	}
 }
 
+type getAddressesRequest struct {
+	UserID string
+}
+
+type getAddressesResponse struct {
+	Addresses []*erp.Address
+	Err       error
+}
+
+func makeGetAddressesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getAddressesRequest)
+		addresses, err := s.GetAddresses(ctx, req.UserID)
+		return getAddressesResponse{Addresses: addresses, Err: err}, nil
+	}
+}
+
+type createAddressRequest struct {
+	UserID string
+	Input  *erp.AddressInput
+}
+
+type createAddressResponse struct {
+	Address *erp.Address
+	Err     error
+}
+
+func makeCreateAddressEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createAddressRequest)
+		address, err := s.CreateAddress(ctx, req.UserID, req.Input)
+		return createAddressResponse{Address: address, Err: err}, nil
+	}
+}
+
+type updateAddressRequest struct {
+	UserID string
+	ID     string
+	Input  *erp.AddressInput
+}
+
+type updateAddressResponse struct {
+	Address *erp.Address
+	Err     error
+}
+
+func makeUpdateAddressEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(updateAddressRequest)
+		address, err := s.UpdateAddress(ctx, req.UserID, req.ID, req.Input)
+		return updateAddressResponse{Address: address, Err: err}, nil
+	}
+}
+
+type setDefaultAddressRequest struct {
+	UserID string
+	ID     string
+}
+
+type setDefaultAddressResponse struct {
+	Err error
+}
+
+func makeSetDefaultAddressEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setDefaultAddressRequest)
+		err = s.SetDefaultAddress(ctx, req.UserID, req.ID)
+		return setDefaultAddressResponse{Err: err}, nil
+	}
+}
+
+type deleteAddressRequest struct {
+	UserID string
+	ID     string
+}
+
+type deleteAddressResponse struct {
+	Err error
+}
+
+func makeDeleteAddressEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteAddressRequest)
+		err = s.DeleteAddress(ctx, req.UserID, req.ID)
+		return deleteAddressResponse{Err: err}, nil
+	}
+}
+
 func decodeRegisterRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	req := registerRequest{Input: &erp.UserInput{}}
 	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
@@ -106,13 +199,11 @@ func decodeLoginRequest(_ context.Context, r *http.Request) (interface{}, error)
 	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
 		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
 	}
-	tmpUserID, err := cookies.GetCookieValue(r, "tmp-user-id")
-	if err != nil {
-		if err != http.ErrNoCookie {
-			return nil, err
-		}
+	// Only the anonymous user we issued the cookie to is merged into the account.
+	tmpUserID, err := auth.GetTmpUserID(r)
+	if err == nil {
+		req.TmpUserID = tmpUserID
 	}
-	req.TmpUserID = tmpUserID
 	return req, nil
 }
 
@@ -165,11 +256,19 @@ func encodeRefreshTokenResponse(ctx context.Context, w http.ResponseWriter, resp
 
 func decodeGetUsersRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	header := r.Header.Get("Authorization")
-	_, err := auth.Check(header)
+	_, err := auth.CheckStaff(header)
+	if err == auth.ErrNotStaff {
+		return nil, erp.ErrForbidden("%s", err)
+	}
 	if err != nil {
 		return nil, erp.ErrUnauthorized("%s", err)
 	}
-	return r, nil
+	page, err := erp.DecodePageRequest(r.URL.Query(), "id", "email", "last_name", "registration_date")
+	if err != nil {
+		return nil, err
+	}
+	req := getUsersRequest{Page: page}
+	return req, nil
 }
 
 func encodeGetUsersResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
@@ -182,6 +281,117 @@ func encodeGetUsersResponse(ctx context.Context, w http.ResponseWriter, response
 	return json.NewEncoder(w).Encode(res.Users)
 }
 
+// decodeAddressUser takes the user from the token, only logged in users have an address book.
+func decodeAddressUser(r *http.Request) (string, error) {
+	userID, isLoggedIn, err := auth.GetUserID(r)
+	if err != nil {
+		return "", erp.ErrUnauthorized("%s", err)
+	}
+	if !isLoggedIn {
+		return "", erp.ErrUnauthorized("%s", "user is not logged in")
+	}
+	return userID, nil
+}
+
+func decodeGetAddressesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	userID, err := decodeAddressUser(r)
+	if err != nil {
+		return nil, err
+	}
+	return getAddressesRequest{UserID: userID}, nil
+}
+
+func encodeGetAddressesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getAddressesResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Addresses)
+}
+
+func decodeCreateAddressRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	userID, err := decodeAddressUser(r)
+	if err != nil {
+		return nil, err
+	}
+	req := createAddressRequest{UserID: userID, Input: &erp.AddressInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeCreateAddressResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(createAddressResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(res.Address)
+}
+
+func decodeUpdateAddressRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	userID, err := decodeAddressUser(r)
+	if err != nil {
+		return nil, err
+	}
+	req := updateAddressRequest{UserID: userID, ID: mux.Vars(r)["id"], Input: &erp.AddressInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeUpdateAddressResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(updateAddressResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Address)
+}
+
+func decodeSetDefaultAddressRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	userID, err := decodeAddressUser(r)
+	if err != nil {
+		return nil, err
+	}
+	return setDefaultAddressRequest{UserID: userID, ID: mux.Vars(r)["id"]}, nil
+}
+
+func encodeSetDefaultAddressResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(setDefaultAddressResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+func decodeDeleteAddressRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	userID, err := decodeAddressUser(r)
+	if err != nil {
+		return nil, err
+	}
+	return deleteAddressRequest{UserID: userID, ID: mux.Vars(r)["id"]}, nil
+}
+
+func encodeDeleteAddressResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deleteAddressResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
 // ****************** Errors *********************
 
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
@@ -194,11 +404,3 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	}
 	e.Encode(w)
 }
-
-//
-//func decodeError(r *http.Response) error {
-//	e := &erp.ServiceError{}
-//	e.Decode(r)
-//
-//	return e
-//}
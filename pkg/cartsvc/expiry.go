@@ -0,0 +1,73 @@
+package erpsvc
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cartExpirer expires carts nobody touched for a while and purges anonymous ones
+// once the retention window is over.
+type cartExpirer struct {
+	storage     Storage
+	logger      log.Logger
+	expireAfter time.Duration
+	retention   time.Duration
+	expired     metrics.Counter
+	purged      metrics.Counter
+}
+
+func newCartExpirer(storage Storage, logger log.Logger, expireAfter time.Duration, retention time.Duration, prefix string) *cartExpirer {
+	return &cartExpirer{
+		storage:     storage,
+		logger:      logger,
+		expireAfter: expireAfter,
+		retention:   retention,
+		expired: kitprometheus.NewCounterFrom(
+			prometheus.CounterOpts{
+				Name: prefix + "_expired_carts_total",
+				Help: "Number of carts expired due to inactivity",
+			},
+			[]string{"type"},
+		),
+		purged: kitprometheus.NewCounterFrom(
+			prometheus.CounterOpts{
+				Name: prefix + "_purged_carts_total",
+				Help: "Number of expired anonymous carts deleted",
+			},
+			[]string{},
+		),
+	}
+}
+
+func (e *cartExpirer) run(ctx context.Context) error {
+	now := time.Now()
+
+	carts, err := e.storage.ExpireCarts(ctx, now.Add(-e.expireAfter))
+	for _, cart := range carts {
+		cartType := cart.Type
+		if cartType == "" {
+			cartType = "tmp"
+		}
+		e.expired.With("type", cartType).Add(1)
+	}
+	if err != nil {
+		return err
+	}
+
+	purged, err := e.storage.PurgeExpiredCarts(ctx, now.Add(-e.retention))
+	e.purged.Add(float64(purged))
+	if err != nil {
+		return err
+	}
+
+	if len(carts) > 0 || purged > 0 {
+		level.Info(e.logger).Log("msg", "expired carts", "expired", len(carts), "purged", purged)
+	}
+	return nil
+}
@@ -51,15 +51,33 @@ func (mw *LoggingMiddleware) IncreaseProductQuantity(ctx context.Context, userID
 	return err
 }
 
-func (mw *LoggingMiddleware) LoadCart(ctx context.Context, userID string) ([]*erp.CartProduct, error) {
+func (mw *LoggingMiddleware) LoadCart(ctx context.Context, userID string, market erp.Market) (*erp.PricedCart, error) {
 	begin := time.Now()
-	resp, err := mw.next.LoadCart(ctx, userID)
+	resp, err := mw.next.LoadCart(ctx, userID, market)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "LoadCart", "err", err, "took", time.Since(begin))
 	}
 	return resp, err
 }
 
+func (mw *LoggingMiddleware) GetHeaderCartInfo(ctx context.Context, userID string, market erp.Market) (*erp.HeaderCartInfo, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetHeaderCartInfo(ctx, userID, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetHeaderCartInfo", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) SetProductQuantity(ctx context.Context, userID string, sku string, qty int, version int64, market erp.Market) (*erp.PricedCart, error) {
+	begin := time.Now()
+	resp, err := mw.next.SetProductQuantity(ctx, userID, sku, qty, version, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "SetProductQuantity", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
 func (mw *LoggingMiddleware) RemoveProduct(ctx context.Context, userID string, sku string) error {
 	begin := time.Now()
 	err := mw.next.RemoveProduct(ctx, userID, sku)
@@ -69,6 +87,132 @@ func (mw *LoggingMiddleware) RemoveProduct(ctx context.Context, userID string, s
 	return err
 }
 
+func (mw *LoggingMiddleware) ApplyCoupon(ctx context.Context, userID string, code string, market erp.Market) (*erp.PricedCart, error) {
+	begin := time.Now()
+	resp, err := mw.next.ApplyCoupon(ctx, userID, code, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "ApplyCoupon", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) RemoveCoupon(ctx context.Context, userID string) error {
+	begin := time.Now()
+	err := mw.next.RemoveCoupon(ctx, userID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "RemoveCoupon", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) MergeCarts(ctx context.Context, tmpUserID string, userID string) error {
+	begin := time.Now()
+	err := mw.next.MergeCarts(ctx, tmpUserID, userID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "MergeCarts", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) GetWishlist(ctx context.Context, userID string, market erp.Market) (*erp.Wishlist, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetWishlist(ctx, userID, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetWishlist", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) GetSharedWishlist(ctx context.Context, token string, market erp.Market) (*erp.Wishlist, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetSharedWishlist(ctx, token, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetSharedWishlist", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) AddToWishlist(ctx context.Context, sku string, userID string) (bool, string, error) {
+	begin := time.Now()
+	setTmpUserIDCookie, userID, err := mw.next.AddToWishlist(ctx, sku, userID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "AddToWishlist", "err", err, "took", time.Since(begin))
+	}
+	return setTmpUserIDCookie, userID, err
+}
+
+func (mw *LoggingMiddleware) RemoveFromWishlist(ctx context.Context, userID string, sku string) error {
+	begin := time.Now()
+	err := mw.next.RemoveFromWishlist(ctx, userID, sku)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "RemoveFromWishlist", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) MoveToCart(ctx context.Context, userID string, sku string, isLoggedIn bool) error {
+	begin := time.Now()
+	err := mw.next.MoveToCart(ctx, userID, sku, isLoggedIn)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "MoveToCart", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) ShareWishlist(ctx context.Context, userID string) (string, error) {
+	begin := time.Now()
+	resp, err := mw.next.ShareWishlist(ctx, userID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "ShareWishlist", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) GetDeliveryOptions(ctx context.Context, userID string, destination *erp.ShippingDestination, market erp.Market) (*erp.DeliveryQuote, error) {
+	begin := time.Now()
+	quote, err := mw.next.GetDeliveryOptions(ctx, userID, destination, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetDeliveryOptions", "err", err, "took", time.Since(begin))
+	}
+	return quote, err
+}
+
+func (mw *LoggingMiddleware) Checkout(ctx context.Context, userID string, input *erp.CheckoutInput, market erp.Market) (*erp.Order, error) {
+	begin := time.Now()
+	resp, err := mw.next.Checkout(ctx, userID, input, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "Checkout", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) HandlePaymentEvent(ctx context.Context, payload []byte, signature string) error {
+	begin := time.Now()
+	err := mw.next.HandlePaymentEvent(ctx, payload, signature)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "HandlePaymentEvent", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) GetOrders(ctx context.Context, userID string, status erp.OrderStatus, page *erp.PageRequest) (*erp.CustomerOrderPage, error) {
+	begin := time.Now()
+	orders, err := mw.next.GetOrders(ctx, userID, status, page)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetOrders", "err", err, "took", time.Since(begin))
+	}
+	return orders, err
+}
+
+func (mw *LoggingMiddleware) GetOrder(ctx context.Context, userID string, id string) (*erp.CustomerOrder, error) {
+	begin := time.Now()
+	order, err := mw.next.GetOrder(ctx, userID, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetOrder", "err", err, "took", time.Since(begin))
+	}
+	return order, err
+}
+
 func NewInstrumentingMiddleware(next Service, prefix string) *InstrumentingMiddleware {
 	return &InstrumentingMiddleware{
 		next: next,
@@ -118,14 +262,30 @@ func (mw *InstrumentingMiddleware) IncreaseProductQuantity(ctx context.Context,
 	return err
 }
 
-func (mw *InstrumentingMiddleware) LoadCart(ctx context.Context, userID string) ([]*erp.CartProduct, error) {
+func (mw *InstrumentingMiddleware) LoadCart(ctx context.Context, userID string, market erp.Market) (*erp.PricedCart, error) {
 	begin := time.Now()
-	resp, err := mw.next.LoadCart(ctx, userID)
+	resp, err := mw.next.LoadCart(ctx, userID, market)
 	labels := []string{"method", "LoadCart", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return resp, err
 }
 
+func (mw *InstrumentingMiddleware) GetHeaderCartInfo(ctx context.Context, userID string, market erp.Market) (*erp.HeaderCartInfo, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetHeaderCartInfo(ctx, userID, market)
+	labels := []string{"method", "GetHeaderCartInfo", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) SetProductQuantity(ctx context.Context, userID string, sku string, qty int, version int64, market erp.Market) (*erp.PricedCart, error) {
+	begin := time.Now()
+	resp, err := mw.next.SetProductQuantity(ctx, userID, sku, qty, version, market)
+	labels := []string{"method", "SetProductQuantity", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
 func (mw *InstrumentingMiddleware) RemoveProduct(ctx context.Context, userID string, sku string) error {
 	begin := time.Now()
 	err := mw.next.RemoveProduct(ctx, userID, sku)
@@ -133,3 +293,115 @@ func (mw *InstrumentingMiddleware) RemoveProduct(ctx context.Context, userID str
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return err
 }
+
+func (mw *InstrumentingMiddleware) ApplyCoupon(ctx context.Context, userID string, code string, market erp.Market) (*erp.PricedCart, error) {
+	begin := time.Now()
+	resp, err := mw.next.ApplyCoupon(ctx, userID, code, market)
+	labels := []string{"method", "ApplyCoupon", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) RemoveCoupon(ctx context.Context, userID string) error {
+	begin := time.Now()
+	err := mw.next.RemoveCoupon(ctx, userID)
+	labels := []string{"method", "RemoveCoupon", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) MergeCarts(ctx context.Context, tmpUserID string, userID string) error {
+	begin := time.Now()
+	err := mw.next.MergeCarts(ctx, tmpUserID, userID)
+	labels := []string{"method", "MergeCarts", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) GetWishlist(ctx context.Context, userID string, market erp.Market) (*erp.Wishlist, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetWishlist(ctx, userID, market)
+	labels := []string{"method", "GetWishlist", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) GetSharedWishlist(ctx context.Context, token string, market erp.Market) (*erp.Wishlist, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetSharedWishlist(ctx, token, market)
+	labels := []string{"method", "GetSharedWishlist", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) AddToWishlist(ctx context.Context, sku string, userID string) (bool, string, error) {
+	begin := time.Now()
+	setTmpUserIDCookie, userID, err := mw.next.AddToWishlist(ctx, sku, userID)
+	labels := []string{"method", "AddToWishlist", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return setTmpUserIDCookie, userID, err
+}
+
+func (mw *InstrumentingMiddleware) RemoveFromWishlist(ctx context.Context, userID string, sku string) error {
+	begin := time.Now()
+	err := mw.next.RemoveFromWishlist(ctx, userID, sku)
+	labels := []string{"method", "RemoveFromWishlist", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) MoveToCart(ctx context.Context, userID string, sku string, isLoggedIn bool) error {
+	begin := time.Now()
+	err := mw.next.MoveToCart(ctx, userID, sku, isLoggedIn)
+	labels := []string{"method", "MoveToCart", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) ShareWishlist(ctx context.Context, userID string) (string, error) {
+	begin := time.Now()
+	resp, err := mw.next.ShareWishlist(ctx, userID)
+	labels := []string{"method", "ShareWishlist", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) GetDeliveryOptions(ctx context.Context, userID string, destination *erp.ShippingDestination, market erp.Market) (*erp.DeliveryQuote, error) {
+	begin := time.Now()
+	quote, err := mw.next.GetDeliveryOptions(ctx, userID, destination, market)
+	labels := []string{"method", "GetDeliveryOptions", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return quote, err
+}
+
+func (mw *InstrumentingMiddleware) Checkout(ctx context.Context, userID string, input *erp.CheckoutInput, market erp.Market) (*erp.Order, error) {
+	begin := time.Now()
+	resp, err := mw.next.Checkout(ctx, userID, input, market)
+	labels := []string{"method", "Checkout", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) HandlePaymentEvent(ctx context.Context, payload []byte, signature string) error {
+	begin := time.Now()
+	err := mw.next.HandlePaymentEvent(ctx, payload, signature)
+	labels := []string{"method", "HandlePaymentEvent", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) GetOrders(ctx context.Context, userID string, status erp.OrderStatus, page *erp.PageRequest) (*erp.CustomerOrderPage, error) {
+	begin := time.Now()
+	orders, err := mw.next.GetOrders(ctx, userID, status, page)
+	labels := []string{"method", "GetOrders", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return orders, err
+}
+
+func (mw *InstrumentingMiddleware) GetOrder(ctx context.Context, userID string, id string) (*erp.CustomerOrder, error) {
+	begin := time.Now()
+	order, err := mw.next.GetOrder(ctx, userID, id)
+	labels := []string{"method", "GetOrder", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return order, err
+}
@@ -2,6 +2,10 @@ package erpsvc
 
 import (
 	"context"
+	"github.com/anabiozz/core/lapkins/pkg/payment"
+	"github.com/anabiozz/core/lapkins/pkg/shipping"
+	"github.com/anabiozz/core/lapkins/pkg/storage/mongo"
+	"github.com/anabiozz/core/lapkins/pkg/worker"
 	"github.com/gorilla/handlers"
 	"net/http"
 	"net/http/pprof"
@@ -23,24 +27,74 @@ type ServerConfig struct {
 	ShutdownTimeout time.Duration
 	MetricPrefix    string
 	AllowedOrigins  []string
+	Payment         payment.Config
+	Shipping        shipping.Config
+	ReservationTTL  time.Duration
+	SweepInterval   time.Duration
+	CartExpiry      CartExpiryConfig
+}
+
+// CartExpiryConfig configures the abandoned cart expiry job.
+type CartExpiryConfig struct {
+	// Interval is how often the job runs.
+	Interval time.Duration
+	// ExpireAfter is how long a cart may stay untouched before it expires.
+	ExpireAfter time.Duration
+	// Retention is how long expired anonymous carts are kept before they are deleted.
+	Retention time.Duration
 }
 
 // Server is a service server.
 type Server struct {
-	cfg *ServerConfig
-	srv *http.Server
+	cfg     *ServerConfig
+	srv     *http.Server
+	workers []*worker.Worker
 }
 
 // NewServer creates a new server.
 func NewServer(cfg ServerConfig) (*Server, error) {
-	var svc Service
-	svc, err := newService(&ServiceConfig{
+	storage, err := mongo.New(mongo.Config{
 		Logger: cfg.Logger,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	payments, err := payment.NewProvider(cfg.Payment)
+	if err != nil {
+		return nil, err
+	}
+
+	carriers, err := shipping.NewCarriers(cfg.Shipping)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := newService(&ServiceConfig{
+		Logger:         cfg.Logger,
+		Storage:        storage,
+		Payments:       payments,
+		Carriers:       carriers,
+		ReservationTTL: cfg.ReservationTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sweepInterval := cfg.SweepInterval
+	if sweepInterval == 0 {
+		sweepInterval = time.Minute
+	}
+	workers := []*worker.Worker{
+		worker.New("reservation-sweeper", sweepInterval, cfg.Logger, base.releaseExpiredReservations),
+	}
+	if cfg.CartExpiry.Interval > 0 {
+		expirer := newCartExpirer(storage, cfg.Logger, cfg.CartExpiry.ExpireAfter, cfg.CartExpiry.Retention, cfg.MetricPrefix)
+		workers = append(workers, worker.New("cart-expirer", cfg.CartExpiry.Interval, cfg.Logger, expirer.run))
+	}
+
+	var svc Service = base
+
 	svc = NewLoggingMiddleware(svc, cfg.Logger)
 	svc = NewInstrumentingMiddleware(svc, cfg.MetricPrefix+"_api")
 
@@ -51,22 +105,30 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 	router.Handle("/api/v1/", makeHandler(svc))
 
 	srv := &http.Server{
-		Handler:      handlers.CORS(handlers.AllowedOrigins(cfg.AllowedOrigins))(router),
+		Handler: handlers.CORS(
+			handlers.AllowedOrigins(cfg.AllowedOrigins),
+			handlers.AllowedHeaders([]string{"If-Match"}),
+			handlers.ExposedHeaders([]string{"ETag"}),
+		)(router),
 		Addr:         ":" + cfg.Port,
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 	}
 
 	s := &Server{
-		cfg: &cfg,
-		srv: srv,
+		cfg:     &cfg,
+		srv:     srv,
+		workers: workers,
 	}
 
 	return s, nil
 }
 
-// Serve starts the HTTP server.
+// Serve starts the background workers and the HTTP server.
 func (s *Server) Serve() error {
+	for _, w := range s.workers {
+		go w.Run()
+	}
 	if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
 		return err
 	}
@@ -74,13 +136,16 @@ func (s *Server) Serve() error {
 	return nil
 }
 
-// Shutdown stops the HTTP server.
+// Shutdown stops the HTTP server and the background workers.
 func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
 	defer cancel()
 	if err := s.srv.Shutdown(ctx); err != nil {
 		return err
 	}
+	for _, w := range s.workers {
+		w.Stop()
+	}
 	return nil
 }
 
@@ -91,12 +156,12 @@ func makeHandler(svc Service) http.Handler {
 
 	router := mux.NewRouter()
 
-	//router.Path("/api/v1/card/summary").Methods(http.MethodGet).Handler(kithttp.NewServer(
-	//	makeGetHeaderCartInfo(svc),
-	//	decodeGetHeaderCartInfoRequest,
-	//	encodeGetHeaderCartInfoResponse,
-	//	opts...,
-	//))
+	router.Path("/api/v1/card/summary").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetHeaderCartInfo(svc),
+		decodeGetHeaderCartInfoRequest,
+		encodeGetHeaderCartInfoResponse,
+		opts...,
+	))
 
 	router.Path("/api/v1/card").Methods(http.MethodGet).Handler(kithttp.NewServer(
 		makeGetCart(svc),
@@ -119,6 +184,13 @@ func makeHandler(svc Service) http.Handler {
 		opts...,
 	))
 
+	router.Path("/api/v1/card/quantity").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeSetProductQty(svc),
+		decodeSetProductQtyRequest,
+		encodeSetProductQtyResponse,
+		opts...,
+	))
+
 	router.Path("/api/v1/card/product").Methods(http.MethodPost).Handler(kithttp.NewServer(
 		makeAddProductEndpoint(svc),
 		decodeAddProductRequest,
@@ -133,12 +205,103 @@ func makeHandler(svc Service) http.Handler {
 		opts...,
 	))
 
-	//router.Path("/api/v1/order").Methods(http.MethodPost).Handler(kithttp.NewServer(
-	//	makeAddOrderEndpoint(svc),
-	//	decodeAddOrderRequest,
-	//	encodeAddOrderResponse,
-	//	opts...,
-	//))
+	router.Path("/api/v1/card/coupon").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeApplyCouponEndpoint(svc),
+		decodeApplyCouponRequest,
+		encodeApplyCouponResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/card/coupon").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeRemoveCouponEndpoint(svc),
+		decodeRemoveCouponRequest,
+		encodeRemoveCouponResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/card/merge").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeMergeCartsEndpoint(svc),
+		decodeMergeCartsRequest,
+		encodeMergeCartsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/wishlist").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetWishlistEndpoint(svc),
+		decodeGetWishlistRequest,
+		encodeGetWishlistResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/wishlist/shared/{token}").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetSharedWishlistEndpoint(svc),
+		decodeGetSharedWishlistRequest,
+		encodeGetWishlistResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/wishlist/product").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeAddToWishlistEndpoint(svc),
+		decodeAddToWishlistRequest,
+		encodeAddToWishlistResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/wishlist/product").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeRemoveFromWishlistEndpoint(svc),
+		decodeWishlistProductRequest,
+		encodeWishlistProductResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/wishlist/move").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeMoveToCartEndpoint(svc),
+		decodeWishlistProductRequest,
+		encodeWishlistProductResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/wishlist/share").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeShareWishlistEndpoint(svc),
+		decodeShareWishlistRequest,
+		encodeShareWishlistResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/card/delivery").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeDeliveryOptionsEndpoint(svc),
+		decodeDeliveryOptionsRequest,
+		encodeDeliveryOptionsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/order").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeCheckoutEndpoint(svc),
+		decodeCheckoutRequest,
+		encodeCheckoutResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/orders").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetOrdersEndpoint(svc),
+		decodeGetOrdersRequest,
+		encodeGetOrdersResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/orders/{id}").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetOrderEndpoint(svc),
+		decodeGetOrderRequest,
+		encodeGetOrderResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/payment/webhook").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makePaymentEventEndpoint(svc),
+		decodePaymentEventRequest,
+		encodePaymentEventResponse,
+		opts...,
+	))
 
 	return router
 }
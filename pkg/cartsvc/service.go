@@ -2,35 +2,94 @@ package erpsvc
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"github.com/anabiozz/core/lapkins/pkg/pricing"
+	"github.com/anabiozz/core/lapkins/pkg/shipping"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 type Storage interface {
 	AddProductToCard(ctx context.Context, sku string, userID string, isLoggedIn bool, isTmpUserIDSet bool) (bool, string, error)
-	IncreaseProductQuantity(ctx context.Context, userID string, sku string) error
-	DecreaseProductQuantity(ctx context.Context, userID string, sku string) error
+	SetProductQuantity(ctx context.Context, userID string, sku string, qty int, version int64) error
 	RemoveProduct(ctx context.Context, userID string, sku string) error
-	LoadCart(ctx context.Context, userID string) ([]*erp.CartProduct, error)
-	AddOrder(ctx context.Context, order *erp.Order) error
+	LoadCart(ctx context.Context, userID string) (*erp.Cart, error)
+	CheckoutCart(ctx context.Context, userID string, order *erp.Order) error
+	GetOrder(ctx context.Context, id string) (*erp.Order, error)
+	UpdateOrderStatus(ctx context.Context, id string, change *erp.OrderStatusChange) error
+	UpdateOrderPayment(ctx context.Context, id string, payment *erp.Payment) error
+	GetInventory(ctx context.Context, sku string) (*erp.Inventory, error)
+	ReserveStock(ctx context.Context, id string, ownerID string, quantities map[string]int, expiresOn time.Time) error
+	CommitReservation(ctx context.Context, id string) error
+	ReleaseReservation(ctx context.Context, id string) error
+	ReleaseExpiredReservations(ctx context.Context, now time.Time) (int, error)
+	ExpireCarts(ctx context.Context, before time.Time) ([]*erp.Cart, error)
+	PurgeExpiredCarts(ctx context.Context, before time.Time) (int64, error)
+	GetPrices(ctx context.Context, skus []string) (map[string]*erp.Pricing, error)
+	GetPriceList(ctx context.Context, id string) (*erp.PriceList, error)
+	GetPriceListPrices(ctx context.Context, id string, skus []string) (map[string]*erp.Pricing, error)
+	GetVariationCategories(ctx context.Context, skus []string) (map[string][]string, error)
+	GetUser(ctx context.Context, id string) (*erp.User, error)
+	GetCoupon(ctx context.Context, code string) (*erp.Coupon, error)
+	SetCartCoupon(ctx context.Context, userID string, code string) error
+	MergeCarts(ctx context.Context, tmpUserID string, userID string) error
+	GetWishlist(ctx context.Context, userID string) (*erp.Wishlist, error)
+	GetWishlistByShareToken(ctx context.Context, token string) (*erp.Wishlist, error)
+	AddToWishlist(ctx context.Context, userID string, sku string) error
+	RemoveFromWishlist(ctx context.Context, userID string, sku string) error
+	SetWishlistShareToken(ctx context.Context, userID string, token string) (string, error)
+	MergeWishlists(ctx context.Context, tmpUserID string, userID string) error
+	GetShippingDetails(ctx context.Context, skus []string) (map[string]*erp.Shipping, error)
+	GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error)
+	GetAddress(ctx context.Context, userID string, id string) (*erp.Address, error)
+	GetUserOrders(ctx context.Context, userID string, status erp.OrderStatus, page *erp.PageRequest) (*erp.OrderPage, error)
 }
 
 type Service interface {
 	AddProductToCard(ctx context.Context, sku string, userID string, isLoggedIn bool, isTmpUserIDSet bool) (bool, string, error)
 	DecreaseProductQuantity(ctx context.Context, userID string, sku string) error
 	IncreaseProductQuantity(ctx context.Context, userID string, sku string) error
-	LoadCart(ctx context.Context, userID string) ([]*erp.CartProduct, error)
+	SetProductQuantity(ctx context.Context, userID string, sku string, qty int, version int64, market erp.Market) (*erp.PricedCart, error)
+	LoadCart(ctx context.Context, userID string, market erp.Market) (*erp.PricedCart, error)
+	GetHeaderCartInfo(ctx context.Context, userID string, market erp.Market) (*erp.HeaderCartInfo, error)
 	RemoveProduct(ctx context.Context, userID string, sku string) error
+	ApplyCoupon(ctx context.Context, userID string, code string, market erp.Market) (*erp.PricedCart, error)
+	RemoveCoupon(ctx context.Context, userID string) error
+	MergeCarts(ctx context.Context, tmpUserID string, userID string) error
+	GetWishlist(ctx context.Context, userID string, market erp.Market) (*erp.Wishlist, error)
+	GetSharedWishlist(ctx context.Context, token string, market erp.Market) (*erp.Wishlist, error)
+	AddToWishlist(ctx context.Context, sku string, userID string) (bool, string, error)
+	RemoveFromWishlist(ctx context.Context, userID string, sku string) error
+	MoveToCart(ctx context.Context, userID string, sku string, isLoggedIn bool) error
+	ShareWishlist(ctx context.Context, userID string) (string, error)
+	GetDeliveryOptions(ctx context.Context, userID string, destination *erp.ShippingDestination, market erp.Market) (*erp.DeliveryQuote, error)
+	Checkout(ctx context.Context, userID string, input *erp.CheckoutInput, market erp.Market) (*erp.Order, error)
+	HandlePaymentEvent(ctx context.Context, payload []byte, signature string) error
+	GetOrders(ctx context.Context, userID string, status erp.OrderStatus, page *erp.PageRequest) (*erp.CustomerOrderPage, error)
+	GetOrder(ctx context.Context, userID string, id string) (*erp.CustomerOrder, error)
 }
 
 type service struct {
-	logger  log.Logger
-	storage Storage
+	logger         log.Logger
+	storage        Storage
+	payments       erp.PaymentProvider
+	pricing        *pricing.Engine
+	shipping       *shipping.Calculator
+	paymentTimeout time.Duration
+	reservationTTL time.Duration
 }
 
 type ServiceConfig struct {
-	Logger  log.Logger
-	Storage Storage
+	Logger         log.Logger
+	Storage        Storage
+	Payments       erp.PaymentProvider
+	Carriers       []erp.Carrier
+	PaymentTimeout time.Duration
+	ReservationTTL time.Duration
 }
 
 func newService(cfg *ServiceConfig) (*service, error) {
@@ -39,15 +98,34 @@ func newService(cfg *ServiceConfig) (*service, error) {
 		logger = log.NewNopLogger()
 	}
 
+	paymentTimeout := cfg.PaymentTimeout
+	if paymentTimeout == 0 {
+		paymentTimeout = 3 * time.Second
+	}
+
+	reservationTTL := cfg.ReservationTTL
+	if reservationTTL == 0 {
+		reservationTTL = 15 * time.Minute
+	}
+
 	svc := &service{
-		logger:  logger,
-		storage: cfg.Storage,
+		logger:         logger,
+		storage:        cfg.Storage,
+		payments:       cfg.Payments,
+		pricing:        pricing.NewEngine(cfg.Storage),
+		shipping:       shipping.NewCalculator(cfg.Carriers),
+		paymentTimeout: paymentTimeout,
+		reservationTTL: reservationTTL,
 	}
 
 	return svc, nil
 }
 
 func (s *service) AddProductToCard(ctx context.Context, sku string, userID string, isLoggedIn bool, isTmpUserIDSet bool) (bool, string, error) {
+	err := s.checkStock(ctx, userID, sku, 1)
+	if err != nil {
+		return false, "", err
+	}
 	setTmpUserIDCookie, userID, err := s.storage.AddProductToCard(ctx, sku, userID, isLoggedIn, isTmpUserIDSet)
 	if err != nil {
 		return false, "", erp.ErrBadRequest("%s", err)
@@ -55,32 +133,78 @@ func (s *service) AddProductToCard(ctx context.Context, sku string, userID strin
 	return setTmpUserIDCookie, userID, nil
 }
 
+// DecreaseProductQuantity takes one item of the SKU out of the cart,
+// the last one removes the product from the cart.
 func (s *service) DecreaseProductQuantity(ctx context.Context, userID string, sku string) error {
-	err := s.storage.DecreaseProductQuantity(ctx, userID, sku)
-	if err != nil {
-		return erp.ErrBadRequest("%s", err)
-	}
-	return nil
+	return s.stepProductQuantity(ctx, userID, sku, -1)
 }
 
 func (s *service) IncreaseProductQuantity(ctx context.Context, userID string, sku string) error {
-	err := s.storage.IncreaseProductQuantity(ctx, userID, sku)
+	return s.stepProductQuantity(ctx, userID, sku, 1)
+}
+
+// SetProductQuantity sets the quantity of the SKU in the cart, zero removes the product.
+// The change is made only if the cart still has the given version, so clients working
+// on a stale cart get an error instead of overwriting newer changes.
+// erp.AnyCartVersion skips the check.
+func (s *service) SetProductQuantity(ctx context.Context, userID string, sku string, qty int, version int64, market erp.Market) (*erp.PricedCart, error) {
+	if userID == "" {
+		return nil, erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	if sku == "" {
+		return nil, erp.ErrBadRequest("%s", "sku is empty")
+	}
+	if qty < 0 {
+		return nil, erp.ErrBadRequest("%s", "quantity can't be negative")
+	}
+
+	err := s.setProductQuantity(ctx, userID, sku, qty, version)
 	if err != nil {
-		return erp.ErrBadRequest("%s", err)
+		if err == erp.ErrModifiedInStorage {
+			return nil, erp.ErrPreconditionFailed("%s", "cart was changed, reload it and try again")
+		}
+		return nil, err
 	}
-	return nil
+	return s.LoadCart(ctx, userID, market)
 }
 
-func (s *service) LoadCart(ctx context.Context, userID string) ([]*erp.CartProduct, error) {
+// LoadCart returns the active cart of the user priced with the current prices of the market price list.
+// Without a market selected by the request the market of the user profile is used.
+func (s *service) LoadCart(ctx context.Context, userID string, market erp.Market) (*erp.PricedCart, error) {
 	if userID == "" {
 		return nil, erp.ErrBadRequest("%s", "provided user id is empty")
 	}
 	cart, err := s.storage.LoadCart(ctx, userID)
 	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return nil, erp.ErrNotFound("%s", "active cart not found")
+		}
 		return nil, err
 	}
-	return cart, nil
+	return s.priceCart(ctx, userID, cart, market)
+}
 
+// GetHeaderCartInfo returns the number of items in the cart and their total price.
+// The price is calculated from the current catalog prices, not from the cart snapshot.
+func (s *service) GetHeaderCartInfo(ctx context.Context, userID string, market erp.Market) (*erp.HeaderCartInfo, error) {
+	info := &erp.HeaderCartInfo{}
+	if userID == "" {
+		return info, nil
+	}
+	cart, err := s.storage.LoadCart(ctx, userID)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return info, nil
+		}
+		return nil, err
+	}
+	priced, err := s.priceCart(ctx, userID, cart, market)
+	if err != nil {
+		return nil, err
+	}
+	info.Quantity = priced.Quantity
+	info.Price = priced.Total
+	return info, nil
 }
 
 func (s *service) RemoveProduct(ctx context.Context, userID string, sku string) error {
@@ -94,10 +218,770 @@ func (s *service) RemoveProduct(ctx context.Context, userID string, sku string)
 	return nil
 }
 
-func (s *service) AddOrder(ctx context.Context, order *erp.Order) error {
-	err := s.storage.AddOrder(ctx, order)
+// ApplyCoupon applies the promo code to the active cart of the user
+// and returns the cart priced with it. A previously applied code is replaced.
+func (s *service) ApplyCoupon(ctx context.Context, userID string, code string, market erp.Market) (*erp.PricedCart, error) {
+	if userID == "" {
+		return nil, erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	code = erp.NormalizeCouponCode(code)
+	if code == "" {
+		return nil, erp.ErrBadRequest("%s", "coupon code is empty")
+	}
+
+	cart, err := s.storage.LoadCart(ctx, userID)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return nil, erp.ErrNotFound("%s", "active cart not found")
+		}
+		return nil, err
+	}
+	cart.Coupon = ""
+	priced, err := s.priceCart(ctx, userID, cart, market)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		return nil, err
+	}
+	err = s.applyCoupon(ctx, user, userID, priced, code)
+	if err != nil {
+		return nil, couponError(code, err)
+	}
+
+	err = s.storage.SetCartCoupon(ctx, userID, code)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return nil, erp.ErrNotFound("%s", "active cart not found")
+		}
+		return nil, err
+	}
+	return priced, nil
+}
+
+func (s *service) RemoveCoupon(ctx context.Context, userID string) error {
+	if userID == "" {
+		return erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	err := s.storage.SetCartCoupon(ctx, userID, "")
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return erp.ErrNotFound("%s", "active cart not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// MergeCarts moves the cart and the wishlist an anonymous user has collected
+// into the ones of the user after login. Merging is idempotent, merged carts
+// and wishlists are gone, so retries are no-ops.
+func (s *service) MergeCarts(ctx context.Context, tmpUserID string, userID string) error {
+	if tmpUserID == "" || userID == "" {
+		return erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	if tmpUserID == userID {
+		return nil
+	}
+	err := s.storage.MergeCarts(ctx, tmpUserID, userID)
+	switch err {
+	case nil, erp.ErrNotFoundInStorage:
+	case erp.ErrModifiedInStorage:
+		return erp.ErrConflict("%s", "cart was changed during merge, try again")
+	default:
+		return err
+	}
+	err = s.storage.MergeWishlists(ctx, tmpUserID, userID)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		return err
+	}
+	return nil
+}
+
+// GetWishlist returns the wishlist of the user with the current prices of the market price list.
+// A user who saved nothing has an empty wishlist.
+func (s *service) GetWishlist(ctx context.Context, userID string, market erp.Market) (*erp.Wishlist, error) {
+	if userID == "" {
+		return &erp.Wishlist{}, nil
+	}
+	wishlist, err := s.storage.GetWishlist(ctx, userID)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return &erp.Wishlist{}, nil
+		}
+		return nil, err
+	}
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		return nil, err
+	}
+	list, err := s.priceList(ctx, user, market)
+	if err != nil {
+		return nil, err
+	}
+	err = s.priceWishlist(ctx, wishlist, list)
+	if err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// GetSharedWishlist returns the wishlist the share token was issued for
+// priced for the market of the viewer.
+func (s *service) GetSharedWishlist(ctx context.Context, token string, market erp.Market) (*erp.Wishlist, error) {
+	if token == "" {
+		return nil, erp.ErrBadRequest("%s", "share token is empty")
+	}
+	wishlist, err := s.storage.GetWishlistByShareToken(ctx, token)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return nil, erp.ErrNotFound("%s", "wishlist not found")
+		}
+		return nil, err
+	}
+	wishlist.ShareToken = ""
+	list, err := s.pricing.PriceList(ctx, market)
+	if err != nil {
+		return nil, err
+	}
+	err = s.priceWishlist(ctx, wishlist, list)
+	if err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// AddToWishlist saves the SKU for later. Anonymous users get a tmp user id
+// the same way they do when adding a product to the cart.
+func (s *service) AddToWishlist(ctx context.Context, sku string, userID string) (bool, string, error) {
+	if sku == "" {
+		return false, "", erp.ErrBadRequest("%s", "sku is empty")
+	}
+	prices, err := s.storage.GetPrices(ctx, []string{sku})
+	if err != nil {
+		return false, "", err
+	}
+	if _, ok := prices[sku]; !ok {
+		return false, "", erp.ErrNotFound("product %s not found", sku)
+	}
+
+	var setTmpUserIDCookie bool
+	if userID == "" {
+		userID = primitive.NewObjectID().Hex()
+		setTmpUserIDCookie = true
+	}
+	err = s.storage.AddToWishlist(ctx, userID, sku)
+	if err != nil {
+		return false, "", err
+	}
+	return setTmpUserIDCookie, userID, nil
+}
+
+func (s *service) RemoveFromWishlist(ctx context.Context, userID string, sku string) error {
+	if userID == "" {
+		return erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	err := s.storage.RemoveFromWishlist(ctx, userID, sku)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return erp.ErrNotFound("product %s is not in the wishlist", sku)
+		}
+		return err
+	}
+	return nil
+}
+
+// MoveToCart puts the SKU from the wishlist into the cart.
+func (s *service) MoveToCart(ctx context.Context, userID string, sku string, isLoggedIn bool) error {
+	if userID == "" {
+		return erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	wishlist, err := s.storage.GetWishlist(ctx, userID)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		return err
+	}
+	if !wishlist.Contains(sku) {
+		return erp.ErrNotFound("product %s is not in the wishlist", sku)
+	}
+
+	_, _, err = s.AddProductToCard(ctx, sku, userID, isLoggedIn, true)
+	if err != nil {
+		return err
+	}
+	err = s.storage.RemoveFromWishlist(ctx, userID, sku)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		return err
+	}
+	return nil
+}
+
+// ShareWishlist returns a token for the read-only link to the wishlist of the user.
+// The token stays the same once issued.
+func (s *service) ShareWishlist(ctx context.Context, userID string) (string, error) {
+	if userID == "" {
+		return "", erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	token, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+	token, err = s.storage.SetWishlistShareToken(ctx, userID, token)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return "", erp.ErrNotFound("%s", "wishlist not found")
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// GetDeliveryOptions returns the delivery options of the active cart to the destination
+// with their prices in the cart currency and the delivery estimates.
+func (s *service) GetDeliveryOptions(ctx context.Context, userID string, destination *erp.ShippingDestination, market erp.Market) (*erp.DeliveryQuote, error) {
+	if userID == "" {
+		return nil, erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	destination.Normalize()
+	if err := destination.Validate(); err != nil {
+		return nil, err
+	}
+	cart, err := s.LoadCart(ctx, userID, market)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Products) == 0 {
+		return nil, erp.ErrBadRequest("%s", "cart is empty")
+	}
+	if err := s.locateDestination(ctx, destination, false); err != nil {
+		return nil, err
+	}
+	return s.quoteDelivery(ctx, cart, destination)
+}
+
+// locateDestination sets the delivery zone of the destination found by its location,
+// destinations outside of all the zones are rejected. Without zones set up nothing is checked.
+// Required tells whether a destination without a location is rejected.
+func (s *service) locateDestination(ctx context.Context, destination *erp.ShippingDestination, required bool) error {
+	zones, err := s.storage.GetDeliveryZones(ctx)
+	if err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+	var v erp.Validation
+	if destination == nil || destination.Location == nil {
+		if required {
+			v.Add("destination.location", "is required to check the delivery zone")
+		}
+		return v.Err()
+	}
+	zone := erp.FindDeliveryZone(zones, *destination.Location)
+	if zone == nil {
+		v.Add("destination.location", "%s", erp.ErrOutsideDeliveryZones)
+		return v.Err()
+	}
+	destination.Zone = zone.ID
+	return nil
+}
+
+// checkAddressLocation rejects saved addresses without a location once delivery zones are set up,
+// addresses saved before the zones have to be updated with one.
+func (s *service) checkAddressLocation(ctx context.Context, address *erp.Address) error {
+	if address.Location != nil {
+		return nil
+	}
+	zones, err := s.storage.GetDeliveryZones(ctx)
+	if err != nil {
+		return err
+	}
+	if len(zones) > 0 {
+		return erp.ErrConflict("address %s has no location to check the delivery zone, update the address", address.ID)
+	}
+	return nil
+}
+
+// quoteDelivery packs the priced cart into a parcel and quotes its delivery.
+func (s *service) quoteDelivery(ctx context.Context, cart *erp.PricedCart, destination *erp.ShippingDestination) (*erp.DeliveryQuote, error) {
+	skus := make([]string, 0, len(cart.Products))
+	for _, p := range cart.Products {
+		skus = append(skus, p.SKU)
+	}
+	details, err := s.storage.GetShippingDetails(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	parcel, err := erp.NewParcel(cart.Products, details)
+	if err != nil {
+		return nil, erp.ErrConflict("%s", err)
+	}
+	return s.shipping.Quote(ctx, cart, parcel, destination)
+}
+
+// Checkout turns the active cart of the user into an order in the currency of the market price list.
+// The order keeps the exchange rate the cart was priced at and a copy of the saved address it is shipped to.
+func (s *service) Checkout(ctx context.Context, userID string, input *erp.CheckoutInput, market erp.Market) (*erp.Order, error) {
+	if userID == "" {
+		return nil, erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	err := input.Validate()
+	if err != nil {
+		return nil, erp.ErrBadRequest("validation error: %v", err)
+	}
+	if input.AddressID != "" {
+		address, err := s.storage.GetAddress(ctx, userID, input.AddressID)
+		if err != nil {
+			if err == erp.ErrAddressNotFound {
+				return nil, erp.ErrNotFound("address %s not found", input.AddressID)
+			}
+			return nil, err
+		}
+		input.UseAddress(address)
+		if err := s.checkAddressLocation(ctx, address); err != nil {
+			return nil, err
+		}
+	}
+
+	cart, err := s.LoadCart(ctx, userID, market)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Products) == 0 {
+		return nil, erp.ErrBadRequest("%s", "cart is empty")
+	}
+	if len(cart.Unpriced) > 0 {
+		return nil, erp.ErrConflict("products %v are not on sale", cart.Unpriced)
+	}
+	if cart.CouponError != "" {
+		return nil, erp.ErrConflict("coupon can't be used: %s", cart.CouponError)
+	}
+	if err := s.locateDestination(ctx, input.Destination, true); err != nil {
+		return nil, err
+	}
+
+	order := erp.NewOrder(primitive.NewObjectID().Hex(), userID, cart, input, time.Now())
+	order.Payment.Provider = s.payments.Name()
+	_, err = s.storage.GetUser(ctx, userID)
+	switch err {
+	case nil:
+	case erp.ErrNotFoundInStorage:
+		order.Anonymous = true
+	default:
+		return nil, err
+	}
+	if input.Delivery != "" {
+		quote, err := s.quoteDelivery(ctx, cart, input.Destination)
+		if err != nil {
+			return nil, err
+		}
+		option, ok := quote.Option(input.Delivery)
+		if !ok {
+			return nil, erp.ErrConflict("%s: %s", erp.ErrUnknownDeliveryOption, input.Delivery)
+		}
+		if err := order.SetDelivery(input.Destination, quote.Parcel, option); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.reserveStock(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.TotalPrice.IsPositive() {
+		result, err := s.authorizePayment(ctx, order, input.PaymentToken)
+		switch err {
+		case nil:
+			order.Payment.Status = result.Status
+			order.Payment.TransactionID = result.TransactionID
+		case erp.ErrPaymentTimeout:
+			// The order waits for the provider to report the outcome with a webhook.
+		case erp.ErrPaymentDeclined:
+			s.releaseReservation(ctx, order)
+			return nil, erp.ErrPaymentRequired("%s", err)
+		default:
+			s.releaseReservation(ctx, order)
+			return nil, err
+		}
+	} else {
+		// Nothing to charge, the order is paid without going to the provider.
+		order.Payment.Status = erp.PaymentStatusCaptured
+	}
+
+	err = s.storage.CheckoutCart(ctx, userID, order)
+	if err != nil {
+		s.releasePayment(ctx, order)
+		s.releaseReservation(ctx, order)
+		switch err {
+		case erp.ErrNotFoundInStorage:
+			return nil, erp.ErrNotFound("%s", "active cart not found")
+		case erp.ErrModifiedInStorage:
+			return nil, erp.ErrConflict("%s", "cart was changed during checkout, try again")
+		case erp.ErrCouponExhausted:
+			return nil, erp.ErrConflict("coupon can't be used: %s", err)
+		}
+		return nil, err
+	}
+
+	err = s.settlePayment(ctx, order)
+	if err != nil {
+		// Order is stored and money is held, it is settled by the next webhook.
+		level.Error(s.logger).Log("msg", "failed to settle payment", "order", order.ID, "err", err)
+	}
+	return order, nil
+}
+
+// GetOrders returns a page of the orders of the user in the given status, of all of them if status is empty.
+func (s *service) GetOrders(ctx context.Context, userID string, status erp.OrderStatus, page *erp.PageRequest) (*erp.CustomerOrderPage, error) {
+	if status != "" && !status.IsValid() {
+		return nil, erp.ErrBadRequest("invalid order status %q", status)
+	}
+	orders, err := s.storage.GetUserOrders(ctx, userID, status, page)
+	if err != nil {
+		if err == erp.ErrInvalidCursor {
+			return nil, erp.ErrBadRequest("%s", err)
+		}
+		return nil, err
+	}
+	result := &erp.CustomerOrderPage{Orders: make([]*erp.CustomerOrder, 0, len(orders.Orders)), Page: orders.Page}
+	for _, o := range orders.Orders {
+		result.Orders = append(result.Orders, o.Customer())
+	}
+	return result, nil
+}
+
+// GetOrder returns an order of the user. Orders of other users are not found,
+// so their ids can't be told apart from unknown ones.
+func (s *service) GetOrder(ctx context.Context, userID string, id string) (*erp.CustomerOrder, error) {
+	order, err := s.storage.GetOrder(ctx, id)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return nil, erp.ErrNotFound("order %s not found", id)
+		}
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, erp.ErrNotFound("order %s not found", id)
+	}
+	return order.Customer(), nil
+}
+
+// HandlePaymentEvent applies an asynchronous notification from the payment provider.
+// Providers retry webhooks, so events for already settled orders are ignored.
+func (s *service) HandlePaymentEvent(ctx context.Context, payload []byte, signature string) error {
+	event, err := s.payments.VerifyWebhook(payload, signature)
+	if err != nil {
+		return erp.ErrBadRequest("%s", err)
+	}
+
+	order, err := s.storage.GetOrder(ctx, event.OrderID)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return erp.ErrNotFound("order %s not found", event.OrderID)
+		}
+		return err
+	}
+	if order.Status != erp.OrderStatusPendingPayment {
+		return nil
+	}
+
+	order.Payment.TransactionID = event.TransactionID
+	order.Payment.Status = event.Status
+	order.Payment.ModifiedOn = time.Now()
+
+	if event.Status == erp.PaymentStatusDeclined {
+		err = s.storage.UpdateOrderPayment(ctx, order.ID, order.Payment)
+		if err != nil {
+			return err
+		}
+		s.releaseReservation(ctx, order)
+		return s.changeOrderStatus(ctx, order, erp.OrderStatusCancelled, "payment declined")
+	}
+	return s.settlePayment(ctx, order)
+}
+
+// priceCart prices the cart lines in the market price list with the discount of the user if there is one
+// and with the coupon applied to the cart.
+// Anonymous users have no account and get no discount.
+// A coupon that does not apply anymore is reported in PricedCart.CouponError.
+func (s *service) priceCart(ctx context.Context, userID string, cart *erp.Cart, market erp.Market) (*erp.PricedCart, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		return nil, err
+	}
+	list, err := s.priceList(ctx, user, market)
+	if err != nil {
+		return nil, err
+	}
+	priced, err := s.pricing.Price(ctx, cart.Products, user, list)
+	if err != nil {
+		return nil, err
+	}
+	// The version is sent back as the ETag, updates with a stale one are rejected.
+	priced.Version = cart.Version
+	if cart.Coupon == "" {
+		return priced, nil
+	}
+
+	err = s.applyCoupon(ctx, user, userID, priced, cart.Coupon)
+	switch err {
+	case nil:
+	case erp.ErrNotFoundInStorage:
+		priced.CouponError = erp.ErrCouponNotActive.Error()
+	case erp.ErrCouponNotActive, erp.ErrCouponExhausted, erp.ErrCouponMinCartValue, erp.ErrCouponNotApplicable,
+		erp.ErrCouponLoginRequired:
+		priced.CouponError = err.Error()
+	default:
+		return nil, err
+	}
+	return priced, nil
+}
+
+// applyCoupon checks the coupon for the user and applies it to the cart.
+// The user is nil for anonymous users.
+func (s *service) applyCoupon(ctx context.Context, user *erp.User, userID string, cart *erp.PricedCart, code string) error {
+	coupon, err := s.storage.GetCoupon(ctx, code)
+	if err != nil {
+		return err
+	}
+	err = coupon.Check(userID, user != nil, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.pricing.ApplyCoupon(ctx, cart, coupon)
+}
+
+// couponError converts errors of applying a coupon to service errors.
+func couponError(code string, err error) error {
+	switch err {
+	case erp.ErrNotFoundInStorage:
+		return erp.ErrNotFound("coupon %s not found", code)
+	case erp.ErrCouponExhausted:
+		return erp.ErrConflict("%s", err)
+	case erp.ErrCouponLoginRequired:
+		return erp.ErrUnauthorized("%s", err)
+	case erp.ErrCouponNotActive, erp.ErrCouponMinCartValue, erp.ErrCouponNotApplicable:
+		return erp.ErrBadRequest("%s", err)
+	}
+	return err
+}
+
+// priceList returns the price list of the market selected by the request,
+// or of the market of the user profile when the request selects none.
+// The user may be nil for anonymous users.
+func (s *service) priceList(ctx context.Context, user *erp.User, market erp.Market) (*erp.PriceList, error) {
+	if market.IsZero() {
+		market = user.Market()
+	}
+	return s.pricing.PriceList(ctx, market)
+}
+
+func (s *service) priceWishlist(ctx context.Context, wishlist *erp.Wishlist, list *erp.PriceList) error {
+	if len(wishlist.Products) == 0 {
+		return nil
+	}
+	skus := make([]string, 0, len(wishlist.Products))
+	for _, p := range wishlist.Products {
+		skus = append(skus, p.SKU)
+	}
+	prices, err := s.pricing.Prices(ctx, skus, list)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, p := range wishlist.Products {
+		if pricing, ok := prices[p.SKU]; ok {
+			p.Price = pricing.Current(now)
+		}
+	}
+	return nil
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *service) authorizePayment(ctx context.Context, order *erp.Order, token string) (*erp.PaymentResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.paymentTimeout)
+	defer cancel()
+	return s.payments.Authorize(ctx, &erp.PaymentRequest{
+		OrderID: order.ID,
+		Amount:  order.TotalPrice,
+		Method:  order.Payment.Method,
+		Token:   token,
+	})
+}
+
+// settlePayment captures an authorized payment and marks the order as paid.
+func (s *service) settlePayment(ctx context.Context, order *erp.Order) error {
+	if order.Payment.Status == erp.PaymentStatusAuthorized {
+		ctx, cancel := context.WithTimeout(ctx, s.paymentTimeout)
+		result, err := s.payments.Capture(ctx, order.Payment.TransactionID, order.Payment.Amount)
+		cancel()
+		if err != nil {
+			return err
+		}
+		order.Payment.Status = result.Status
+		order.Payment.ModifiedOn = time.Now()
+	}
+	if order.Payment.Status != erp.PaymentStatusCaptured {
+		return nil
+	}
+
+	err := s.storage.UpdateOrderPayment(ctx, order.ID, order.Payment)
+	if err != nil {
+		return err
+	}
+	err = s.changeOrderStatus(ctx, order, erp.OrderStatusPaid, "")
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.CommitReservation(ctx, order.ID)
+	if err != nil {
+		// Reservation has expired before the payment, the stock has to be checked by hand.
+		level.Error(s.logger).Log("msg", "failed to commit reservation", "order", order.ID, "err", err)
+	}
+	return nil
+}
+
+// releasePayment gives back money held for an order that was not stored.
+func (s *service) releasePayment(ctx context.Context, order *erp.Order) {
+	if order.Payment.TransactionID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.paymentTimeout)
+	defer cancel()
+	_, err := s.payments.Refund(ctx, order.Payment.TransactionID, order.Payment.Amount)
+	if err != nil {
+		level.Error(s.logger).Log("msg", "failed to release payment", "transaction", order.Payment.TransactionID, "err", err)
+	}
+}
+
+// stepProductQuantity changes the quantity of the SKU in the cart by step.
+func (s *service) stepProductQuantity(ctx context.Context, userID string, sku string, step int) error {
+	if userID == "" {
+		return erp.ErrBadRequest("%s", "provided user id is empty")
+	}
+	cart, err := s.storage.LoadCart(ctx, userID)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return erp.ErrNotFound("%s", "active cart not found")
+		}
+		return err
+	}
+	for _, p := range cart.Products {
+		if p.SKU != sku {
+			continue
+		}
+		err = s.setProductQuantity(ctx, userID, sku, p.Quantity+step, cart.Version)
+		if err == erp.ErrModifiedInStorage {
+			return erp.ErrConflict("%s", "cart was changed concurrently, try again")
+		}
+		return err
+	}
+	return erp.ErrNotFound("product %s is not in the cart", sku)
+}
+
+func (s *service) setProductQuantity(ctx context.Context, userID string, sku string, qty int, version int64) error {
+	if qty > 0 {
+		err := s.checkAvailable(ctx, sku, qty)
+		if err != nil {
+			return err
+		}
+	}
+	err := s.storage.SetProductQuantity(ctx, userID, sku, qty, version)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return erp.ErrNotFound("product %s is not in the cart", sku)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkStock makes sure the cart of the user can take qty more items of the SKU.
+func (s *service) checkStock(ctx context.Context, userID string, sku string, qty int) error {
+	var inCart int
+	if userID != "" {
+		cart, err := s.storage.LoadCart(ctx, userID)
+		switch err {
+		case nil:
+			for _, p := range cart.Products {
+				if p.SKU == sku {
+					inCart += p.Quantity
+				}
+			}
+		case erp.ErrNotFoundInStorage:
+		default:
+			return err
+		}
+	}
+
+	return s.checkAvailable(ctx, sku, inCart+qty)
+}
+
+// checkAvailable makes sure qty items of the SKU are in stock.
+// SKUs without an inventory are not in stock.
+func (s *service) checkAvailable(ctx context.Context, sku string, qty int) error {
+	inventory, err := s.storage.GetInventory(ctx, sku)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		return err
+	}
+	if available := inventory.Available(); qty > available {
+		return erp.ErrConflict("only %d items of %s are available", available, sku)
+	}
+	return nil
+}
+
+// reserveStock holds the ordered quantities until the order is paid.
+func (s *service) reserveStock(ctx context.Context, order *erp.Order) error {
+	quantities := make(map[string]int, len(order.Products))
+	for _, p := range order.Products {
+		quantities[p.SKU] += p.Quantity
+	}
+	err := s.storage.ReserveStock(ctx, order.ID, order.UserID, quantities, time.Now().Add(s.reservationTTL))
+	if err != nil {
+		if err == erp.ErrInsufficientStock {
+			return erp.ErrConflict("%s", "some products are out of stock")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *service) releaseReservation(ctx context.Context, order *erp.Order) {
+	err := s.storage.ReleaseReservation(ctx, order.ID)
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		level.Error(s.logger).Log("msg", "failed to release reservation", "order", order.ID, "err", err)
+	}
+}
+
+// releaseExpiredReservations returns the stock of unpaid orders back to sale.
+func (s *service) releaseExpiredReservations(ctx context.Context) error {
+	released, err := s.storage.ReleaseExpiredReservations(ctx, time.Now())
+	if released > 0 {
+		level.Info(s.logger).Log("msg", "released expired reservations", "count", released)
+	}
+	return err
+}
+
+func (s *service) changeOrderStatus(ctx context.Context, order *erp.Order, status erp.OrderStatus, comment string) error {
+	change, err := order.ChangeStatus(status, erp.PaymentActor, comment, time.Now())
+	if err != nil {
+		return err
+	}
+	err = s.storage.UpdateOrderStatus(ctx, order.ID, change)
 	if err != nil {
 		return err
 	}
+	order.Status = change.To
+	order.ModifiedOn = change.At
+	order.History = append(order.History, change)
 	return nil
 }
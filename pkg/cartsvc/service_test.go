@@ -0,0 +1,219 @@
+package erpsvc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+// checkoutStorage keeps the active cart of a single user and the orders placed from it,
+// methods the checkout doesn't need panic.
+type checkoutStorage struct {
+	Storage
+	userID   string
+	user     *erp.User
+	products []*erp.CartProduct
+	prices   map[string]*erp.Pricing
+	orders   map[string]*erp.Order
+	reserved map[string]map[string]int
+}
+
+func (m *checkoutStorage) LoadCart(_ context.Context, userID string) (*erp.Cart, error) {
+	if userID != m.userID {
+		return nil, erp.ErrNotFoundInStorage
+	}
+	return &erp.Cart{UserID: userID, Products: m.products}, nil
+}
+
+func (m *checkoutStorage) GetUser(_ context.Context, id string) (*erp.User, error) {
+	if id != m.userID || m.user == nil {
+		return nil, erp.ErrNotFoundInStorage
+	}
+	return m.user, nil
+}
+
+func (m *checkoutStorage) GetPrices(_ context.Context, skus []string) (map[string]*erp.Pricing, error) {
+	prices := map[string]*erp.Pricing{}
+	for _, sku := range skus {
+		if p, ok := m.prices[sku]; ok {
+			prices[sku] = p
+		}
+	}
+	return prices, nil
+}
+
+func (m *checkoutStorage) GetDeliveryZones(context.Context) ([]*erp.DeliveryZone, error) {
+	return nil, nil
+}
+
+func (m *checkoutStorage) CheckoutCart(_ context.Context, userID string, order *erp.Order) error {
+	if userID != m.userID {
+		return erp.ErrNotFoundInStorage
+	}
+	m.orders[order.ID] = order
+	return nil
+}
+
+func (m *checkoutStorage) ReserveStock(_ context.Context, id string, _ string, quantities map[string]int, _ time.Time) error {
+	m.reserved[id] = quantities
+	return nil
+}
+
+func (m *checkoutStorage) ReleaseReservation(_ context.Context, id string) error {
+	if _, ok := m.reserved[id]; !ok {
+		return erp.ErrNotFoundInStorage
+	}
+	delete(m.reserved, id)
+	return nil
+}
+
+func (m *checkoutStorage) CommitReservation(_ context.Context, id string) error {
+	return m.ReleaseReservation(context.Background(), id)
+}
+
+func (m *checkoutStorage) UpdateOrderPayment(_ context.Context, id string, payment *erp.Payment) error {
+	if _, ok := m.orders[id]; !ok {
+		return erp.ErrNotFoundInStorage
+	}
+	return nil
+}
+
+func (m *checkoutStorage) UpdateOrderStatus(_ context.Context, id string, change *erp.OrderStatusChange) error {
+	order, ok := m.orders[id]
+	if !ok || order.Status != change.From {
+		return erp.ErrModifiedInStorage
+	}
+	return nil
+}
+
+// recordingPayments records the authorized amounts, the "decline" token is declined.
+type recordingPayments struct {
+	erp.PaymentProvider
+	authorized []erp.Money
+}
+
+func (p *recordingPayments) Name() string {
+	return "recording"
+}
+
+func (p *recordingPayments) Authorize(_ context.Context, req *erp.PaymentRequest) (*erp.PaymentResult, error) {
+	p.authorized = append(p.authorized, req.Amount)
+	if req.Token == "decline" {
+		return nil, erp.ErrPaymentDeclined
+	}
+	return &erp.PaymentResult{TransactionID: "tx-" + req.OrderID, Status: erp.PaymentStatusAuthorized}, nil
+}
+
+func (p *recordingPayments) Capture(_ context.Context, transactionID string, _ erp.Money) (*erp.PaymentResult, error) {
+	return &erp.PaymentResult{TransactionID: transactionID, Status: erp.PaymentStatusCaptured}, nil
+}
+
+func rub(kopecks int64) erp.Money {
+	return erp.NewMoney(kopecks, erp.DefaultCurrency)
+}
+
+func TestCheckout(t *testing.T) {
+	prices := map[string]*erp.Pricing{
+		"1": {Price: rub(1000)},
+		"2": {Price: rub(255)},
+	}
+	tests := []struct {
+		name           string
+		products       []*erp.CartProduct
+		discount       uint8
+		anonymous      bool
+		token          string
+		wantStatus     int
+		wantAuthorized []erp.Money
+		wantTotal      erp.Money
+	}{
+		{
+			name:           "priced cart",
+			products:       []*erp.CartProduct{{SKU: "1", Quantity: 2}, {SKU: "2", Quantity: 1}},
+			wantAuthorized: []erp.Money{rub(2255)},
+			wantTotal:      rub(2255),
+		},
+		{
+			name:      "nothing to charge",
+			products:  []*erp.CartProduct{{SKU: "1", Quantity: 1}},
+			discount:  100,
+			wantTotal: rub(0),
+		},
+		{
+			name:           "anonymous user",
+			products:       []*erp.CartProduct{{SKU: "1", Quantity: 1}},
+			anonymous:      true,
+			wantAuthorized: []erp.Money{rub(1000)},
+			wantTotal:      rub(1000),
+		},
+		{
+			name:       "unpriced line",
+			products:   []*erp.CartProduct{{SKU: "1", Quantity: 1}, {SKU: "3", Quantity: 1}},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:           "declined",
+			products:       []*erp.CartProduct{{SKU: "1", Quantity: 1}},
+			token:          "decline",
+			wantStatus:     http.StatusPaymentRequired,
+			wantAuthorized: []erp.Money{rub(1000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &checkoutStorage{
+				userID:   "user",
+				products: tt.products,
+				prices:   prices,
+				orders:   map[string]*erp.Order{},
+				reserved: map[string]map[string]int{},
+			}
+			if !tt.anonymous {
+				storage.user = &erp.User{ConstDiscount: tt.discount}
+			}
+			payments := &recordingPayments{}
+			svc, err := newService(&ServiceConfig{Storage: storage, Payments: payments})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			input := &erp.CheckoutInput{Address: "101000, Moscow, Tverskaya 1", PaymentMethod: "card", PaymentToken: tt.token}
+			order, err := svc.Checkout(context.Background(), "user", input, erp.Market{})
+			if len(payments.authorized) != len(tt.wantAuthorized) ||
+				(len(tt.wantAuthorized) > 0 && payments.authorized[0] != tt.wantAuthorized[0]) {
+				t.Errorf("authorized %v, want %v", payments.authorized, tt.wantAuthorized)
+			}
+			if tt.wantStatus != 0 {
+				e, ok := err.(*erp.ServiceError)
+				if !ok || e.Code != tt.wantStatus {
+					t.Fatalf("got %v, want status %d", err, tt.wantStatus)
+				}
+				if len(storage.orders) > 0 {
+					t.Errorf("order stored after a failed checkout")
+				}
+				if len(storage.reserved) > 0 {
+					t.Errorf("stock kept reserved after a failed checkout: %v", storage.reserved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if order.Anonymous != tt.anonymous {
+				t.Errorf("order anonymous %v, want %v", order.Anonymous, tt.anonymous)
+			}
+			if order.TotalPrice != tt.wantTotal {
+				t.Errorf("order total %v, want %v", order.TotalPrice, tt.wantTotal)
+			}
+			if order.Status != erp.OrderStatusPaid || order.Payment.Status != erp.PaymentStatusCaptured {
+				t.Errorf("order %s, payment %s, want paid and captured", order.Status, order.Payment.Status)
+			}
+			if len(storage.reserved) > 0 {
+				t.Errorf("reservation of the paid order not committed: %v", storage.reserved)
+			}
+		})
+	}
+}
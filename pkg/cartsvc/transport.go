@@ -3,13 +3,19 @@ package erpsvc
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/anabiozz/core/lapkins/pkg/auth"
-	"github.com/anabiozz/core/lapkins/pkg/cookies"
 	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/gorilla/mux"
 )
 
 type addProductRequest struct {
@@ -33,38 +39,40 @@ func makeAddProductEndpoint(s Service) endpoint.Endpoint {
 	}
 }
 
-//type getHeaderCartInfoRequest struct {
-//	UserID string `json:"user_id"`
-//	Err    error  `json:"err"`
-//}
-//
-//type getHeaderCartInfoResponse struct {
-//	Info *erp.HeaderCartInfo `json:"info"`
-//	Err  error               `json:"err"`
-//}
-//
-//func makeGetHeaderCartInfo(s Service) endpoint.Endpoint {
-//	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-//		req := request.(getHeaderCartInfoRequest)
-//		info, err := s.GetHeaderCartInfo(ctx, req.UserID)
-//		return getHeaderCartInfoResponse{Err: err, Info: info}, nil
-//	}
-//}
+type getHeaderCartInfoRequest struct {
+	UserID string     `json:"user_id"`
+	Market erp.Market `json:"market"`
+	Err    error      `json:"err"`
+}
+
+type getHeaderCartInfoResponse struct {
+	Info *erp.HeaderCartInfo `json:"info"`
+	Err  error               `json:"err"`
+}
+
+func makeGetHeaderCartInfo(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getHeaderCartInfoRequest)
+		info, err := s.GetHeaderCartInfo(ctx, req.UserID, req.Market)
+		return getHeaderCartInfoResponse{Err: err, Info: info}, nil
+	}
+}
 
 type getCartRequest struct {
-	UserID string `json:"user_id"`
-	Err    error  `json:"err"`
+	UserID string     `json:"user_id"`
+	Market erp.Market `json:"market"`
+	Err    error      `json:"err"`
 }
 
 type getCartResponse struct {
-	Cart []*erp.CartProduct `json:"cart"`
-	Err  error              `json:"err"`
+	Cart *erp.PricedCart `json:"cart"`
+	Err  error           `json:"err"`
 }
 
 func makeGetCart(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(getCartRequest)
-		cart, err := s.LoadCart(ctx, req.UserID)
+		cart, err := s.LoadCart(ctx, req.UserID, req.Market)
 		return getCartResponse{Err: err, Cart: cart}, nil
 	}
 }
@@ -103,6 +111,31 @@ func makeDecreaseProductQty(s Service) endpoint.Endpoint {
 	}
 }
 
+type setProductQtyRequest struct {
+	UserID   string     `json:"user_id"`
+	SKU      string     `json:"sku"`
+	Quantity int        `json:"quantity"`
+	Version  *int64     `json:"version"`
+	Market   erp.Market `json:"-"`
+}
+
+type setProductQtyResponse struct {
+	Cart *erp.PricedCart `json:"cart"`
+	Err  error           `json:"err"`
+}
+
+func makeSetProductQty(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setProductQtyRequest)
+		version := erp.AnyCartVersion
+		if req.Version != nil {
+			version = *req.Version
+		}
+		cart, err := s.SetProductQuantity(ctx, req.UserID, req.SKU, req.Quantity, version, req.Market)
+		return setProductQtyResponse{Cart: cart, Err: err}, nil
+	}
+}
+
 type removeProductRequest struct {
 	UserID string `json:"user_id"`
 	SKU    string `json:"sku"`
@@ -133,13 +166,7 @@ func decodeAddProductRequest(_ context.Context, r *http.Request) (interface{}, e
 	if userID != "" && isLoggedIn {
 		req.IsLoggedIn = true
 	}
-	tmpUserID, err := cookies.GetCookieValue(r, "tmp-user-id")
-	if err != nil {
-		if err != http.ErrNoCookie {
-			return nil, err
-		}
-	}
-	if tmpUserID != "" {
+	if _, err := auth.GetTmpUserID(r); err == nil {
 		req.IsTmpUserIDSet = true
 	}
 	return req, nil
@@ -156,7 +183,7 @@ func encodeAddProductResponse(ctx context.Context, w http.ResponseWriter, respon
 		http.SetCookie(w, &http.Cookie{
 			Path:    "/",
 			Name:    "tmp-user-id",
-			Value:   res.UserID,
+			Value:   auth.SignTmpUserID(res.UserID),
 			Expires: time.Now().Add(168 * time.Hour),
 		})
 	}
@@ -165,26 +192,29 @@ func encodeAddProductResponse(ctx context.Context, w http.ResponseWriter, respon
 	return json.NewEncoder(w).Encode(true)
 }
 
-//
-//func decodeGetHeaderCartInfoRequest(_ context.Context, r *http.Request) (interface{}, error) {
-//	req := getHeaderCartInfoRequest{}
-//	userID, _, err := auth.GetUserID(r)
-//	if err != nil {
-//		return nil, erp.ErrBadRequest("%s", err)
-//	}
-//	req.UserID = userID
-//	return req, nil
-//}
-//
-//func encodeGetHeaderCartInfoResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-//	res := response.(getHeaderCartInfoResponse)
-//	if res.Err != nil {
-//		encodeError(ctx, res.Err, w)
-//		return nil
-//	}
-//	w.Header().Set("Content-Type", "application/json")
-//	return json.NewEncoder(w).Encode(res.Info)
-//}
+func decodeGetHeaderCartInfoRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := getHeaderCartInfoRequest{}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	req.Market, err = erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	return req, nil
+}
+
+func encodeGetHeaderCartInfoResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getHeaderCartInfoResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Info)
+}
 
 func decodeGetCartRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	req := getCartRequest{}
@@ -193,6 +223,10 @@ func decodeGetCartRequest(_ context.Context, r *http.Request) (interface{}, erro
 		return nil, erp.ErrBadRequest("%s", err)
 	}
 	req.UserID = userID
+	req.Market, err = erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
 	return req, nil
 }
 
@@ -203,9 +237,64 @@ func encodeGetCartResponse(ctx context.Context, w http.ResponseWriter, response
 		return nil
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", cartETag(res.Cart.Version))
+	return json.NewEncoder(w).Encode(res.Cart)
+}
+
+// decodeSetProductQtyRequest takes the cart version from the If-Match header
+// or from the version field of the request.
+func decodeSetProductQtyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := setProductQtyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	if etag := r.Header.Get("If-Match"); etag != "" && etag != "*" {
+		version, err := parseCartETag(etag)
+		if err != nil {
+			return nil, erp.ErrBadRequest("%s", err)
+		}
+		req.Version = &version
+	}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	req.Market, err = erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	return req, nil
+}
+
+func encodeSetProductQtyResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(setProductQtyResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", cartETag(res.Cart.Version))
 	return json.NewEncoder(w).Encode(res.Cart)
 }
 
+func cartETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+func parseCartETag(etag string) (int64, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	value, err := strconv.Unquote(etag)
+	if err != nil {
+		return 0, fmt.Errorf("invalid If-Match header %q", etag)
+	}
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid If-Match header %q", etag)
+	}
+	return version, nil
+}
+
 func decodeIncreaseProductQtyRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	req := increaseProductQtyRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -275,40 +364,592 @@ func encodeRemoveProductResponse(ctx context.Context, w http.ResponseWriter, res
 	return json.NewEncoder(w).Encode(true)
 }
 
-//
-//type addOrderRequest struct {
-//	Order *erp.Order
-//}
-//
-//type addOrderResponse struct {
-//	Err error `json:"err"`
-//}
-//
-//func makeAddOrderEndpoint(s Service) endpoint.Endpoint {
-//	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-//		req := request.(addOrderRequest)
-//		err = s.AddOrder(ctx, req.Order)
-//		return addOrderResponse{Err: err}, nil
-//	}
-//}
-//
-//func decodeAddOrderRequest(_ context.Context, r *http.Request) (interface{}, error) {
-//	req := addOrderRequest{}
-//	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-//		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
-//	}
-//	return req, nil
-//}
-//
-//func encodeAddOrderResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-//	res := response.(addOrderResponse)
-//	if res.Err != nil {
-//		encodeError(ctx, res.Err, w)
-//		return nil
-//	}
-//	w.Header().Set("Content-Type", "application/json")
-//	return json.NewEncoder(w).Encode(true)
-//}
+type applyCouponRequest struct {
+	UserID string     `json:"user_id"`
+	Code   string     `json:"code"`
+	Market erp.Market `json:"-"`
+}
+
+type applyCouponResponse struct {
+	Cart *erp.PricedCart `json:"cart"`
+	Err  error           `json:"err"`
+}
+
+func makeApplyCouponEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(applyCouponRequest)
+		cart, err := s.ApplyCoupon(ctx, req.UserID, req.Code, req.Market)
+		return applyCouponResponse{Cart: cart, Err: err}, nil
+	}
+}
+
+func decodeApplyCouponRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := applyCouponRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	req.Market, err = erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	return req, nil
+}
+
+func encodeApplyCouponResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(applyCouponResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Cart)
+}
+
+type removeCouponRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type removeCouponResponse struct {
+	Err error `json:"err"`
+}
+
+func makeRemoveCouponEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(removeCouponRequest)
+		err = s.RemoveCoupon(ctx, req.UserID)
+		return removeCouponResponse{Err: err}, nil
+	}
+}
+
+func decodeRemoveCouponRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := removeCouponRequest{}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	return req, nil
+}
+
+func encodeRemoveCouponResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(removeCouponResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+type mergeCartsRequest struct {
+	TmpUserID string
+	UserID    string
+}
+
+type mergeCartsResponse struct {
+	Err error `json:"err"`
+}
+
+func makeMergeCartsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(mergeCartsRequest)
+		err = s.MergeCarts(ctx, req.TmpUserID, req.UserID)
+		return mergeCartsResponse{Err: err}, nil
+	}
+}
+
+// decodeMergeCartsRequest takes the user from the token and the anonymous cart from
+// the signed tmp-user-id cookie, only a logged in user can take over the anonymous cart
+// of the browser it logged in from.
+func decodeMergeCartsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	tmpUserID, err := auth.GetTmpUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", "signed tmp-user-id cookie is required")
+	}
+	req := mergeCartsRequest{TmpUserID: tmpUserID}
+	userID, isLoggedIn, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrUnauthorized("%s", err)
+	}
+	if !isLoggedIn {
+		return nil, erp.ErrUnauthorized("%s", "user is not logged in")
+	}
+	req.UserID = userID
+	return req, nil
+}
+
+func encodeMergeCartsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(mergeCartsResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+type getWishlistRequest struct {
+	UserID string     `json:"user_id"`
+	Market erp.Market `json:"market"`
+}
+
+type getWishlistResponse struct {
+	Wishlist *erp.Wishlist `json:"wishlist"`
+	Err      error         `json:"err"`
+}
+
+func makeGetWishlistEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getWishlistRequest)
+		wishlist, err := s.GetWishlist(ctx, req.UserID, req.Market)
+		return getWishlistResponse{Wishlist: wishlist, Err: err}, nil
+	}
+}
+
+func decodeGetWishlistRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := getWishlistRequest{}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	req.Market, err = erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	return req, nil
+}
+
+func encodeGetWishlistResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getWishlistResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Wishlist)
+}
+
+type getSharedWishlistRequest struct {
+	Token  string     `json:"token"`
+	Market erp.Market `json:"market"`
+}
+
+func makeGetSharedWishlistEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getSharedWishlistRequest)
+		wishlist, err := s.GetSharedWishlist(ctx, req.Token, req.Market)
+		return getWishlistResponse{Wishlist: wishlist, Err: err}, nil
+	}
+}
+
+func decodeGetSharedWishlistRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := getSharedWishlistRequest{
+		Token: mux.Vars(r)["token"],
+	}
+	market, err := erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.Market = market
+	return req, nil
+}
+
+type addToWishlistRequest struct {
+	SKU    string `json:"sku"`
+	UserID string `json:"user_id"`
+}
+
+type addToWishlistResponse struct {
+	SetTmpUserIDCookie bool   `json:"set_tmp_user_id_cookie"`
+	UserID             string `json:"user_id"`
+	Err                error  `json:"err"`
+}
+
+func makeAddToWishlistEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(addToWishlistRequest)
+		setTmpUserIDCookie, userID, err := s.AddToWishlist(ctx, req.SKU, req.UserID)
+		return addToWishlistResponse{SetTmpUserIDCookie: setTmpUserIDCookie, UserID: userID, Err: err}, nil
+	}
+}
+
+func decodeAddToWishlistRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := addToWishlistRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	return req, nil
+}
+
+func encodeAddToWishlistResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(addToWishlistResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+
+	if res.SetTmpUserIDCookie {
+		http.SetCookie(w, &http.Cookie{
+			Path:    "/",
+			Name:    "tmp-user-id",
+			Value:   auth.SignTmpUserID(res.UserID),
+			Expires: time.Now().Add(168 * time.Hour),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+type wishlistProductRequest struct {
+	UserID     string `json:"user_id"`
+	SKU        string `json:"sku"`
+	IsLoggedIn bool   `json:"is_logged_in"`
+}
+
+type wishlistProductResponse struct {
+	Err error `json:"err"`
+}
+
+func makeRemoveFromWishlistEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(wishlistProductRequest)
+		err = s.RemoveFromWishlist(ctx, req.UserID, req.SKU)
+		return wishlistProductResponse{Err: err}, nil
+	}
+}
+
+func makeMoveToCartEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(wishlistProductRequest)
+		err = s.MoveToCart(ctx, req.UserID, req.SKU, req.IsLoggedIn)
+		return wishlistProductResponse{Err: err}, nil
+	}
+}
+
+func decodeWishlistProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := wishlistProductRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	userID, isLoggedIn, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	req.IsLoggedIn = isLoggedIn
+	return req, nil
+}
+
+func encodeWishlistProductResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(wishlistProductResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+type shareWishlistRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type shareWishlistResponse struct {
+	Token string `json:"token"`
+	Err   error  `json:"err"`
+}
+
+func makeShareWishlistEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(shareWishlistRequest)
+		token, err := s.ShareWishlist(ctx, req.UserID)
+		return shareWishlistResponse{Token: token, Err: err}, nil
+	}
+}
+
+func decodeShareWishlistRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := shareWishlistRequest{}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	return req, nil
+}
+
+func encodeShareWishlistResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(shareWishlistResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(map[string]string{"token": res.Token})
+}
+
+type deliveryOptionsRequest struct {
+	UserID      string
+	Destination *erp.ShippingDestination
+	Market      erp.Market
+}
+
+type deliveryOptionsResponse struct {
+	Quote *erp.DeliveryQuote `json:"quote"`
+	Err   error              `json:"err"`
+}
+
+func makeDeliveryOptionsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deliveryOptionsRequest)
+		quote, err := s.GetDeliveryOptions(ctx, req.UserID, req.Destination, req.Market)
+		return deliveryOptionsResponse{Quote: quote, Err: err}, nil
+	}
+}
+
+func decodeDeliveryOptionsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	req := deliveryOptionsRequest{
+		Destination: &erp.ShippingDestination{
+			Country:  query.Get("country"),
+			Postcode: query.Get("postcode"),
+		},
+	}
+	if query.Get("lat") != "" || query.Get("lng") != "" {
+		location, err := decodeLocation(query)
+		if err != nil {
+			return nil, err
+		}
+		req.Destination.Location = location
+	}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	req.Market, err = erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	return req, nil
+}
+
+// decodeLocation reads the lat and lng query parameters.
+func decodeLocation(query url.Values) (*erp.Location, error) {
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil {
+		return nil, erp.ErrBadRequest("invalid lat %q", query.Get("lat"))
+	}
+	lng, err := strconv.ParseFloat(query.Get("lng"), 64)
+	if err != nil {
+		return nil, erp.ErrBadRequest("invalid lng %q", query.Get("lng"))
+	}
+	return &erp.Location{Lat: lat, Lng: lng}, nil
+}
+
+func encodeDeliveryOptionsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deliveryOptionsResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Quote)
+}
+
+type checkoutRequest struct {
+	UserID string
+	Input  *erp.CheckoutInput
+	Market erp.Market
+}
+
+type checkoutResponse struct {
+	Order *erp.Order `json:"order"`
+	Err   error      `json:"err"`
+}
+
+func makeCheckoutEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(checkoutRequest)
+		order, err := s.Checkout(ctx, req.UserID, req.Input, req.Market)
+		return checkoutResponse{Order: order, Err: err}, nil
+	}
+}
+
+func decodeCheckoutRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := checkoutRequest{Input: &erp.CheckoutInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	userID, _, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	req.UserID = userID
+	req.Market, err = erp.RequestMarket(r)
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	return req, nil
+}
+
+func encodeCheckoutResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(checkoutResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(res.Order)
+}
+
+type paymentEventRequest struct {
+	Payload   []byte
+	Signature string
+}
+
+type paymentEventResponse struct {
+	Err error `json:"err"`
+}
+
+func makePaymentEventEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(paymentEventRequest)
+		err = s.HandlePaymentEvent(ctx, req.Payload, req.Signature)
+		return paymentEventResponse{Err: err}, nil
+	}
+}
+
+func decodePaymentEventRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, erp.ErrBadRequest("failed to read request: %v", err)
+	}
+	req := paymentEventRequest{
+		Payload:   payload,
+		Signature: r.Header.Get("X-Signature"),
+	}
+	return req, nil
+}
+
+func encodePaymentEventResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(paymentEventResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+type getOrdersRequest struct {
+	UserID string
+	Status erp.OrderStatus
+	Page   *erp.PageRequest
+}
+
+type getOrdersResponse struct {
+	Orders *erp.CustomerOrderPage
+	Err    error
+}
+
+func makeGetOrdersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getOrdersRequest)
+		orders, err := s.GetOrders(ctx, req.UserID, req.Status, req.Page)
+		return getOrdersResponse{Orders: orders, Err: err}, nil
+	}
+}
+
+// decodeOrdersUser takes the user from the token, anonymous users have no order history.
+func decodeOrdersUser(r *http.Request) (string, error) {
+	userID, isLoggedIn, err := auth.GetUserID(r)
+	if err != nil {
+		return "", erp.ErrUnauthorized("%s", err)
+	}
+	if !isLoggedIn {
+		return "", erp.ErrUnauthorized("%s", "user is not logged in")
+	}
+	return userID, nil
+}
+
+func decodeGetOrdersRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	userID, err := decodeOrdersUser(r)
+	if err != nil {
+		return nil, err
+	}
+	query := r.URL.Query()
+	page, err := erp.DecodePageRequest(query, "id", "created_on", "total_price")
+	if err != nil {
+		return nil, err
+	}
+	// The newest orders come first unless asked otherwise.
+	if page.Sort == "" {
+		page.Sort = "created_on"
+		page.Desc = query.Get("order") != "asc"
+	}
+	req := getOrdersRequest{UserID: userID, Page: page}
+	req.Status = erp.OrderStatus(query.Get("status"))
+	return req, nil
+}
+
+func encodeGetOrdersResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getOrdersResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Orders)
+}
+
+type getOrderRequest struct {
+	UserID string
+	ID     string
+}
+
+type getOrderResponse struct {
+	Order *erp.CustomerOrder
+	Err   error
+}
+
+func makeGetOrderEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getOrderRequest)
+		order, err := s.GetOrder(ctx, req.UserID, req.ID)
+		return getOrderResponse{Order: order, Err: err}, nil
+	}
+}
+
+func decodeGetOrderRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	userID, err := decodeOrdersUser(r)
+	if err != nil {
+		return nil, err
+	}
+	return getOrderRequest{UserID: userID, ID: mux.Vars(r)["id"]}, nil
+}
+
+func encodeGetOrderResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getOrderResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Order)
+}
 
 // ****************** Errors *********************
 
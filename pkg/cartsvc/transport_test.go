@@ -0,0 +1,142 @@
+package erpsvc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anabiozz/core/lapkins/pkg/auth"
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+// memStorage keeps a single cart in memory, methods the tests don't need panic.
+type memStorage struct {
+	Storage
+	cart      *erp.Cart
+	inventory map[string]*erp.Inventory
+	prices    map[string]*erp.Pricing
+}
+
+func (m *memStorage) LoadCart(_ context.Context, userID string) (*erp.Cart, error) {
+	if m.cart == nil || m.cart.UserID != userID {
+		return nil, erp.ErrNotFoundInStorage
+	}
+	cart := *m.cart
+	cart.Products = make([]*erp.CartProduct, 0, len(m.cart.Products))
+	for _, p := range m.cart.Products {
+		line := *p
+		cart.Products = append(cart.Products, &line)
+	}
+	return &cart, nil
+}
+
+func (m *memStorage) SetProductQuantity(_ context.Context, userID string, sku string, qty int, version int64) error {
+	if m.cart == nil || m.cart.UserID != userID {
+		return erp.ErrNotFoundInStorage
+	}
+	if version != erp.AnyCartVersion && version != m.cart.Version {
+		return erp.ErrModifiedInStorage
+	}
+	for _, p := range m.cart.Products {
+		if p.SKU == sku {
+			p.Quantity = qty
+			m.cart.Version++
+			return nil
+		}
+	}
+	return erp.ErrNotFoundInStorage
+}
+
+func (m *memStorage) GetInventory(_ context.Context, sku string) (*erp.Inventory, error) {
+	inventory, ok := m.inventory[sku]
+	if !ok {
+		return nil, erp.ErrNotFoundInStorage
+	}
+	return inventory, nil
+}
+
+func (m *memStorage) GetUser(context.Context, string) (*erp.User, error) {
+	return nil, erp.ErrNotFoundInStorage
+}
+
+func (m *memStorage) GetPrices(_ context.Context, skus []string) (map[string]*erp.Pricing, error) {
+	prices := map[string]*erp.Pricing{}
+	for _, sku := range skus {
+		if p, ok := m.prices[sku]; ok {
+			prices[sku] = p
+		}
+	}
+	return prices, nil
+}
+
+func newTestHandler(t *testing.T, storage Storage) http.Handler {
+	t.Helper()
+	svc, err := newService(&ServiceConfig{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return makeHandler(svc)
+}
+
+func newCartRequest(method string, target string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.AddCookie(&http.Cookie{Name: "tmp-user-id", Value: auth.SignTmpUserID("user")})
+	return r
+}
+
+func TestSetProductQuantityIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch func(etag string) string
+		want    int
+	}{
+		{"etag of the loaded cart", func(etag string) string { return etag }, http.StatusOK},
+		{"weak etag of the loaded cart", func(etag string) string { return "W/" + etag }, http.StatusOK},
+		{"stale etag", func(string) string { return `"2"` }, http.StatusPreconditionFailed},
+		{"any version", func(string) string { return "*" }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &memStorage{
+				cart: &erp.Cart{
+					UserID:   "user",
+					Status:   erp.CartStatusActive,
+					Version:  3,
+					Products: []*erp.CartProduct{{SKU: "1", Quantity: 1}},
+				},
+				inventory: map[string]*erp.Inventory{"1": {ID: "1", Quantity: 10}},
+				prices:    map[string]*erp.Pricing{"1": {Price: erp.Money{Amount: 10000, Currency: erp.DefaultCurrency}}},
+			}
+			handler := newTestHandler(t, storage)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newCartRequest(http.MethodGet, "/api/v1/card", ""))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET /api/v1/card: status %d: %s", w.Code, w.Body)
+			}
+			etag := w.Header().Get("ETag")
+			if etag != `"3"` {
+				t.Fatalf("GET /api/v1/card: ETag %s, want \"3\"", etag)
+			}
+
+			r := newCartRequest(http.MethodPut, "/api/v1/card/quantity", `{"sku":"1","quantity":2}`)
+			r.Header.Set("If-Match", tt.ifMatch(etag))
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("PUT /api/v1/card/quantity: status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("ETag"); got != `"4"` {
+				t.Errorf("PUT /api/v1/card/quantity: ETag %s, want \"4\"", got)
+			}
+			if got := storage.cart.Products[0].Quantity; got != 2 {
+				t.Errorf("quantity %d, want 2", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package erp
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Address book errors.
+var ErrAddressNotFound = errors.New("address not found")
+
+// maxAddressFieldLength is the longest text of an address field.
+const maxAddressFieldLength = 200
+
+var (
+	phoneRegexp    = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+	postcodeRegexp = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,8}[A-Z0-9]$`)
+	// countryPostcodes are the postcode formats of the countries the shop ships to the most,
+	// postcodes of other countries are checked with postcodeRegexp.
+	countryPostcodes = map[string]*regexp.Regexp{
+		"RU": regexp.MustCompile(`^[0-9]{6}$`),
+		"BY": regexp.MustCompile(`^[0-9]{6}$`),
+		"KZ": regexp.MustCompile(`^([0-9]{6}|[A-Z][0-9]{2}[A-Z][0-9][A-Z][0-9])$`),
+		"UA": regexp.MustCompile(`^[0-9]{5}$`),
+		"DE": regexp.MustCompile(`^[0-9]{5}$`),
+		"US": regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`),
+	}
+)
+
+// Address is a shipping address in the address book of a user.
+// The first address of a user is the default one, the user can pick another.
+type Address struct {
+	ID         string    `bson:"_id" json:"id"`
+	UserID     string    `bson:"user_id" json:"user_id"`
+	Country    string    `bson:"country" json:"country"`
+	Region     string    `bson:"region,omitempty" json:"region,omitempty"`
+	City       string    `bson:"city" json:"city"`
+	Street     string    `bson:"street" json:"street"`
+	Building   string    `bson:"building" json:"building"`
+	Apartment  string    `bson:"apartment,omitempty" json:"apartment,omitempty"`
+	Postcode   string    `bson:"postcode" json:"postcode"`
+	Recipient  string    `bson:"recipient" json:"recipient"`
+	Phone      string    `bson:"phone" json:"phone"`
+	Location   *Location `bson:"location,omitempty" json:"location,omitempty"`
+	Default    bool      `bson:"default" json:"default"`
+	CreatedOn  time.Time `bson:"created_on" json:"createdOn"`
+	ModifiedOn time.Time `bson:"modified_on" json:"modifiedOn"`
+}
+
+// String returns the address in one line, like the free text addresses of older orders.
+func (a *Address) String() string {
+	parts := make([]string, 0, 6)
+	for _, p := range []string{a.Postcode, a.Region, a.City, a.Street, a.Building, a.Apartment} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Destination returns the shipping destination of the address.
+func (a *Address) Destination() *ShippingDestination {
+	d := &ShippingDestination{Country: a.Country, Postcode: a.Postcode}
+	if a.Location != nil {
+		location := *a.Location
+		d.Location = &location
+	}
+	d.Normalize()
+	return d
+}
+
+// AddressInput is an address to save. Phones are in the international format like +79161234567,
+// spaces, dashes and parentheses are removed. Location is needed to check the delivery zone
+// at checkout, it is required once zones are set up.
+// Default makes the address the default one, clearing it on update keeps the default.
+type AddressInput struct {
+	Country   string    `json:"country"`
+	Region    string    `json:"region"`
+	City      string    `json:"city"`
+	Street    string    `json:"street"`
+	Building  string    `json:"building"`
+	Apartment string    `json:"apartment"`
+	Postcode  string    `json:"postcode"`
+	Recipient string    `json:"recipient"`
+	Phone     string    `json:"phone"`
+	Location  *Location `json:"location"`
+	Default   bool      `json:"default"`
+}
+
+// Normalize trims the fields, upper cases the country and the postcode and removes
+// the formatting of the phone.
+func (in *AddressInput) Normalize() {
+	in.Country = strings.ToUpper(strings.TrimSpace(in.Country))
+	in.Region = strings.TrimSpace(in.Region)
+	in.City = strings.TrimSpace(in.City)
+	in.Street = strings.TrimSpace(in.Street)
+	in.Building = strings.TrimSpace(in.Building)
+	in.Apartment = strings.TrimSpace(in.Apartment)
+	in.Postcode = strings.ToUpper(strings.TrimSpace(in.Postcode))
+	in.Recipient = strings.TrimSpace(in.Recipient)
+	in.Phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(strings.TrimSpace(in.Phone))
+}
+
+// Validate checks the normalized address.
+func (in *AddressInput) Validate() error {
+	var v Validation
+	if in.Country == "" {
+		v.Add("country", "is required")
+	} else if !regionRegexp.MatchString(in.Country) {
+		v.Add("country", "must be an ISO 3166 country code like RU")
+	}
+	fields := []struct {
+		name     string
+		value    string
+		required bool
+	}{
+		{"region", in.Region, false},
+		{"city", in.City, true},
+		{"street", in.Street, true},
+		{"building", in.Building, true},
+		{"apartment", in.Apartment, false},
+		{"recipient", in.Recipient, true},
+	}
+	for _, f := range fields {
+		switch {
+		case f.value == "" && f.required:
+			v.Add(f.name, "is required")
+		case len([]rune(f.value)) > maxAddressFieldLength:
+			v.Add(f.name, "must be at most %d characters", maxAddressFieldLength)
+		}
+	}
+	if in.Postcode == "" {
+		v.Add("postcode", "is required")
+	} else if re, ok := countryPostcodes[in.Country]; ok && !re.MatchString(in.Postcode) {
+		v.Add("postcode", "is not a valid postcode of %s", in.Country)
+	} else if !ok && !postcodeRegexp.MatchString(in.Postcode) {
+		v.Add("postcode", "must be 3 to 10 letters and digits")
+	}
+	if in.Phone == "" {
+		v.Add("phone", "is required")
+	} else if !phoneRegexp.MatchString(in.Phone) {
+		v.Add("phone", "must be an international number like +79161234567")
+	}
+	if in.Location != nil {
+		if err := in.Location.Validate(); err != nil {
+			v.Add("location", "%s", err)
+		}
+	}
+	return v.Err()
+}
+
+// Address returns the address of a validated input.
+func (in *AddressInput) Address(id string, userID string, createdTime time.Time) *Address {
+	createdTime = createdTime.Round(time.Second)
+	a := &Address{
+		ID:         id,
+		UserID:     userID,
+		Country:    in.Country,
+		Region:     in.Region,
+		City:       in.City,
+		Street:     in.Street,
+		Building:   in.Building,
+		Apartment:  in.Apartment,
+		Postcode:   in.Postcode,
+		Recipient:  in.Recipient,
+		Phone:      in.Phone,
+		Default:    in.Default,
+		CreatedOn:  createdTime,
+		ModifiedOn: createdTime,
+	}
+	if in.Location != nil {
+		location := *in.Location
+		a.Location = &location
+	}
+	return a
+}
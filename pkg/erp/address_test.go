@@ -0,0 +1,72 @@
+package erp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddressInputNormalize(t *testing.T) {
+	in := &AddressInput{
+		Country:  " ru ",
+		City:     " Москва ",
+		Postcode: " kz a15c9t5 ",
+		Phone:    " +7 (916) 123-45-67 ",
+	}
+	in.Normalize()
+	want := &AddressInput{Country: "RU", City: "Москва", Postcode: "KZ A15C9T5", Phone: "+79161234567"}
+	if !reflect.DeepEqual(in, want) {
+		t.Errorf("got %+v, want %+v", in, want)
+	}
+}
+
+func TestAddressInputValidate(t *testing.T) {
+	valid := func() *AddressInput {
+		return &AddressInput{
+			Country:   "RU",
+			City:      "Москва",
+			Street:    "Тверская",
+			Building:  "1",
+			Postcode:  "101000",
+			Recipient: "Иван Петров",
+			Phone:     "+79161234567",
+		}
+	}
+	tests := []struct {
+		name       string
+		change     func(in *AddressInput)
+		wantFields []string
+	}{
+		{"valid", func(in *AddressInput) {}, nil},
+		{"with a location", func(in *AddressInput) { in.Location = &Location{Lat: 55.76, Lng: 37.61} }, nil},
+		{"required fields", func(in *AddressInput) { *in = AddressInput{} },
+			[]string{"country", "city", "street", "building", "recipient", "postcode", "phone"}},
+		{"country name", func(in *AddressInput) { in.Country = "RUSSIA" }, []string{"country"}},
+		{"too long", func(in *AddressInput) { in.Street = strings.Repeat("я", maxAddressFieldLength+1) }, []string{"street"}},
+		{"postcode of another country", func(in *AddressInput) { in.Postcode = "10115" }, []string{"postcode"}},
+		{"postcode of the country", func(in *AddressInput) {
+			in.Country = "DE"
+			in.Postcode = "10115"
+		}, nil},
+		{"other country postcode", func(in *AddressInput) {
+			in.Country = "GB"
+			in.Postcode = "SW1A 1AA"
+		}, nil},
+		{"bad other country postcode", func(in *AddressInput) {
+			in.Country = "GB"
+			in.Postcode = "SW1A_1AA"
+		}, []string{"postcode"}},
+		{"local phone", func(in *AddressInput) { in.Phone = "89161234567" }, []string{"phone"}},
+		{"bad location", func(in *AddressInput) { in.Location = &Location{Lat: 91} }, []string{"location"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid()
+			tt.change(in)
+			err := in.Validate()
+			if fields := fieldsOf(err); !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("got %v, want invalid fields %v", err, tt.wantFields)
+			}
+		})
+	}
+}
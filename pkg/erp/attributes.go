@@ -0,0 +1,170 @@
+package erp
+
+import (
+	"errors"
+	"strings"
+)
+
+// Attribute errors.
+var (
+	ErrAttributeUnknown  = errors.New("attribute is not in the attribute dictionary")
+	ErrVariationNotFound = errors.New("variation not found")
+)
+
+// Attribute is a value of the attribute dictionary, see deploy/mongo/attributes.json.
+// Products and variations may only have attributes named in the dictionary.
+// Count is the number of products and variations having the value.
+type Attribute struct {
+	ID    string `bson:"_id" json:"id"`
+	Name  string `bson:"name" json:"name"`
+	Value string `bson:"value" json:"value"`
+	Count int    `bson:"count" json:"count"`
+}
+
+// AttributeKey returns the facet name of an attribute, the lower cased attribute name.
+func AttributeKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// AttributeID returns the dictionary id of an attribute value, like "материалы=semi-gloss".
+// It is also the facet id the catalog is filtered by.
+func AttributeID(name string, value string) string {
+	return FacetID(AttributeKey(name), value)
+}
+
+// AttributeDictionary maps attribute keys to the attribute names of the dictionary.
+type AttributeDictionary map[string]string
+
+// NewAttributeDictionary returns the attribute names of the dictionary values.
+func NewAttributeDictionary(attributes []*Attribute) AttributeDictionary {
+	dict := AttributeDictionary{}
+	for _, a := range attributes {
+		if key, _, ok := ParseFacetID(a.ID); ok {
+			dict[key] = a.Name
+		}
+	}
+	return dict
+}
+
+// Name returns the dictionary name of the attribute, the name is matched by its key.
+func (d AttributeDictionary) Name(name string) (string, bool) {
+	dictName, ok := d[AttributeKey(name)]
+	if !ok {
+		return name, false
+	}
+	return dictName, true
+}
+
+// ProductAttribute is an attribute of a product, products may have several values of an attribute.
+type ProductAttribute struct {
+	Name  string   `bson:"name" json:"name"`
+	Value []string `bson:"value" json:"value,omitempty"`
+}
+
+// AttributeEdit adds or removes values of an attribute of a product or of a variation.
+// Variations have a single value per attribute, adding one replaces the current value.
+type AttributeEdit struct {
+	ProductID string   `json:"product_id"`
+	SKU       string   `json:"sku"`
+	Name      string   `json:"name"`
+	Values    []string `json:"values"`
+}
+
+// Validate checks the edit, remove tells whether the values are to be removed.
+// Removing no values removes the attribute.
+func (e *AttributeEdit) Validate(remove bool) error {
+	if (e.ProductID == "") == (e.SKU == "") {
+		return errors.New("either product_id or sku is required")
+	}
+	e.Name = strings.TrimSpace(e.Name)
+	if e.Name == "" {
+		return errors.New("attribute name is required")
+	}
+	values := e.Values[:0]
+	for _, v := range e.Values {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	e.Values = values
+	if remove {
+		return nil
+	}
+	if len(e.Values) == 0 {
+		return errors.New("attribute values are required")
+	}
+	if e.SKU != "" && len(e.Values) > 1 {
+		return errors.New("variations have a single value per attribute")
+	}
+	return nil
+}
+
+// AddAttributeValues adds the values to the named product attribute and returns the attributes
+// with the values that were not there yet. The attribute is matched by its key.
+func AddAttributeValues(attrs []*ProductAttribute, name string, values []string) ([]*ProductAttribute, []string) {
+	var attr *ProductAttribute
+	for _, a := range attrs {
+		if AttributeKey(a.Name) == AttributeKey(name) {
+			attr = a
+			break
+		}
+	}
+	if attr == nil {
+		attr = &ProductAttribute{Name: name}
+		attrs = append(attrs, attr)
+	}
+	var added []string
+	for _, v := range values {
+		if !containsString(attr.Value, v) {
+			attr.Value = append(attr.Value, v)
+			added = append(added, v)
+		}
+	}
+	return attrs, added
+}
+
+// RemoveAttributeValues removes the values of the named product attribute, all of them when none are given,
+// and returns the attributes with the values that were removed.
+// Attributes left without values are removed.
+func RemoveAttributeValues(attrs []*ProductAttribute, name string, values []string) ([]*ProductAttribute, []string) {
+	var removed []string
+	result := attrs[:0]
+	for _, a := range attrs {
+		if AttributeKey(a.Name) == AttributeKey(name) {
+			kept := a.Value[:0]
+			for _, v := range a.Value {
+				if len(values) == 0 || containsString(values, v) {
+					removed = append(removed, v)
+				} else {
+					kept = append(kept, v)
+				}
+			}
+			a.Value = kept
+			if len(a.Value) == 0 {
+				continue
+			}
+		}
+		result = append(result, a)
+	}
+	return result, removed
+}
+
+// AttributeIDs returns the facet ids of the product attribute values.
+func AttributeIDs(attrs []*ProductAttribute) []string {
+	ids := []string{}
+	for _, a := range attrs {
+		for _, v := range a.Value {
+			ids = append(ids, AttributeID(a.Name, v))
+		}
+	}
+	return ids
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -9,21 +9,56 @@ import (
 // CartItem ..
 type Cart struct {
 	ID        primitive.ObjectID `bson:"_id" json:"id,omitempty"`
+	UserID    string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	OrderID   string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
 	Status    string             `bson:"status" json:"status"`
 	Type      string             `bson:"type" json:"type"`
+	Coupon    string             `bson:"coupon,omitempty" json:"coupon,omitempty"`
+	Version   int64              `bson:"version" json:"version"`
 	Products  []*CartProduct     `bson:"products" json:"products"`
 	CreatedAt time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
 	UpdatedAt time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
 }
 
+// AnyCartVersion skips the cart version check on update.
+// Cart.Version is incremented on every change of the cart.
+const AnyCartVersion int64 = -1
+
+// Cart states, see deploy/mongo/cart.md.
+const (
+	CartStatusActive    = "active"
+	CartStatusCompleted = "completed"
+	CartStatusExpired   = "expired"
+)
+
 type CartProduct struct {
-	Name      string    `json:"name"`
-	SKU       string    `json:"sku"`
-	Price     int       `json:"price"`
-	Quantity  int       `json:"quantity"`
-	Size      string    `json:"size"`
-	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
-	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	Name         string    `json:"name"`
+	SKU          string    `json:"sku"`
+	Price        Money     `json:"price"`
+	RegularPrice *Money    `bson:"regular_price,omitempty" json:"regular_price,omitempty"`
+	LineTotal    Money     `bson:"line_total,omitempty" json:"line_total"`
+	Quantity     int       `json:"quantity"`
+	Size         string    `json:"size"`
+	CreatedAt    time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt    time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// PricedCart is a cart priced with the current prices of a price list, sales and the user discount.
+type PricedCart struct {
+	Version  int64          `json:"version"`
+	Currency Currency       `json:"currency"`
+	Products []*CartProduct `json:"products"`
+	Quantity int            `json:"quantity"`
+	Subtotal Money          `json:"subtotal"`
+	Discount Money          `json:"discount"`
+	Total    Money          `json:"total"`
+	Coupon   *AppliedCoupon `json:"coupon,omitempty"`
+	// CouponError tells why the coupon stored in the cart does not apply anymore.
+	CouponError string `json:"coupon_error,omitempty"`
+	// Unpriced lists SKUs without a price, such a cart can't be ordered.
+	Unpriced []string `json:"unpriced,omitempty"`
+	// ExchangeRate is the rate of the price list the cart is priced with.
+	ExchangeRate *ExchangeRate `json:"exchange_rate"`
 }
 
 type CartUser struct {
@@ -32,6 +67,6 @@ type CartUser struct {
 }
 
 type HeaderCartInfo struct {
-	Price    int `json:"price"`
-	Quantity int `json:"quantity"`
+	Price    Money `json:"price"`
+	Quantity int   `json:"quantity"`
 }
@@ -1,22 +1,136 @@
 package erp
 
-import "time"
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
 
-// Category ..
+// Category tree errors.
+var (
+	ErrCategoryNotFound         = errors.New("category not found")
+	ErrCategoryParentNotFound   = errors.New("parent category not found")
+	ErrCategoryCycle            = errors.New("category can't be moved into its own subtree")
+	ErrCategoryHasSubcategories = errors.New("category has subcategories")
+	ErrCategoryHasProducts      = errors.New("category has products")
+	ErrCategoryOrderMismatch    = errors.New("categories to reorder don't match the subcategories")
+)
+
+var categoryIDRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Category is a node of the category tree, see deploy/mongo/categories.json.
+// Root categories have no parents.
+// Ancestors are materialized from the root down to the parent,
+// so a subtree is found with a single query on the ancestors index.
 type Category struct {
-	ID         string         `bson:"_id" json:"id,omitempty"`
-	Name       []*LangValue   `json:"name"`
-	Ancestors  []*Subcategory `json:"ancestors"`
-	CreatedOn  time.Time      `json:"createdOn"`
-	ModifiedOn time.Time      `json:"modifiedOn"`
+	ID          string       `bson:"_id" json:"id,omitempty"`
+	Name        []*LangValue `bson:"name" json:"name"`
+	Description []*LangValue `bson:"description" json:"description"`
+	// Parents holds the parent id, a category has one parent at most.
+	Parents   []string `bson:"parents" json:"parents"`
+	Ancestors []string `bson:"ancestors" json:"ancestors"`
+	// Position orders the subcategories of a parent.
+	Position      int         `bson:"position" json:"position"`
+	Facets        []string    `bson:"facets" json:"facets"`
+	Subcategories []*Category `bson:"-" json:"subcategories,omitempty"`
+	CreatedOn     time.Time   `bson:"created_on" json:"createdOn"`
+	ModifiedOn    time.Time   `bson:"modified_on" json:"modifiedOn"`
 }
 
-type Subcategory struct {
-	ID          string       `bson:"_id" json:"id,omitempty"`
+// Subcategory is a category below a root one, they are stored alike.
+type Subcategory = Category
+
+// Parent returns the parent id, empty for root categories.
+func (c *Category) Parent() string {
+	if len(c.Parents) == 0 {
+		return ""
+	}
+	return c.Parents[0]
+}
+
+// Path returns the category path the variations refer to, like /wall-art/posters-without-frame.
+func (c *Category) Path() string {
+	return CategoryPath(append(append([]string{}, c.Ancestors...), c.ID))
+}
+
+// MoveTo returns the ancestors the category gets under the parent,
+// or no ancestors if the parent is nil and the category becomes a root one.
+// A category can't be moved into its own subtree.
+func (c *Category) MoveTo(parent *Category) ([]string, error) {
+	if parent == nil {
+		return []string{}, nil
+	}
+	if parent.ID == c.ID {
+		return nil, ErrCategoryCycle
+	}
+	for _, ancestor := range parent.Ancestors {
+		if ancestor == c.ID {
+			return nil, ErrCategoryCycle
+		}
+	}
+	return append(append([]string{}, parent.Ancestors...), parent.ID), nil
+}
+
+// CategoryPath joins category ids into a category path.
+func CategoryPath(ids []string) string {
+	return "/" + strings.Join(ids, "/")
+}
+
+// CategoryInput is a category to create.
+type CategoryInput struct {
+	ID          string       `json:"id"`
+	Parent      string       `json:"parent"`
 	Name        []*LangValue `json:"name"`
 	Description []*LangValue `json:"description"`
-	Parents     []string     `json:"parents"`
 	Facets      []string     `json:"facets"`
-	CreatedOn   time.Time    `json:"createdOn"`
-	ModifiedOn  time.Time    `json:"modifiedOn"`
+}
+
+// Validate checks the category to create.
+// Ids end up in category paths and URLs, so they are lower case slugs.
+func (in *CategoryInput) Validate() error {
+	if !categoryIDRegexp.MatchString(in.ID) {
+		return errors.New("category id must be a lower case slug like posters-without-frame")
+	}
+	if in.Parent == in.ID {
+		return ErrCategoryCycle
+	}
+	return ValidateCategoryName(in.Name)
+}
+
+// ValidateCategoryName checks that the category is named at least in one language.
+func ValidateCategoryName(name []*LangValue) error {
+	for _, n := range name {
+		if n != nil && strings.TrimSpace(n.Lang) != "" && strings.TrimSpace(n.Value) != "" {
+			return nil
+		}
+	}
+	return errors.New("category name is required")
+}
+
+// BuildCategoryTree links the categories to their parents and returns the topmost ones,
+// the ones whose parent is not among the categories, in the given order.
+// Subcategories are ordered by position.
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[string]*Category, len(categories))
+	for _, c := range categories {
+		c.Subcategories = nil
+		byID[c.ID] = c
+	}
+	var top []*Category
+	for _, c := range categories {
+		if parent, ok := byID[c.Parent()]; ok {
+			parent.Subcategories = append(parent.Subcategories, c)
+		} else {
+			top = append(top, c)
+		}
+	}
+	for _, c := range categories {
+		sub := c.Subcategories
+		sort.SliceStable(sub, func(i, j int) bool {
+			return sub[i].Position < sub[j].Position
+		})
+	}
+	return top
 }
@@ -0,0 +1,97 @@
+package erp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCategoryMoveTo(t *testing.T) {
+	wallArt := &Category{ID: "wall-art", Ancestors: []string{}}
+	posters := &Category{ID: "posters", Parents: []string{"wall-art"}, Ancestors: []string{"wall-art"}}
+	framed := &Category{ID: "framed", Parents: []string{"posters"}, Ancestors: []string{"wall-art", "posters"}}
+	mugs := &Category{ID: "mugs", Ancestors: []string{}}
+	tests := []struct {
+		name          string
+		category      *Category
+		parent        *Category
+		wantAncestors []string
+		wantPath      string
+		wantErr       error
+	}{
+		{"to a root category", framed, mugs, []string{"mugs"}, "/mugs/framed", nil},
+		{"to a subcategory", mugs, posters, []string{"wall-art", "posters"}, "/wall-art/posters/mugs", nil},
+		{"to the root", framed, nil, []string{}, "/framed", nil},
+		{"up the tree", framed, wallArt, []string{"wall-art"}, "/wall-art/framed", nil},
+		{"under itself", posters, posters, nil, "", ErrCategoryCycle},
+		{"under a subcategory", wallArt, framed, nil, "", ErrCategoryCycle},
+		{"under a direct subcategory", posters, framed, nil, "", ErrCategoryCycle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ancestors, err := tt.category.MoveTo(tt.parent)
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ancestors, tt.wantAncestors) {
+				t.Errorf("ancestors %v, want %v", ancestors, tt.wantAncestors)
+			}
+			if err == nil {
+				if path := CategoryPath(append(ancestors, tt.category.ID)); path != tt.wantPath {
+					t.Errorf("path %s, want %s", path, tt.wantPath)
+				}
+			}
+		})
+	}
+	if len(posters.Ancestors) != 1 {
+		t.Errorf("parent ancestors changed to %v", posters.Ancestors)
+	}
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	categories := []*Category{
+		{ID: "wall-art"},
+		{ID: "frames", Parents: []string{"wall-art"}, Position: 2},
+		{ID: "posters", Parents: []string{"wall-art"}, Position: 1},
+		{ID: "framed", Parents: []string{"posters"}},
+		{ID: "mugs"},
+		{ID: "orphan", Parents: []string{"gone"}},
+	}
+	top := BuildCategoryTree(categories)
+
+	var ids []string
+	for _, c := range top {
+		ids = append(ids, c.ID)
+	}
+	if want := []string{"wall-art", "mugs", "orphan"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("top categories %v, want %v", ids, want)
+	}
+	sub := top[0].Subcategories
+	if len(sub) != 2 || sub[0].ID != "posters" || sub[1].ID != "frames" {
+		t.Fatalf("subcategories of wall-art %v, want posters and frames", sub)
+	}
+	if len(sub[0].Subcategories) != 1 || sub[0].Subcategories[0].ID != "framed" {
+		t.Errorf("subcategories of posters %v, want framed", sub[0].Subcategories)
+	}
+}
+
+func TestCategoryInputValidate(t *testing.T) {
+	name := []*LangValue{{Lang: "ru", Value: "Постеры"}}
+	tests := []struct {
+		name    string
+		input   *CategoryInput
+		wantErr bool
+	}{
+		{"valid", &CategoryInput{ID: "posters-without-frame", Parent: "wall-art", Name: name}, false},
+		{"upper case id", &CategoryInput{ID: "Posters", Name: name}, true},
+		{"path in id", &CategoryInput{ID: "wall-art/posters", Name: name}, true},
+		{"own parent", &CategoryInput{ID: "posters", Parent: "posters", Name: name}, true},
+		{"no name", &CategoryInput{ID: "posters", Name: []*LangValue{{Lang: "ru", Value: " "}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.input.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
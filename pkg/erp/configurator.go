@@ -0,0 +1,283 @@
+package erp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Configurator errors.
+var ErrConfiguratorNotFound = errors.New("configurator not found")
+
+var optionCodeRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Configurator builds the variations of a product from option values, see deploy/mongo/configurators.json.
+// The SKU of a combination is the SKU prefix followed by the codes of the selected values,
+// like poster_250x400_no-frame_no-mat_luster-photo-paper, its price is the base price
+// plus the surcharges of the selected values.
+type Configurator struct {
+	ProductID string                `bson:"_id" json:"product_id"`
+	SKUPrefix string                `bson:"sku_prefix" json:"sku_prefix"`
+	BasePrice Money                 `bson:"base_price" json:"base_price"`
+	Options   []*ConfiguratorOption `bson:"options" json:"options"`
+	// Exclusions are the unavailable combinations, option names to values.
+	// A selection having all the values of an exclusion is rejected.
+	Exclusions []map[string]string `bson:"exclusions" json:"exclusions"`
+	ModifiedOn time.Time           `bson:"modified_on" json:"modifiedOn"`
+}
+
+// ConfiguratorOption is a variation attribute the customer selects a value of.
+type ConfiguratorOption struct {
+	Name   string               `bson:"name" json:"name"`
+	Values []*ConfiguratorValue `bson:"values" json:"values"`
+}
+
+// ConfiguratorValue is a value of an option, Code stands for it in SKUs.
+type ConfiguratorValue struct {
+	Value     string `bson:"value" json:"value"`
+	Code      string `bson:"code" json:"code"`
+	Surcharge Money  `bson:"surcharge" json:"surcharge"`
+}
+
+// ConfiguratorInput is a configurator to set, prices are decimal strings like "69.99" in DefaultCurrency.
+type ConfiguratorInput struct {
+	SKUPrefix  string                     `json:"sku_prefix"`
+	BasePrice  string                     `json:"base_price"`
+	Options    []*ConfiguratorOptionInput `json:"options"`
+	Exclusions []map[string]string        `json:"exclusions"`
+}
+
+// ConfiguratorOptionInput is an option of a configurator to set.
+type ConfiguratorOptionInput struct {
+	Name   string                    `json:"name"`
+	Values []*ConfiguratorValueInput `json:"values"`
+}
+
+// ConfiguratorValueInput is an option value of a configurator to set, the surcharge is optional.
+type ConfiguratorValueInput struct {
+	Value     string `json:"value"`
+	Code      string `json:"code"`
+	Surcharge string `json:"surcharge"`
+}
+
+// Validate checks the configurator, option names have to be in the attribute dictionary
+// and are replaced with the dictionary names.
+func (in *ConfiguratorInput) Validate(dict AttributeDictionary) error {
+	var v Validation
+	if !productIDRegexp.MatchString(in.SKUPrefix) {
+		v.Add("sku_prefix", "must be letters and digits like poster")
+	}
+	if _, err := ParsePrice(in.BasePrice); err != nil {
+		v.Add("base_price", "%s", err)
+	}
+	if len(in.Options) == 0 {
+		v.Add("options", "at least one option is required")
+	}
+	options := map[string]map[string]bool{}
+	for i, o := range in.Options {
+		field := fmt.Sprintf("options[%d]", i)
+		if o == nil {
+			v.Add(field, "is empty")
+			continue
+		}
+		name, ok := dict.Name(o.Name)
+		if !ok {
+			v.Add(field+".name", "%q is not in the attribute dictionary", o.Name)
+		}
+		o.Name = name
+		key := AttributeKey(o.Name)
+		if _, ok := options[key]; ok {
+			v.Add(field+".name", "duplicates another option")
+		}
+		values := map[string]bool{}
+		options[key] = values
+		if len(o.Values) == 0 {
+			v.Add(field+".values", "at least one value is required")
+		}
+		codes := map[string]bool{}
+		for j, val := range o.Values {
+			valueField := fmt.Sprintf("%s.values[%d]", field, j)
+			if val == nil {
+				v.Add(valueField, "is empty")
+				continue
+			}
+			val.Value = strings.TrimSpace(val.Value)
+			if val.Value == "" {
+				v.Add(valueField+".value", "is required")
+			} else if values[val.Value] {
+				v.Add(valueField+".value", "duplicates another value of the option")
+			}
+			values[val.Value] = true
+			if !optionCodeRegexp.MatchString(val.Code) {
+				v.Add(valueField+".code", "must be lower case letters and digits like no-frame")
+			} else if codes[val.Code] {
+				v.Add(valueField+".code", "duplicates another code of the option")
+			}
+			codes[val.Code] = true
+			if val.Surcharge != "" {
+				if _, err := ParsePrice(val.Surcharge); err != nil {
+					v.Add(valueField+".surcharge", "%s", err)
+				}
+			}
+		}
+	}
+	for i, e := range in.Exclusions {
+		field := fmt.Sprintf("exclusions[%d]", i)
+		if len(e) == 0 {
+			v.Add(field, "at least one option value is required")
+		}
+		for name, value := range e {
+			values, ok := options[AttributeKey(name)]
+			if !ok {
+				v.Add(field, "%q is not an option", name)
+			} else if !values[value] {
+				v.Add(field, "%q is not a value of option %s", value, name)
+			}
+		}
+	}
+	return v.Err()
+}
+
+// Configurator returns the configurator of a validated input.
+func (in *ConfiguratorInput) Configurator(productID string, modifiedTime time.Time) *Configurator {
+	c := &Configurator{
+		ProductID:  productID,
+		SKUPrefix:  in.SKUPrefix,
+		Options:    make([]*ConfiguratorOption, 0, len(in.Options)),
+		Exclusions: make([]map[string]string, 0, len(in.Exclusions)),
+		ModifiedOn: modifiedTime.Round(time.Second),
+	}
+	c.BasePrice, _ = ParsePrice(in.BasePrice)
+	for _, o := range in.Options {
+		option := &ConfiguratorOption{Name: o.Name, Values: make([]*ConfiguratorValue, 0, len(o.Values))}
+		for _, val := range o.Values {
+			value := &ConfiguratorValue{Value: val.Value, Code: val.Code, Surcharge: Money{Currency: DefaultCurrency}}
+			if val.Surcharge != "" {
+				value.Surcharge, _ = ParsePrice(val.Surcharge)
+			}
+			option.Values = append(option.Values, value)
+		}
+		c.Options = append(c.Options, option)
+	}
+	for _, e := range in.Exclusions {
+		exclusion := make(map[string]string, len(e))
+		for name, value := range e {
+			exclusion[AttributeKey(name)] = value
+		}
+		c.Exclusions = append(c.Exclusions, exclusion)
+	}
+	return c
+}
+
+// Configuration is a combination of option values of a product.
+// Price is the price of the combination in DefaultCurrency, variations created
+// before the configurator keep the price they were created with.
+type Configuration struct {
+	SKU        string       `json:"sku"`
+	Attributes []*NameValue `json:"attributes"`
+	Price      Money        `json:"price"`
+	// Created tells whether the variation of the combination was created by the configuration.
+	Created bool `json:"created"`
+	// Pricing is the pricing of the variation in the price list of the market.
+	Pricing *Pricing `json:"pricing"`
+}
+
+// Configure returns the combination of the selected values, option names to values.
+// Every option needs a value, unavailable combinations are rejected.
+func (c *Configurator) Configure(selected map[string]string) (*Configuration, error) {
+	var v Validation
+	values := make(map[string]string, len(selected))
+	for name, value := range selected {
+		values[AttributeKey(name)] = strings.TrimSpace(value)
+	}
+
+	conf := &Configuration{
+		Attributes: make([]*NameValue, 0, len(c.Options)),
+		Price:      c.BasePrice,
+	}
+	codes := []string{c.SKUPrefix}
+	for _, o := range c.Options {
+		key := AttributeKey(o.Name)
+		value, ok := values[key]
+		if !ok {
+			v.Add("options."+key, "is required")
+			continue
+		}
+		delete(values, key)
+		var found *ConfiguratorValue
+		for _, val := range o.Values {
+			if val.Value == value {
+				found = val
+				break
+			}
+		}
+		if found == nil {
+			v.Add("options."+key, "%q is not a value of the option", value)
+			continue
+		}
+		codes = append(codes, found.Code)
+		conf.Attributes = append(conf.Attributes, &NameValue{Name: o.Name, Value: found.Value})
+		price, err := conf.Price.Add(found.Surcharge)
+		if err != nil {
+			return nil, fmt.Errorf("surcharge of %s %s: %w", o.Name, found.Value, err)
+		}
+		conf.Price = price
+	}
+	for key := range values {
+		v.Add("options."+key, "is not an option of the product")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range c.Exclusions {
+		if conf.Excludes(e) {
+			v.Add("options", "the combination is unavailable")
+			return nil, v.Err()
+		}
+	}
+	conf.SKU = strings.Join(codes, "_")
+	return conf, nil
+}
+
+// Excludes reports whether the combination has all the values of the exclusion.
+func (c *Configuration) Excludes(exclusion map[string]string) bool {
+	if len(exclusion) == 0 {
+		return false
+	}
+	for name, value := range exclusion {
+		if !c.Has(name, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Has reports whether the combination has the value of the named option.
+func (c *Configuration) Has(name string, value string) bool {
+	for _, a := range c.Attributes {
+		if AttributeKey(a.Name) == AttributeKey(name) && a.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the variation has the values of the combination, it may have other attributes too.
+func (c *Configuration) Matches(variation *VariationInput) bool {
+	for _, a := range c.Attributes {
+		found := false
+		for _, va := range variation.Attributes {
+			if va != nil && AttributeKey(va.Name) == AttributeKey(a.Name) && va.Value == a.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
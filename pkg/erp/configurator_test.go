@@ -0,0 +1,181 @@
+package erp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestConfigurator() *Configurator {
+	rub := func(kopecks int64) Money { return NewMoney(kopecks, DefaultCurrency) }
+	return &Configurator{
+		ProductID: "poster",
+		SKUPrefix: "poster",
+		BasePrice: rub(99000),
+		Options: []*ConfiguratorOption{
+			{Name: "Размер", Values: []*ConfiguratorValue{
+				{Value: "250x400", Code: "250x400", Surcharge: rub(0)},
+				{Value: "500x700", Code: "500x700", Surcharge: rub(50000)},
+			}},
+			{Name: "Рама", Values: []*ConfiguratorValue{
+				{Value: "Без рамы", Code: "no-frame", Surcharge: rub(0)},
+				{Value: "Дерево", Code: "wood", Surcharge: rub(120050)},
+			}},
+		},
+		Exclusions: []map[string]string{{"размер": "250x400", "рама": "Дерево"}},
+	}
+}
+
+// fieldsOf returns the invalid fields of a validation error.
+func fieldsOf(err error) []string {
+	e, ok := err.(*ServiceError)
+	if !ok {
+		return nil
+	}
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+func TestConfiguratorConfigure(t *testing.T) {
+	tests := []struct {
+		name       string
+		selected   map[string]string
+		wantSKU    string
+		wantPrice  Money
+		wantFields []string
+	}{
+		{
+			name:      "base price",
+			selected:  map[string]string{"Размер": "250x400", "Рама": "Без рамы"},
+			wantSKU:   "poster_250x400_no-frame",
+			wantPrice: NewMoney(99000, DefaultCurrency),
+		},
+		{
+			name:      "surcharges",
+			selected:  map[string]string{"размер": " 500x700 ", "РАМА": "Дерево"},
+			wantSKU:   "poster_500x700_wood",
+			wantPrice: NewMoney(269050, DefaultCurrency),
+		},
+		{
+			name:       "missing option",
+			selected:   map[string]string{"Размер": "500x700"},
+			wantFields: []string{"options.рама"},
+		},
+		{
+			name:       "unknown value",
+			selected:   map[string]string{"Размер": "100x100", "Рама": "Дерево"},
+			wantFields: []string{"options.размер"},
+		},
+		{
+			name:       "unknown option",
+			selected:   map[string]string{"Размер": "500x700", "Рама": "Дерево", "Цвет": "Белый"},
+			wantFields: []string{"options.цвет"},
+		},
+		{
+			name:       "unavailable combination",
+			selected:   map[string]string{"Размер": "250x400", "Рама": "Дерево"},
+			wantFields: []string{"options"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := newTestConfigurator().Configure(tt.selected)
+			if tt.wantFields != nil {
+				if fields := fieldsOf(err); !reflect.DeepEqual(fields, tt.wantFields) {
+					t.Fatalf("got %v, %v, want invalid fields %v", conf, err, tt.wantFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.SKU != tt.wantSKU || conf.Price != tt.wantPrice {
+				t.Errorf("got %s at %v, want %s at %v", conf.SKU, conf.Price, tt.wantSKU, tt.wantPrice)
+			}
+			if len(conf.Attributes) != 2 || conf.Attributes[0].Name != "Размер" || conf.Attributes[1].Name != "Рама" {
+				t.Errorf("attributes %v, want the option names in order", conf.Attributes)
+			}
+		})
+	}
+}
+
+func TestConfigurationMatches(t *testing.T) {
+	conf, err := newTestConfigurator().Configure(map[string]string{"Размер": "500x700", "Рама": "Дерево"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		attributes []*NameValue
+		want       bool
+	}{
+		{"same values", []*NameValue{{Name: "рама", Value: "Дерево"}, {Name: "Размер", Value: "500x700"}}, true},
+		{"more attributes", []*NameValue{{Name: "Размер", Value: "500x700"}, {Name: "Рама", Value: "Дерево"}, {Name: "Бумага", Value: "Матовая"}}, true},
+		{"other value", []*NameValue{{Name: "Размер", Value: "500x700"}, {Name: "Рама", Value: "Без рамы"}}, false},
+		{"missing option", []*NameValue{{Name: "Размер", Value: "500x700"}, nil}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conf.Matches(&VariationInput{Attributes: tt.attributes}); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfiguratorInputValidate(t *testing.T) {
+	dict := AttributeDictionary{"размер": "Размер", "рама": "Рама"}
+	valid := func() *ConfiguratorInput {
+		return &ConfiguratorInput{
+			SKUPrefix: "poster",
+			BasePrice: "990",
+			Options: []*ConfiguratorOptionInput{
+				{Name: "размер", Values: []*ConfiguratorValueInput{{Value: "250x400", Code: "250x400"}, {Value: "500x700", Code: "500x700", Surcharge: "500"}}},
+				{Name: "Рама", Values: []*ConfiguratorValueInput{{Value: "Дерево", Code: "wood"}}},
+			},
+			Exclusions: []map[string]string{{"Размер": "250x400", "Рама": "Дерево"}},
+		}
+	}
+	tests := []struct {
+		name       string
+		change     func(in *ConfiguratorInput)
+		wantFields []string
+	}{
+		{"valid", func(in *ConfiguratorInput) {}, nil},
+		{"bad prefix and price", func(in *ConfiguratorInput) {
+			in.SKUPrefix = "poster 1"
+			in.BasePrice = "-1"
+		}, []string{"sku_prefix", "base_price"}},
+		{"not in the dictionary", func(in *ConfiguratorInput) {
+			in.Options[1].Name = "Цвет"
+			in.Exclusions = nil
+		}, []string{"options[1].name"}},
+		{"duplicate option", func(in *ConfiguratorInput) {
+			in.Options[1].Name = "Размер"
+			in.Exclusions = nil
+		}, []string{"options[1].name"}},
+		{"bad values", func(in *ConfiguratorInput) {
+			in.Options[0].Values[1].Value = "250x400"
+			in.Options[0].Values[1].Code = "250X400"
+			in.Options[0].Values[1].Surcharge = "abc"
+		}, []string{"options[0].values[1].value", "options[0].values[1].code", "options[0].values[1].surcharge"}},
+		{"exclusion of an unknown value", func(in *ConfiguratorInput) {
+			in.Exclusions[0]["Рама"] = "Металл"
+		}, []string{"exclusions[0]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid()
+			tt.change(in)
+			err := in.Validate(dict)
+			if fields := fieldsOf(err); !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Fatalf("got %v, want invalid fields %v", err, tt.wantFields)
+			}
+			if err == nil && in.Options[0].Name != "Размер" {
+				t.Errorf("option name %q, want the dictionary name", in.Options[0].Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package erp
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Coupon-related errors.
+var (
+	ErrCouponNotActive     = errors.New("coupon is not active")
+	ErrCouponExhausted     = errors.New("coupon usage limit is reached")
+	ErrCouponMinCartValue  = errors.New("cart value is below the coupon minimum")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to the cart products")
+	ErrCouponLoginRequired = errors.New("log in to use the coupon")
+)
+
+type CouponType string
+
+const (
+	CouponTypePercent      CouponType = "percent"
+	CouponTypeFixed        CouponType = "fixed"
+	CouponTypeFreeShipping CouponType = "free_shipping"
+)
+
+// Coupon is a promo code giving a discount on the cart, see deploy/mongo/coupons.json.
+type Coupon struct {
+	Code string     `bson:"_id" json:"code"`
+	Type CouponType `bson:"type" json:"type"`
+	// Value is a percentage for percent coupons and an amount in DefaultCurrency for fixed ones.
+	// Amounts are converted to the cart currency at the exchange rate of the cart.
+	Value     float64   `bson:"value" json:"value"`
+	ValidFrom time.Time `bson:"valid_from,omitempty" json:"valid_from,omitempty"`
+	ValidTo   time.Time `bson:"valid_to,omitempty" json:"valid_to,omitempty"`
+	// UsageLimit and UsageLimitPerUser are unlimited when zero.
+	UsageLimit        int   `bson:"usage_limit" json:"usage_limit"`
+	UsageLimitPerUser int   `bson:"usage_limit_per_user" json:"usage_limit_per_user"`
+	MinCartValue      Money `bson:"min_cart_value" json:"min_cart_value"`
+	// SKUs and Categories restrict the discount to the matching products.
+	SKUs        []string            `bson:"skus,omitempty" json:"skus,omitempty"`
+	Categories  []string            `bson:"categories,omitempty" json:"categories,omitempty"`
+	Redemptions []*CouponRedemption `bson:"redemptions" json:"-"`
+	CreatedOn   time.Time           `bson:"created_on" json:"createdOn"`
+	ModifiedOn  time.Time           `bson:"modified_on" json:"modifiedOn"`
+}
+
+// CouponRedemption is a use of a coupon by an order.
+type CouponRedemption struct {
+	OrderID    string    `bson:"order_id" json:"order_id"`
+	UserID     string    `bson:"user_id" json:"user_id"`
+	RedeemedOn time.Time `bson:"redeemed_on" json:"redeemed_on"`
+}
+
+// AppliedCoupon is the discount a coupon gave to a cart.
+type AppliedCoupon struct {
+	Code         string     `bson:"code" json:"code"`
+	Type         CouponType `bson:"type" json:"type"`
+	Discount     Money      `bson:"discount" json:"discount"`
+	FreeShipping bool       `bson:"free_shipping" json:"free_shipping"`
+}
+
+// NormalizeCouponCode makes codes typed by customers case insensitive.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Check checks that the coupon can be used by the user at the given time.
+// Coupons limited per user need a registered user, anonymous users get a new id
+// with every cookie and could redeem them without a limit.
+func (c *Coupon) Check(userID string, registered bool, now time.Time) error {
+	if !c.ValidFrom.IsZero() && now.Before(c.ValidFrom) {
+		return ErrCouponNotActive
+	}
+	if !c.ValidTo.IsZero() && !now.Before(c.ValidTo) {
+		return ErrCouponNotActive
+	}
+	if c.UsageLimit > 0 && len(c.Redemptions) >= c.UsageLimit {
+		return ErrCouponExhausted
+	}
+	if c.UsageLimitPerUser > 0 && !registered {
+		return ErrCouponLoginRequired
+	}
+	if c.UsageLimitPerUser > 0 && c.RedeemedBy(userID) >= c.UsageLimitPerUser {
+		return ErrCouponExhausted
+	}
+	return nil
+}
+
+// RedeemedBy returns how many times the user has redeemed the coupon.
+func (c *Coupon) RedeemedBy(userID string) int {
+	var n int
+	for _, r := range c.Redemptions {
+		if r.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+// IsRestricted reports whether the coupon applies only to some products.
+func (c *Coupon) IsRestricted() bool {
+	return len(c.SKUs) > 0 || len(c.Categories) > 0
+}
+
+// AppliesTo reports whether the coupon applies to the SKU listed in the categories.
+// The categories hold the ancestors of the product categories too, so a coupon
+// for a category applies to all its subcategories.
+func (c *Coupon) AppliesTo(sku string, categories []string) bool {
+	if !c.IsRestricted() {
+		return true
+	}
+	for _, s := range c.SKUs {
+		if s == sku {
+			return true
+		}
+	}
+	for _, category := range categories {
+		for _, cat := range c.Categories {
+			if category == cat {
+				return true
+			}
+		}
+	}
+	return false
+}
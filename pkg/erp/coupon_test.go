@@ -0,0 +1,62 @@
+package erp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCouponCheck(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	redemptions := []*CouponRedemption{{OrderID: "1", UserID: "ann"}, {OrderID: "2", UserID: "bob"}}
+	tests := []struct {
+		name       string
+		coupon     *Coupon
+		userID     string
+		registered bool
+		wantErr    error
+	}{
+		{"no limits", &Coupon{}, "ann", true, nil},
+		{"anonymous user", &Coupon{UsageLimit: 3}, "tmp", false, nil},
+		{"within the window", &Coupon{ValidFrom: now.Add(-time.Hour), ValidTo: now.Add(time.Hour)}, "ann", true, nil},
+		{"not started", &Coupon{ValidFrom: now.Add(time.Hour)}, "ann", true, ErrCouponNotActive},
+		{"expired", &Coupon{ValidTo: now}, "ann", true, ErrCouponNotActive},
+		{"usage limit reached", &Coupon{UsageLimit: 2, Redemptions: redemptions}, "eve", true, ErrCouponExhausted},
+		{"usage limit left", &Coupon{UsageLimit: 3, Redemptions: redemptions}, "eve", true, nil},
+		{"user limit reached", &Coupon{UsageLimitPerUser: 1, Redemptions: redemptions}, "ann", true, ErrCouponExhausted},
+		{"user limit of another user", &Coupon{UsageLimitPerUser: 1, Redemptions: redemptions}, "eve", true, nil},
+		{"user limit for an anonymous user", &Coupon{UsageLimitPerUser: 1}, "tmp", false, ErrCouponLoginRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.coupon.Check(tt.userID, tt.registered, now); err != tt.wantErr {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCouponAppliesTo(t *testing.T) {
+	tests := []struct {
+		name       string
+		coupon     *Coupon
+		sku        string
+		categories []string
+		want       bool
+	}{
+		{"unrestricted", &Coupon{}, "1", []string{"mugs"}, true},
+		{"sku", &Coupon{SKUs: []string{"1", "2"}}, "2", []string{"mugs"}, true},
+		{"other sku", &Coupon{SKUs: []string{"1"}}, "3", []string{"mugs"}, false},
+		{"category", &Coupon{Categories: []string{"wall-art"}}, "1", []string{"wall-art"}, true},
+		{"subcategory", &Coupon{Categories: []string{"wall-art"}}, "1", []string{"posters-without-frame", "wall-art"}, true},
+		{"second listing", &Coupon{Categories: []string{"sale"}}, "1", []string{"mugs", "sale"}, true},
+		{"other category", &Coupon{Categories: []string{"wall-art"}}, "1", []string{"mugs"}, false},
+		{"no category", &Coupon{Categories: []string{"wall-art"}}, "1", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.coupon.AppliesTo(tt.sku, tt.categories); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,12 +12,21 @@ var (
 	ErrNotFoundInStorage     = errors.New("not found in storage")
 	ErrDuplicateKeyInStorage = errors.New("duplicate key in storage")
 	ErrNotExist              = errors.New("not exist")
+	ErrModifiedInStorage     = errors.New("modified concurrently in storage")
 )
 
 // ServiceError describes a web-service error.
+// Fields tell which request fields are invalid.
 type ServiceError struct {
 	Code    int
 	Message string
+	Fields  []*FieldError
+}
+
+// FieldError describes an invalid request field, e.g. variations[1].price.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 // Error returns a string representation of the error.
@@ -38,17 +47,21 @@ func (e *ServiceError) Encode(w http.ResponseWriter) {
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(e.Code)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	json.NewEncoder(w).Encode(errorResponse{Error: message, Fields: e.Fields})
+}
+
+type errorResponse struct {
+	Error  string        `json:"error"`
+	Fields []*FieldError `json:"fields,omitempty"`
 }
 
 // Decode decodes the error from the given HTTP response.
 func (e *ServiceError) Decode(r *http.Response) {
 	e.Code = r.StatusCode
-	var res struct {
-		Error string `json:"error"`
-	}
+	var res errorResponse
 	if err := json.NewDecoder(r.Body).Decode(&res); err == nil && res.Error != "" {
 		e.Message = res.Error
+		e.Fields = res.Fields
 	} else {
 		e.Message = http.StatusText(r.StatusCode)
 	}
@@ -70,6 +83,22 @@ func ErrUnauthorized(format string, v ...interface{}) error {
 	}
 }
 
+// ErrForbidden creates a Forbidden service error.
+func ErrForbidden(format string, v ...interface{}) error {
+	return &ServiceError{
+		Code:    http.StatusForbidden,
+		Message: fmt.Sprintf(format, v...),
+	}
+}
+
+// ErrPaymentRequired creates a PaymentRequired service error.
+func ErrPaymentRequired(format string, v ...interface{}) error {
+	return &ServiceError{
+		Code:    http.StatusPaymentRequired,
+		Message: fmt.Sprintf(format, v...),
+	}
+}
+
 // ErrNotFound creates a NotFound service error.
 func ErrNotFound(format string, v ...interface{}) error {
 	return &ServiceError{
@@ -79,12 +108,20 @@ func ErrNotFound(format string, v ...interface{}) error {
 }
 
 // ErrConflict creates a Conflict service error.
-//func ErrConflict(format string, v ...interface{}) error {
-//	return &ServiceError{
-//		Code:    http.StatusConflict,
-//		Message: fmt.Sprintf(format, v...),
-//	}
-//}
+func ErrConflict(format string, v ...interface{}) error {
+	return &ServiceError{
+		Code:    http.StatusConflict,
+		Message: fmt.Sprintf(format, v...),
+	}
+}
+
+// ErrPreconditionFailed creates a PreconditionFailed service error.
+func ErrPreconditionFailed(format string, v ...interface{}) error {
+	return &ServiceError{
+		Code:    http.StatusPreconditionFailed,
+		Message: fmt.Sprintf(format, v...),
+	}
+}
 
 // ErrInternal creates an Internal service error.
 func ErrInternal(format string, v ...interface{}) error {
@@ -93,3 +130,23 @@ func ErrInternal(format string, v ...interface{}) error {
 		Message: fmt.Sprintf(format, v...),
 	}
 }
+
+// Validation collects the invalid fields of a request.
+type Validation []*FieldError
+
+// Add records an invalid field.
+func (v *Validation) Add(field string, format string, args ...interface{}) {
+	*v = append(*v, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns a BadRequest service error listing the invalid fields, nil if there are none.
+func (v Validation) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return &ServiceError{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("invalid %s: %s", v[0].Field, v[0].Message),
+		Fields:  v,
+	}
+}
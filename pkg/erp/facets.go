@@ -1 +1,144 @@
 package erp
+
+import (
+	"sort"
+	"strings"
+)
+
+// FacetID returns the id of a facet value, like "size=250x400", see deploy/mongo/facets.json.
+func FacetID(name string, value string) string {
+	return name + "=" + value
+}
+
+// ParseFacetID splits a facet id into the facet name and value.
+func ParseFacetID(id string) (name string, value string, ok bool) {
+	i := strings.Index(id, "=")
+	if i <= 0 || i == len(id)-1 {
+		return "", "", false
+	}
+	return id[:i], id[i+1:], true
+}
+
+// FacetFilter selects catalog products by facet values.
+// Values of the same facet are alternatives, different facets all have to match,
+// so size=250x400&size=400x400&frame=без рамки finds unframed posters of either size.
+type FacetFilter struct {
+	// Category limits the products to the category and its subcategories.
+	Category string
+	// Selected are the selected values keyed by facet name.
+	Selected map[string][]string
+	Offset   int
+	Limit    int
+}
+
+// Select adds the facet value to the filter.
+func (f *FacetFilter) Select(name string, value string) {
+	if f.Selected == nil {
+		f.Selected = map[string][]string{}
+	}
+	for _, v := range f.Selected[name] {
+		if v == value {
+			return
+		}
+	}
+	f.Selected[name] = append(f.Selected[name], value)
+}
+
+// IsSelected reports whether the facet value is selected.
+func (f *FacetFilter) IsSelected(name string, value string) bool {
+	for _, v := range f.Selected[name] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectedIDs returns the ids of the selected values of the facet.
+func (f *FacetFilter) SelectedIDs(name string) []string {
+	ids := make([]string, 0, len(f.Selected[name]))
+	for _, v := range f.Selected[name] {
+		ids = append(ids, FacetID(name, v))
+	}
+	return ids
+}
+
+// FacetCounts is the number of matching products keyed by facet id.
+type FacetCounts map[string]int
+
+// FacetValue is a facet value with the number of products that would match
+// if it were selected in addition to the current filter.
+type FacetValue struct {
+	ID       string `json:"id"`
+	Value    string `json:"value"`
+	Count    int    `json:"count"`
+	Selected bool   `json:"selected"`
+}
+
+// FacetGroup is a facet with its values, e.g. all sizes for the sidebar.
+type FacetGroup struct {
+	Name    string        `json:"name"`
+	Display string        `json:"display"`
+	Values  []*FacetValue `json:"values"`
+}
+
+// FacetedProducts is a page of products matching a facet filter.
+type FacetedProducts struct {
+	Products []*CatalogProduct `json:"products"`
+	Facets   []*FacetGroup     `json:"facets"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	Limit    int               `json:"limit"`
+	Counts   FacetCounts       `json:"-"`
+}
+
+// GroupFacets groups the counted facet values by facet in the order of the facet definitions.
+// Selected values are always listed so they can be unselected, even when nothing matches them.
+func GroupFacets(defs []*Facet, counts FacetCounts, filter *FacetFilter) []*FacetGroup {
+	values := map[string]*FacetValue{}
+	for id, count := range counts {
+		if name, value, ok := ParseFacetID(id); ok {
+			values[id] = &FacetValue{ID: id, Value: value, Count: count, Selected: filter.IsSelected(name, value)}
+		}
+	}
+	for name, selected := range filter.Selected {
+		for _, value := range selected {
+			id := FacetID(name, value)
+			if values[id] == nil {
+				values[id] = &FacetValue{ID: id, Value: value, Selected: true}
+			}
+		}
+	}
+
+	var groups []*FacetGroup
+	byName := map[string]*FacetGroup{}
+	group := func(name string, display string) *FacetGroup {
+		g, ok := byName[name]
+		if !ok {
+			g = &FacetGroup{Name: name, Display: display}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		return g
+	}
+	for _, def := range defs {
+		if v, ok := values[def.ID]; ok {
+			g := group(def.Name, def.Display)
+			g.Values = append(g.Values, v)
+			delete(values, def.ID)
+		}
+	}
+
+	// Values missing from the definitions are listed after the known ones.
+	rest := make([]string, 0, len(values))
+	for id := range values {
+		rest = append(rest, id)
+	}
+	sort.Strings(rest)
+	for _, id := range rest {
+		name, _, _ := ParseFacetID(id)
+		g := group(name, name)
+		g.Values = append(g.Values, values[id])
+	}
+	return groups
+}
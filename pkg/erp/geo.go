@@ -0,0 +1,228 @@
+package erp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+)
+
+// Geo errors.
+var (
+	ErrDeliveryZoneNotFound = errors.New("delivery zone not found")
+	ErrPickupPointNotFound  = errors.New("pickup point not found")
+	ErrOutsideDeliveryZones = errors.New("address is outside of the delivery zones")
+)
+
+// Nearest pickup points limits.
+const (
+	DefaultPickupPointsLimit = 10
+	MaxPickupPointsLimit     = 50
+)
+
+// Location is a point on the map in degrees.
+type Location struct {
+	Lat float64 `bson:"lat" json:"lat"`
+	Lng float64 `bson:"lng" json:"lng"`
+}
+
+// Validate checks the coordinates.
+func (l Location) Validate() error {
+	if l.Lat < -90 || l.Lat > 90 {
+		return fmt.Errorf("latitude %v is not between -90 and 90", l.Lat)
+	}
+	if l.Lng < -180 || l.Lng > 180 {
+		return fmt.Errorf("longitude %v is not between -180 and 180", l.Lng)
+	}
+	return nil
+}
+
+// DistanceTo returns the great circle distance to the location in kilometers.
+func (l Location) DistanceTo(to Location) float64 {
+	return l.point().GreatCircleDistance(to.point())
+}
+
+func (l Location) point() *geo.Point {
+	return geo.NewPoint(l.Lat, l.Lng)
+}
+
+// DeliveryZone is an area the shop delivers to, a polygon or a radius around a center
+// like the studio, see deploy/mongo/delivery_zones.json. Rate tables price destinations
+// by the zone they are in, zones with a lower priority are matched first.
+type DeliveryZone struct {
+	ID         string     `bson:"_id" json:"id"`
+	Name       string     `bson:"name" json:"name"`
+	Priority   int        `bson:"priority" json:"priority"`
+	Polygon    []Location `bson:"polygon,omitempty" json:"polygon,omitempty"`
+	Center     *Location  `bson:"center,omitempty" json:"center,omitempty"`
+	RadiusKm   float64    `bson:"radius_km,omitempty" json:"radius_km,omitempty"`
+	ModifiedOn time.Time  `bson:"modified_on" json:"modifiedOn"`
+}
+
+// Contains reports whether the location is in the zone.
+func (z *DeliveryZone) Contains(l Location) bool {
+	if z.Center != nil {
+		return z.Center.DistanceTo(l) <= z.RadiusKm
+	}
+	points := make([]*geo.Point, 0, len(z.Polygon))
+	for _, p := range z.Polygon {
+		points = append(points, p.point())
+	}
+	return geo.NewPolygon(points).Contains(l.point())
+}
+
+// FindDeliveryZone returns the zone of the location, nil if it is outside of all the zones.
+func FindDeliveryZone(zones []*DeliveryZone, l Location) *DeliveryZone {
+	sorted := make([]*DeliveryZone, len(zones))
+	copy(sorted, zones)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	for _, z := range sorted {
+		if z.Contains(l) {
+			return z
+		}
+	}
+	return nil
+}
+
+// DeliveryZoneInput is a delivery zone to set, either a polygon or a center with a radius.
+type DeliveryZoneInput struct {
+	Name     string     `json:"name"`
+	Priority int        `json:"priority"`
+	Polygon  []Location `json:"polygon"`
+	Center   *Location  `json:"center"`
+	RadiusKm float64    `json:"radius_km"`
+}
+
+// Validate checks the zone with the given id.
+func (in *DeliveryZoneInput) Validate(id string) error {
+	var v Validation
+	if !categoryIDRegexp.MatchString(id) {
+		v.Add("id", "must be lower case letters and digits like moscow-center")
+	}
+	if strings.TrimSpace(in.Name) == "" {
+		v.Add("name", "is required")
+	}
+	switch {
+	case in.Center != nil && len(in.Polygon) > 0:
+		v.Add("polygon", "a zone is either a polygon or a center with a radius")
+	case in.Center != nil:
+		if err := in.Center.Validate(); err != nil {
+			v.Add("center", "%s", err)
+		}
+		if in.RadiusKm <= 0 {
+			v.Add("radius_km", "must be positive")
+		}
+	case len(in.Polygon) < 3:
+		v.Add("polygon", "at least three points are required")
+	default:
+		for i, p := range in.Polygon {
+			if err := p.Validate(); err != nil {
+				v.Add(fmt.Sprintf("polygon[%d]", i), "%s", err)
+			}
+		}
+	}
+	return v.Err()
+}
+
+// DeliveryZone returns the zone of a validated input.
+func (in *DeliveryZoneInput) DeliveryZone(id string, modifiedTime time.Time) *DeliveryZone {
+	zone := &DeliveryZone{
+		ID:         id,
+		Name:       strings.TrimSpace(in.Name),
+		Priority:   in.Priority,
+		ModifiedOn: modifiedTime.Round(time.Second),
+	}
+	if in.Center != nil {
+		center := *in.Center
+		zone.Center = &center
+		zone.RadiusKm = in.RadiusKm
+	} else {
+		zone.Polygon = in.Polygon
+	}
+	return zone
+}
+
+// PickupPoint is a place customers collect their orders at, see deploy/mongo/pickup_points.json.
+type PickupPoint struct {
+	ID         string    `bson:"_id" json:"id"`
+	Name       string    `bson:"name" json:"name"`
+	Address    string    `bson:"address" json:"address"`
+	Hours      string    `bson:"hours" json:"hours"`
+	Location   Location  `bson:"location" json:"location"`
+	Active     bool      `bson:"active" json:"active"`
+	ModifiedOn time.Time `bson:"modified_on" json:"modifiedOn"`
+}
+
+// PickupPointInput is a pickup point to set.
+type PickupPointInput struct {
+	Name     string   `json:"name"`
+	Address  string   `json:"address"`
+	Hours    string   `json:"hours"`
+	Location Location `json:"location"`
+	Active   bool     `json:"active"`
+}
+
+// Validate checks the pickup point with the given id.
+func (in *PickupPointInput) Validate(id string) error {
+	var v Validation
+	if !categoryIDRegexp.MatchString(id) {
+		v.Add("id", "must be lower case letters and digits like studio")
+	}
+	if strings.TrimSpace(in.Name) == "" {
+		v.Add("name", "is required")
+	}
+	if strings.TrimSpace(in.Address) == "" {
+		v.Add("address", "is required")
+	}
+	if err := in.Location.Validate(); err != nil {
+		v.Add("location", "%s", err)
+	}
+	return v.Err()
+}
+
+// PickupPoint returns the pickup point of a validated input.
+func (in *PickupPointInput) PickupPoint(id string, modifiedTime time.Time) *PickupPoint {
+	return &PickupPoint{
+		ID:         id,
+		Name:       strings.TrimSpace(in.Name),
+		Address:    strings.TrimSpace(in.Address),
+		Hours:      strings.TrimSpace(in.Hours),
+		Location:   in.Location,
+		Active:     in.Active,
+		ModifiedOn: modifiedTime.Round(time.Second),
+	}
+}
+
+// NearbyPickupPoint is a pickup point with its distance in kilometers.
+type NearbyPickupPoint struct {
+	*PickupPoint
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// NearestPickupPoints returns up to limit active points closest to the location, nearest first.
+// Points farther than maxDistance kilometers are left out unless maxDistance is zero.
+func NearestPickupPoints(points []*PickupPoint, l Location, limit int, maxDistance float64) []*NearbyPickupPoint {
+	nearby := make([]*NearbyPickupPoint, 0, len(points))
+	for _, p := range points {
+		if !p.Active {
+			continue
+		}
+		distance := l.DistanceTo(p.Location)
+		if maxDistance > 0 && distance > maxDistance {
+			continue
+		}
+		nearby = append(nearby, &NearbyPickupPoint{PickupPoint: p, DistanceKm: distance})
+	}
+	sort.SliceStable(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+	if limit > 0 && len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+	return nearby
+}
@@ -0,0 +1,60 @@
+package erp
+
+import "testing"
+
+func TestDeliveryZoneContains(t *testing.T) {
+	radius := &DeliveryZone{ID: "center", Center: &Location{Lat: 55.7558, Lng: 37.6173}, RadiusKm: 10}
+	polygon := &DeliveryZone{ID: "square", Polygon: []Location{
+		{Lat: 55.5, Lng: 37.3},
+		{Lat: 55.5, Lng: 37.9},
+		{Lat: 56.0, Lng: 37.9},
+		{Lat: 56.0, Lng: 37.3},
+	}}
+	tests := []struct {
+		name     string
+		zone     *DeliveryZone
+		location Location
+		want     bool
+	}{
+		{"radius center", radius, Location{Lat: 55.7558, Lng: 37.6173}, true},
+		{"radius inside", radius, Location{Lat: 55.8, Lng: 37.6}, true},
+		{"radius outside", radius, Location{Lat: 55.9, Lng: 37.6}, false},
+		{"radius far away", radius, Location{Lat: 59.9343, Lng: 30.3351}, false},
+		{"polygon inside", polygon, Location{Lat: 55.7, Lng: 37.5}, true},
+		{"polygon outside to the north", polygon, Location{Lat: 56.1, Lng: 37.5}, false},
+		{"polygon outside to the east", polygon, Location{Lat: 55.7, Lng: 38.0}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.zone.Contains(tt.location); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDeliveryZone(t *testing.T) {
+	inner := &DeliveryZone{ID: "inner", Priority: 1, Center: &Location{Lat: 55.7558, Lng: 37.6173}, RadiusKm: 5}
+	outer := &DeliveryZone{ID: "outer", Priority: 2, Center: &Location{Lat: 55.7558, Lng: 37.6173}, RadiusKm: 50}
+	zones := []*DeliveryZone{outer, inner}
+	tests := []struct {
+		name     string
+		location Location
+		want     string
+	}{
+		{"lower priority first", Location{Lat: 55.76, Lng: 37.62}, "inner"},
+		{"outer ring", Location{Lat: 55.95, Lng: 37.62}, "outer"},
+		{"outside", Location{Lat: 59.9343, Lng: 30.3351}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if zone := FindDeliveryZone(zones, tt.location); zone != nil {
+				got = zone.ID
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,38 @@
 package erp
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
+// ErrInsufficientStock is returned when there is not enough stock to reserve.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// Inventory is a stock of one SKU, keyed by the SKU.
+// Reserved is the sum of all reservations kept to check availability in a single query.
 type Inventory struct {
-	ID           string `bson:"_id" json:"id,omitempty"`
-	Quantity     int    `json:"quantity"`
-	Reservations []*Reservation
-	CreatedOn    time.Time `json:"createdOn"`
-	ModifiedOn   time.Time `json:"modifiedOn"`
+	ID           string         `bson:"_id" json:"id,omitempty"`
+	Quantity     int            `json:"quantity"`
+	Reserved     int            `bson:"reserved" json:"reserved"`
+	Reservations []*Reservation `bson:"reservations" json:"reservations"`
+	CreatedOn    time.Time      `json:"createdOn"`
+	ModifiedOn   time.Time      `json:"modifiedOn"`
+}
+
+// Available returns the quantity that can still be reserved.
+func (i *Inventory) Available() int {
+	if i == nil || i.Quantity < i.Reserved {
+		return 0
+	}
+	return i.Quantity - i.Reserved
 }
 
+// Reservation holds stock for an order until it is paid or the reservation expires.
 type Reservation struct {
 	ID         string    `bson:"_id" json:"id,omitempty"`
+	OwnerID    string    `bson:"owner_id" json:"owner_id"`
 	Quantity   int       `json:"quantity"`
+	ExpiresOn  time.Time `bson:"expires_on" json:"expiresOn"`
 	CreatedOn  time.Time `json:"createdOn"`
 	ModifiedOn time.Time `json:"modifiedOn"`
 }
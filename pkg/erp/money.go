@@ -0,0 +1,342 @@
+package erp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// Money errors.
+var (
+	ErrInvalidMoney     = errors.New("invalid money amount")
+	ErrCurrencyMismatch = errors.New("money amounts are in different currencies")
+)
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+// DefaultCurrency is the currency of amounts stored before currencies were recorded.
+const DefaultCurrency Currency = "RUB"
+
+// currencyMinorUnits are the digits after the decimal point of the currencies other than two.
+var currencyMinorUnits = map[Currency]int{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+// MinorUnits returns the number of digits after the decimal point of the currency, 2 for kopecks.
+func (c Currency) MinorUnits() int {
+	if n, ok := currencyMinorUnits[c]; ok {
+		return n
+	}
+	return 2
+}
+
+func (c Currency) scale() float64 {
+	return math.Pow10(c.MinorUnits())
+}
+
+// Money is an amount in the minor units of a currency, like kopecks,
+// so sums and totals are exact.
+// Amounts without a currency are in DefaultCurrency.
+//
+// Money is encoded as {"amount": "69.99", "minor_units": 6999, "currency": "RUB"} in JSON
+// and as {amount: 6999, currency: "RUB"} in BSON. Both decoders also accept the legacy
+// shapes of the amounts: decimal numbers like 69.99 and strings like "69.99".
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// NewMoney returns the amount given in minor units.
+func NewMoney(minor int64, currency Currency) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// MoneyFromFloat converts a decimal amount, rounding half away from zero to the minor units.
+func MoneyFromFloat(amount float64, currency Currency) Money {
+	currency = currencyOrDefault(currency)
+	return Money{Amount: int64(math.Round(amount * currency.scale())), Currency: currency}
+}
+
+// ParseMoney parses a decimal amount like "69.99" without going through floats.
+// Digits beyond the minor units of the currency are rounded half away from zero.
+func ParseMoney(s string, currency Currency) (Money, error) {
+	currency = currencyOrDefault(currency)
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	units := currency.MinorUnits()
+	roundUp := false
+	if len(frac) > units {
+		roundUp = frac[units] >= '5'
+		frac = frac[:units]
+	}
+	frac += strings.Repeat("0", units-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if roundUp {
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func currencyOrDefault(c Currency) Currency {
+	if c == "" {
+		return DefaultCurrency
+	}
+	return c
+}
+
+// CurrencyOrDefault returns the currency of the amount, DefaultCurrency if it has none.
+func (m Money) CurrencyOrDefault() Currency {
+	return currencyOrDefault(m.Currency)
+}
+
+// String formats the amount as a decimal like 69.99, the way ParseMoney reads it.
+func (m Money) String() string {
+	units := m.CurrencyOrDefault().MinorUnits()
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	if units == 0 {
+		return sign + s
+	}
+	if len(s) <= units {
+		s = strings.Repeat("0", units-len(s)+1) + s
+	}
+	return sign + s[:len(s)-units] + "." + s[len(s)-units:]
+}
+
+// Float returns the amount as a decimal number, for display and third party APIs only.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / m.CurrencyOrDefault().scale()
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is above zero.
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// currencyWith returns the currency of an operation of the two amounts.
+// Zero amounts take the currency of the other one, so sums can start from Money{}.
+func (m Money) currencyWith(o Money) (Currency, error) {
+	switch {
+	case m.Currency == "" || (m.Amount == 0 && o.Currency != ""):
+		return o.Currency, nil
+	case o.Currency == "" || o.Amount == 0 || o.Currency == m.Currency:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+// SameCurrency reports whether the amounts can be added together.
+func (m Money) SameCurrency(o Money) bool {
+	return m.IsZero() || o.IsZero() || m.CurrencyOrDefault() == o.CurrencyOrDefault()
+}
+
+// Add returns the sum of the amounts.
+// Amounts in different currencies can't be added, ErrCurrencyMismatch is returned for them.
+func (m Money) Add(o Money) (Money, error) {
+	currency, err := m.currencyWith(o)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: currency}, nil
+}
+
+// Sub returns the difference of the amounts, see Add.
+func (m Money) Sub(o Money) (Money, error) {
+	currency, err := m.currencyWith(o)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: currency}, nil
+}
+
+// Mul returns the amount multiplied by a quantity.
+func (m Money) Mul(quantity int) Money {
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}
+}
+
+// Percent returns the given percentage of the amount rounded half away from zero to the minor units.
+func (m Money) Percent(percent float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * percent / 100)), Currency: m.Currency}
+}
+
+// Cmp compares the amounts, see Add.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := m.currencyWith(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Less reports whether the amount is below the other one.
+// Amounts in different currencies are not comparable, none of them is less.
+func (m Money) Less(o Money) bool {
+	c, err := m.Cmp(o)
+	return err == nil && c < 0
+}
+
+// Min returns the lower amount, the amount itself when the currencies differ.
+func (m Money) Min(o Money) Money {
+	if o.Less(m) {
+		return o
+	}
+	return m
+}
+
+// Max returns the higher amount, the amount itself when the currencies differ.
+func (m Money) Max(o Money) Money {
+	if m.Less(o) {
+		return o
+	}
+	return m
+}
+
+type moneyJSON struct {
+	Amount     json.RawMessage `json:"amount"`
+	MinorUnits *int64          `json:"minor_units,omitempty"`
+	Currency   Currency        `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as {"amount": "69.99", "minor_units": 6999, "currency": "RUB"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, _ := json.Marshal(m.String())
+	minor := m.Amount
+	return json.Marshal(moneyJSON{Amount: amount, MinorUnits: &minor, Currency: m.CurrencyOrDefault()})
+}
+
+// UnmarshalJSON decodes the amount from an object or from a legacy number or string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = []byte(strings.TrimSpace(string(data)))
+	switch {
+	case string(data) == "null":
+		return nil
+	case len(data) > 0 && data[0] == '{':
+		var v moneyJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if v.MinorUnits != nil {
+			*m = Money{Amount: *v.MinorUnits, Currency: currencyOrDefault(v.Currency)}
+			return nil
+		}
+		return m.unmarshalLegacyJSON(v.Amount, v.Currency)
+	}
+	return m.unmarshalLegacyJSON(data, "")
+}
+
+func (m *Money) unmarshalLegacyJSON(data []byte, currency Currency) error {
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	money, err := ParseMoney(s, currency)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+type moneyBSON struct {
+	Amount   int64    `bson:"amount"`
+	Currency Currency `bson:"currency"`
+}
+
+// MarshalBSONValue encodes the amount as {amount: 6999, currency: "RUB"}.
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(moneyBSON{Amount: m.Amount, Currency: m.CurrencyOrDefault()})
+}
+
+// UnmarshalBSONValue decodes the amount from a document or from a legacy number or string.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: t, Data: data}
+	switch t {
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+	case bsontype.EmbeddedDocument:
+		var v moneyBSON
+		if err := bson.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*m = Money{Amount: v.Amount, Currency: currencyOrDefault(v.Currency)}
+	case bsontype.Double:
+		*m = MoneyFromFloat(value.Double(), DefaultCurrency)
+	case bsontype.Int32:
+		*m = MoneyFromFloat(float64(value.Int32()), DefaultCurrency)
+	case bsontype.Int64:
+		*m = MoneyFromFloat(float64(value.Int64()), DefaultCurrency)
+	case bsontype.Decimal128:
+		s := value.Decimal128().String()
+		money, err := ParseMoney(s, DefaultCurrency)
+		if err != nil {
+			// Decimals may come in exponent form.
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil {
+				return err
+			}
+			money = MoneyFromFloat(f, DefaultCurrency)
+		}
+		*m = money
+	case bsontype.String:
+		money, err := ParseMoney(value.StringValue(), DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = money
+	default:
+		return fmt.Errorf("%w: can't decode money from BSON %s", ErrInvalidMoney, t)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package erp
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency Currency
+		want     Money
+		wantErr  bool
+	}{
+		{"69.99", "", Money{6999, "RUB"}, false},
+		{" 69.99 ", "USD", Money{6999, "USD"}, false},
+		{"69", "RUB", Money{6900, "RUB"}, false},
+		{"69.9", "RUB", Money{6990, "RUB"}, false},
+		{"0.005", "RUB", Money{1, "RUB"}, false},
+		{"0.004", "RUB", Money{0, "RUB"}, false},
+		{"-10.50", "RUB", Money{-1050, "RUB"}, false},
+		{"1500", "JPY", Money{1500, "JPY"}, false},
+		{"1500.5", "JPY", Money{1501, "JPY"}, false},
+		{"1.234", "KWD", Money{1234, "KWD"}, false},
+		{"", "RUB", Money{}, true},
+		{".99", "RUB", Money{}, true},
+		{"1,99", "RUB", Money{}, true},
+		{"1.9a", "RUB", Money{}, true},
+		{"99999999999999999999", "RUB", Money{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, tt.currency)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidMoney) {
+				t.Errorf("ParseMoney(%q): got %v, %v, want ErrInvalidMoney", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q): got %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{6999, "RUB"}, `{"amount":"69.99","minor_units":6999,"currency":"RUB"}`},
+		{Money{5, ""}, `{"amount":"0.05","minor_units":5,"currency":"RUB"}`},
+		{Money{-150, "USD"}, `{"amount":"-1.50","minor_units":-150,"currency":"USD"}`},
+		{Money{1500, "JPY"}, `{"amount":"1500","minor_units":1500,"currency":"JPY"}`},
+		{Money{}, `{"amount":"0.00","minor_units":0,"currency":"RUB"}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.money)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.money, got, tt.want)
+		}
+		var decoded Money
+		if err := json.Unmarshal(got, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded != (Money{tt.money.Amount, tt.money.CurrencyOrDefault()}) {
+			t.Errorf("%s: decoded %v", got, decoded)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSONLegacy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{`69.99`, Money{6999, "RUB"}},
+		{`"69.99"`, Money{6999, "RUB"}},
+		{`{"amount":"12.5","currency":"USD"}`, Money{1250, "USD"}},
+		{`{"amount":12.5}`, Money{1250, "RUB"}},
+	}
+	for _, tt := range tests {
+		var got Money
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    Money
+		wantErr bool
+	}{
+		{"same currency", Money{100, "RUB"}, Money{250, "RUB"}, Money{350, "RUB"}, false},
+		{"sum from zero", Money{}, Money{250, "USD"}, Money{250, "USD"}, false},
+		{"zero of another currency", Money{0, "RUB"}, Money{250, "USD"}, Money{250, "USD"}, false},
+		{"adding zero of another currency", Money{100, "USD"}, Money{0, "RUB"}, Money{100, "USD"}, false},
+		{"no currency", Money{100, "USD"}, Money{50, ""}, Money{150, "USD"}, false},
+		{"different currencies", Money{100, "RUB"}, Money{250, "USD"}, Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if tt.wantErr {
+				if !errors.Is(err, ErrCurrencyMismatch) {
+					t.Fatalf("got %v, %v, want ErrCurrencyMismatch", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyCmp(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    int
+		wantErr bool
+	}{
+		{"less", Money{100, "RUB"}, Money{250, "RUB"}, -1, false},
+		{"greater", Money{300, "RUB"}, Money{250, "RUB"}, 1, false},
+		{"equal", Money{250, "RUB"}, Money{250, "RUB"}, 0, false},
+		{"zero", Money{}, Money{250, "USD"}, -1, false},
+		{"different currencies", Money{100, "RUB"}, Money{250, "USD"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Cmp(tt.b)
+			if tt.wantErr {
+				if !errors.Is(err, ErrCurrencyMismatch) {
+					t.Fatalf("got %d, %v, want ErrCurrencyMismatch", got, err)
+				}
+				if tt.a.Less(tt.b) || tt.b.Less(tt.a) {
+					t.Error("amounts in different currencies are less than one another")
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %d, %v, want %d", got, err, tt.want)
+			}
+			if less := tt.a.Less(tt.b); less != (tt.want < 0) {
+				t.Errorf("Less: got %v", less)
+			}
+		})
+	}
+}
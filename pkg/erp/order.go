@@ -1,28 +1,336 @@
 package erp
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	errMustProvideAddress       = errors.New("must provide shipping address")
+	errMustProvidePaymentMethod = errors.New("must provide payment method")
+	errMustProvideDestination   = errors.New("must provide shipping destination for the delivery")
+	errAddressAndAddressID      = errors.New("must provide either shipping address or saved address id")
+	errDestinationOfAddressID   = errors.New("shipping destination is taken from the saved address")
+	errInvalidOrderStatus       = errors.New("invalid order status")
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusPendingPayment OrderStatus = "pending_payment"
+	OrderStatusPaid           OrderStatus = "paid"
+	OrderStatusPacked         OrderStatus = "packed"
+	OrderStatusShipped        OrderStatus = "shipped"
+	OrderStatusDelivered      OrderStatus = "delivered"
+	OrderStatusCancelled      OrderStatus = "cancelled"
+	OrderStatusRefunded       OrderStatus = "refunded"
+)
+
+// orderTransitions lists the states an order may move to from each state.
+// Once money is taken the order can only be refunded, not cancelled.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPendingPayment: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:           {OrderStatusPacked, OrderStatusRefunded},
+	OrderStatusPacked:         {OrderStatusShipped, OrderStatusRefunded},
+	OrderStatusShipped:        {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered:      {OrderStatusRefunded},
+	OrderStatusCancelled:      {},
+	OrderStatusRefunded:       {},
+}
+
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order in state s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStatusChange is a record in the order status history.
+type OrderStatusChange struct {
+	From    OrderStatus `bson:"from,omitempty" json:"from,omitempty"`
+	To      OrderStatus `bson:"to" json:"to"`
+	By      string      `bson:"by" json:"by"`
+	Comment string      `bson:"comment,omitempty" json:"comment,omitempty"`
+	At      time.Time   `bson:"at" json:"at"`
+}
+
+// PaymentActor is recorded in the order history for changes made by the payment flow.
+const PaymentActor = "payment"
 
 type Order struct {
-	ID         string `bson:"_id" json:"id,omitempty"`
-	TotalPrice int    `json:"total_price"`
-	Shipping   *Shipping
-	Pricing    *Pricing
-	CreatedOn  time.Time `json:"createdOn"`
-	ModifiedOn time.Time `json:"modifiedOn"`
+	ID     string `bson:"_id" json:"id,omitempty"`
+	UserID string `bson:"user_id" json:"user_id"`
+	// Anonymous is set for orders placed without an account,
+	// they are handed over to the account the user logs in to.
+	Anonymous bool                 `bson:"anonymous,omitempty" json:"-"`
+	CartID    string               `bson:"cart_id" json:"cart_id"`
+	Status    OrderStatus          `bson:"status" json:"status"`
+	History   []*OrderStatusChange `bson:"history" json:"history"`
+	Products  []*CartProduct       `bson:"products" json:"products"`
+	Quantity  int                  `bson:"quantity" json:"quantity"`
+	Subtotal  Money                `bson:"subtotal" json:"subtotal"`
+	Discount  Money                `bson:"discount" json:"discount"`
+	// TotalPrice is the discounted subtotal plus the shipping price.
+	TotalPrice    Money `bson:"total_price" json:"total_price"`
+	ShippingPrice Money `bson:"shipping_price" json:"shipping_price"`
+	// Currency is the currency of the order amounts, ExchangeRate is the rate of the price list
+	// the order was priced with at the time of the order.
+	Currency     Currency       `bson:"currency" json:"currency"`
+	ExchangeRate *ExchangeRate  `bson:"exchange_rate,omitempty" json:"exchange_rate,omitempty"`
+	Coupon       *AppliedCoupon `bson:"coupon,omitempty" json:"coupon,omitempty"`
+	Shipping     *Shipping      `bson:"shipping" json:"shipping"`
+	Payment      *Payment       `bson:"payment" json:"payment"`
+	Pricing      *Pricing       `bson:"pricing,omitempty" json:"pricing,omitempty"`
+	CreatedOn    time.Time      `bson:"created_on" json:"createdOn"`
+	ModifiedOn   time.Time      `bson:"modified_on" json:"modifiedOn"`
 }
 
+// Shipping is the delivery of an order, Dimensions and Weight are the ones of the parcel.
+// Variations keep their package details in the same form.
 type Shipping struct {
-	Dimensions *Dimensions `json:"dimensions"`
-	Weight     *Weight     `json:"weight"`
-	Address    string      `json:"address"`
-	CreatedOn  time.Time   `json:"createdOn"`
-	ModifiedOn time.Time   `json:"modifiedOn"`
+	Dimensions  *Dimensions          `json:"dimensions"`
+	Weight      *Weight              `json:"weight"`
+	Address     string               `json:"address"`
+	Destination *ShippingDestination `bson:"destination,omitempty" json:"destination,omitempty"`
+	// SavedAddress is a copy of the address book entry the order is shipped to,
+	// later changes of the address book don't change the order.
+	SavedAddress *Address        `bson:"saved_address,omitempty" json:"saved_address,omitempty"`
+	Delivery     *DeliveryOption `bson:"delivery,omitempty" json:"delivery,omitempty"`
+	CreatedOn    time.Time       `json:"createdOn"`
+	ModifiedOn   time.Time       `json:"modifiedOn"`
 }
 
 type Payment struct {
-	ID            string    `bson:"_id" json:"id,omitempty"`
-	Method        string    `json:"method"`
-	TransactionID int       `json:"transaction_id"`
-	CreatedOn     time.Time `json:"createdOn"`
-	ModifiedOn    time.Time `json:"modifiedOn"`
+	ID            string        `bson:"_id" json:"id,omitempty"`
+	Provider      string        `bson:"provider" json:"provider"`
+	Method        string        `json:"method"`
+	Status        PaymentStatus `bson:"status" json:"status"`
+	Amount        Money         `bson:"amount" json:"amount"`
+	TransactionID string        `bson:"transaction_id" json:"transaction_id"`
+	CreatedOn     time.Time     `json:"createdOn"`
+	ModifiedOn    time.Time     `json:"modifiedOn"`
+}
+
+// CheckoutInput is what a customer provides to turn the active cart into an order.
+// Delivery is the id of a delivery option quoted for the destination,
+// orders without one are shipped at no charge. The destination needs a location
+// when delivery zones are set up, addresses outside of them are rejected.
+// AddressID references an address of the address book instead of the address text,
+// the destination is then taken from the saved address, see UseAddress.
+type CheckoutInput struct {
+	Address       string               `json:"address"`
+	AddressID     string               `json:"address_id"`
+	Destination   *ShippingDestination `json:"destination"`
+	Delivery      string               `json:"delivery"`
+	PaymentMethod string               `json:"payment_method"`
+	PaymentToken  string               `json:"payment_token"`
+
+	savedAddress *Address
+}
+
+func (in *CheckoutInput) Validate() error {
+	in.AddressID = strings.TrimSpace(in.AddressID)
+	if in.AddressID != "" {
+		if strings.TrimSpace(in.Address) != "" {
+			return errAddressAndAddressID
+		}
+		if in.Destination != nil {
+			return errDestinationOfAddressID
+		}
+	} else if strings.TrimSpace(in.Address) == "" {
+		return errMustProvideAddress
+	}
+	if strings.TrimSpace(in.PaymentMethod) == "" {
+		return errMustProvidePaymentMethod
+	}
+	in.Delivery = strings.TrimSpace(in.Delivery)
+	if in.Delivery != "" && in.Destination == nil && in.AddressID == "" {
+		return errMustProvideDestination
+	}
+	if in.Destination != nil {
+		in.Destination.Normalize()
+		return in.Destination.Validate()
+	}
+	return nil
+}
+
+// UseAddress ships the order to the saved address of AddressID.
+func (in *CheckoutInput) UseAddress(address *Address) {
+	saved := *address
+	in.savedAddress = &saved
+	in.Address = address.String()
+	in.Destination = address.Destination()
+}
+
+// NewOrder snapshots the priced cart lines and totals into a new order.
+func NewOrder(id string, userID string, cart *PricedCart, in *CheckoutInput, createdTime time.Time) *Order {
+	createdTime = createdTime.Round(time.Second)
+	o := &Order{
+		ID:     id,
+		UserID: userID,
+		Status: OrderStatusPendingPayment,
+		History: []*OrderStatusChange{
+			{To: OrderStatusPendingPayment, By: userID, At: createdTime},
+		},
+		Products:      make([]*CartProduct, 0, len(cart.Products)),
+		Quantity:      cart.Quantity,
+		Subtotal:      cart.Subtotal,
+		Discount:      cart.Discount,
+		TotalPrice:    cart.Total,
+		ShippingPrice: Money{Currency: cart.Currency},
+		Currency:      cart.Currency,
+		Coupon:        cart.Coupon,
+		Shipping: &Shipping{
+			Address:      strings.TrimSpace(in.Address),
+			Destination:  in.Destination,
+			SavedAddress: in.savedAddress,
+			CreatedOn:    createdTime,
+			ModifiedOn:   createdTime,
+		},
+		Payment: &Payment{
+			ID:         id,
+			Method:     strings.TrimSpace(in.PaymentMethod),
+			Status:     PaymentStatusPending,
+			CreatedOn:  createdTime,
+			ModifiedOn: createdTime,
+		},
+		CreatedOn:  createdTime,
+		ModifiedOn: createdTime,
+	}
+	for _, p := range cart.Products {
+		line := *p
+		o.Products = append(o.Products, &line)
+	}
+	if cart.ExchangeRate != nil {
+		rate := *cart.ExchangeRate
+		o.ExchangeRate = &rate
+	}
+	o.Payment.Amount = o.TotalPrice
+	return o
+}
+
+// SetDelivery ships the order in the parcel with the delivery option,
+// the shipping price is added to the total and to the payment amount.
+// The option has to be priced in the currency of the order.
+func (o *Order) SetDelivery(destination *ShippingDestination, parcel *Parcel, option *DeliveryOption) error {
+	total, err := o.TotalPrice.Add(option.Price)
+	if err != nil {
+		return fmt.Errorf("delivery option %s: %w", option.ID, err)
+	}
+	o.Shipping.Destination = destination
+	o.Shipping.Delivery = option
+	o.Shipping.Dimensions = NewDimensions(parcel.Length, parcel.Width, parcel.Height)
+	o.Shipping.Weight = NewWeight(parcel.Weight)
+	o.ShippingPrice = option.Price
+	o.TotalPrice = total
+	o.Payment.Amount = o.TotalPrice
+	return nil
+}
+
+// ChangeStatus validates the transition and returns the history record for it.
+// The order itself is left untouched, storage applies the change.
+func (o *Order) ChangeStatus(to OrderStatus, by string, comment string, changedTime time.Time) (*OrderStatusChange, error) {
+	if !to.IsValid() {
+		return nil, errInvalidOrderStatus
+	}
+	if !o.Status.CanTransitionTo(to) {
+		return nil, fmt.Errorf("order can not be moved from %s to %s", o.Status, to)
+	}
+	change := &OrderStatusChange{
+		From:    o.Status,
+		To:      to,
+		By:      by,
+		Comment: strings.TrimSpace(comment),
+		At:      changedTime.Round(time.Second),
+	}
+	return change, nil
+}
+
+// CustomerOrder is an order as its customer sees it, without the payment provider
+// details and the history of who changed the order.
+type CustomerOrder struct {
+	ID            string                 `json:"id"`
+	Status        OrderStatus            `json:"status"`
+	History       []*CustomerOrderStatus `json:"history"`
+	Products      []*CartProduct         `json:"products"`
+	Quantity      int                    `json:"quantity"`
+	Subtotal      Money                  `json:"subtotal"`
+	Discount      Money                  `json:"discount"`
+	ShippingPrice Money                  `json:"shipping_price"`
+	TotalPrice    Money                  `json:"total_price"`
+	Currency      Currency               `json:"currency"`
+	Coupon        *AppliedCoupon         `json:"coupon,omitempty"`
+	Shipping      *ShippingSummary       `json:"shipping"`
+	Payment       *PaymentSummary        `json:"payment"`
+	CreatedOn     time.Time              `json:"createdOn"`
+	ModifiedOn    time.Time              `json:"modifiedOn"`
+}
+
+// CustomerOrderStatus is a status the order was moved to.
+type CustomerOrderStatus struct {
+	Status OrderStatus `json:"status"`
+	At     time.Time   `json:"at"`
+}
+
+// ShippingSummary is where and how the order is delivered.
+type ShippingSummary struct {
+	Address      string               `json:"address"`
+	Destination  *ShippingDestination `json:"destination,omitempty"`
+	SavedAddress *Address             `json:"saved_address,omitempty"`
+	Delivery     *DeliveryOption      `json:"delivery,omitempty"`
+}
+
+// PaymentSummary is how much and how the order is paid.
+type PaymentSummary struct {
+	Method string        `json:"method"`
+	Status PaymentStatus `json:"status"`
+	Amount Money         `json:"amount"`
+}
+
+// Customer returns the order as its customer sees it.
+func (o *Order) Customer() *CustomerOrder {
+	c := &CustomerOrder{
+		ID:            o.ID,
+		Status:        o.Status,
+		History:       make([]*CustomerOrderStatus, 0, len(o.History)),
+		Products:      o.Products,
+		Quantity:      o.Quantity,
+		Subtotal:      o.Subtotal,
+		Discount:      o.Discount,
+		ShippingPrice: o.ShippingPrice,
+		TotalPrice:    o.TotalPrice,
+		Currency:      o.Currency,
+		Coupon:        o.Coupon,
+		CreatedOn:     o.CreatedOn,
+		ModifiedOn:    o.ModifiedOn,
+	}
+	for _, h := range o.History {
+		c.History = append(c.History, &CustomerOrderStatus{Status: h.To, At: h.At})
+	}
+	if o.Shipping != nil {
+		c.Shipping = &ShippingSummary{
+			Address:      o.Shipping.Address,
+			Destination:  o.Shipping.Destination,
+			SavedAddress: o.Shipping.SavedAddress,
+			Delivery:     o.Shipping.Delivery,
+		}
+	}
+	if o.Payment != nil {
+		c.Payment = &PaymentSummary{
+			Method: o.Payment.Method,
+			Status: o.Payment.Status,
+			Amount: o.Payment.Amount,
+		}
+	}
+	return c
 }
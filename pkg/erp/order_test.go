@@ -0,0 +1,75 @@
+package erp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		to   OrderStatus
+		want bool
+	}{
+		{OrderStatusPendingPayment, OrderStatusPaid, true},
+		{OrderStatusPendingPayment, OrderStatusCancelled, true},
+		{OrderStatusPendingPayment, OrderStatusShipped, false},
+		{OrderStatusPendingPayment, OrderStatusRefunded, false},
+		{OrderStatusPaid, OrderStatusPacked, true},
+		{OrderStatusPaid, OrderStatusRefunded, true},
+		{OrderStatusPaid, OrderStatusCancelled, false},
+		{OrderStatusPaid, OrderStatusPendingPayment, false},
+		{OrderStatusPacked, OrderStatusShipped, true},
+		{OrderStatusPacked, OrderStatusRefunded, true},
+		{OrderStatusShipped, OrderStatusDelivered, true},
+		{OrderStatusShipped, OrderStatusPacked, false},
+		{OrderStatusDelivered, OrderStatusRefunded, true},
+		{OrderStatusDelivered, OrderStatusShipped, false},
+		{OrderStatusCancelled, OrderStatusPaid, false},
+		{OrderStatusRefunded, OrderStatusPaid, false},
+		{OrderStatusPaid, OrderStatusPaid, false},
+		{"unknown", OrderStatusPaid, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: got %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestOrderChangeStatus(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 400000000, time.UTC)
+	tests := []struct {
+		name    string
+		from    OrderStatus
+		to      OrderStatus
+		wantErr bool
+	}{
+		{"allowed", OrderStatusPaid, OrderStatusPacked, false},
+		{"not allowed", OrderStatusPaid, OrderStatusDelivered, true},
+		{"invalid status", OrderStatusPaid, "lost", true},
+		{"final status", OrderStatusRefunded, OrderStatusPaid, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &Order{Status: tt.from}
+			change, err := order.ChangeStatus(tt.to, "admin", " packed ", now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got change %+v, want an error", change)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := OrderStatusChange{From: tt.from, To: tt.to, By: "admin", Comment: "packed", At: now.Round(time.Second)}
+			if *change != want {
+				t.Errorf("got %+v, want %+v", *change, want)
+			}
+			if order.Status != tt.from {
+				t.Errorf("order status changed to %s", order.Status)
+			}
+		})
+	}
+}
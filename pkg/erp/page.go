@@ -0,0 +1,113 @@
+package erp
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// ErrInvalidCursor is returned by storage for a cursor it did not issue
+// or one issued for another sort order.
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// List page size limits.
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// PageRequest asks for a page of a list sorted by a field.
+// The next page is requested with the cursor of the previous one and the same sort.
+type PageRequest struct {
+	Limit int
+	// Cursor is the opaque Page.NextCursor of the previous page, empty for the first page.
+	Cursor string
+	// Sort is the JSON name of the field to sort by, empty for the default order.
+	Sort string
+	Desc bool
+}
+
+// Page describes a page of a list.
+type Page struct {
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is only counted for the first page.
+	Total *int64 `json:"total,omitempty"`
+}
+
+// ProductPage is a page of products.
+type ProductPage struct {
+	Products []*Product `json:"products"`
+	Page
+}
+
+// CatalogPage is a page of product cards.
+type CatalogPage struct {
+	Products []*CatalogProduct `json:"products"`
+	Page
+}
+
+// CategoryPage is a page of categories.
+type CategoryPage struct {
+	Categories []*Category `json:"categories"`
+	Page
+}
+
+// OrderPage is a page of orders.
+type OrderPage struct {
+	Orders []*Order `json:"orders"`
+	Page
+}
+
+// CustomerOrderPage is a page of the orders of a customer.
+type CustomerOrderPage struct {
+	Orders []*CustomerOrder `json:"orders"`
+	Page
+}
+
+// UserPage is a page of users.
+type UserPage struct {
+	Users []*User `json:"users"`
+	Page
+}
+
+// DecodePageRequest reads a page request from the query of a list request,
+// e.g. ?limit=50&sort=name&order=desc&cursor=...
+// Only the sort fields given are accepted.
+func DecodePageRequest(query url.Values, sorts ...string) (*PageRequest, error) {
+	req := &PageRequest{
+		Limit:  DefaultPageLimit,
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return nil, ErrBadRequest("invalid limit %q", v)
+		}
+		req.Limit = limit
+	}
+	if req.Limit > MaxPageLimit {
+		req.Limit = MaxPageLimit
+	}
+
+	if req.Sort != "" {
+		valid := false
+		for _, s := range sorts {
+			if s == req.Sort {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, ErrBadRequest("unknown sort field %q", req.Sort)
+		}
+	}
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		req.Desc = true
+	default:
+		return nil, ErrBadRequest("unknown sort order %q", order)
+	}
+	return req, nil
+}
@@ -0,0 +1,56 @@
+package erp
+
+import (
+	"context"
+	"errors"
+)
+
+// Payment-related errors.
+var (
+	ErrPaymentDeclined       = errors.New("payment declined")
+	ErrPaymentTimeout        = errors.New("payment provider timeout")
+	ErrInvalidWebhookPayload = errors.New("invalid webhook payload")
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending    PaymentStatus = "pending"
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusCaptured   PaymentStatus = "captured"
+	PaymentStatusDeclined   PaymentStatus = "declined"
+	PaymentStatusRefunded   PaymentStatus = "refunded"
+)
+
+// PaymentProvider is an acquirer the shop takes money through.
+type PaymentProvider interface {
+	// Name is stored on the payment to know which provider to use for it later.
+	Name() string
+	// Authorize holds the amount on the customer's payment method.
+	Authorize(ctx context.Context, req *PaymentRequest) (*PaymentResult, error)
+	// Capture takes the previously authorized amount.
+	Capture(ctx context.Context, transactionID string, amount Money) (*PaymentResult, error)
+	// Refund returns the captured amount to the customer.
+	Refund(ctx context.Context, transactionID string, amount Money) (*PaymentResult, error)
+	// VerifyWebhook checks the signature of a provider notification and decodes it.
+	VerifyWebhook(payload []byte, signature string) (*PaymentEvent, error)
+}
+
+type PaymentRequest struct {
+	OrderID string `json:"order_id"`
+	Amount  Money  `json:"amount"`
+	Method  string `json:"method"`
+	Token   string `json:"token"`
+}
+
+type PaymentResult struct {
+	TransactionID string        `json:"transaction_id"`
+	Status        PaymentStatus `json:"status"`
+}
+
+// PaymentEvent is an asynchronous notification about a payment sent by the provider.
+type PaymentEvent struct {
+	OrderID       string        `json:"order_id"`
+	TransactionID string        `json:"transaction_id"`
+	Status        PaymentStatus `json:"status"`
+}
@@ -0,0 +1,305 @@
+package erp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Price list errors.
+var (
+	ErrPriceListNotFound = errors.New("price list not found")
+	ErrUnknownSKU        = errors.New("sku is not a variation of any product")
+)
+
+// Requests select the market with the headers, or with the cookies when the headers are missing.
+const (
+	CurrencyHeader = "X-Currency"
+	RegionHeader   = "X-Region"
+	CurrencyCookie = "currency"
+	RegionCookie   = "region"
+)
+
+var (
+	currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+	regionRegexp   = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// Market is the currency and the region a customer shops in, it selects the price list.
+// Regions are ISO 3166 country codes.
+type Market struct {
+	Currency Currency `bson:"currency,omitempty" json:"currency,omitempty"`
+	Region   string   `bson:"region,omitempty" json:"region,omitempty"`
+}
+
+// NewMarket returns the market with upper cased codes.
+// A region without a currency is in DefaultCurrency.
+func NewMarket(currency string, region string) Market {
+	m := Market{
+		Currency: Currency(strings.ToUpper(strings.TrimSpace(currency))),
+		Region:   strings.ToUpper(strings.TrimSpace(region)),
+	}
+	if m.Currency == "" && m.Region != "" {
+		m.Currency = DefaultCurrency
+	}
+	return m
+}
+
+// RequestMarket returns the market selected by the request, a zero one if none is selected.
+func RequestMarket(r *http.Request) (Market, error) {
+	m := NewMarket(r.Header.Get(CurrencyHeader), r.Header.Get(RegionHeader))
+	if m.IsZero() {
+		var currency, region string
+		if c, err := r.Cookie(CurrencyCookie); err == nil {
+			currency = c.Value
+		}
+		if c, err := r.Cookie(RegionCookie); err == nil {
+			region = c.Value
+		}
+		m = NewMarket(currency, region)
+	}
+	if err := m.Validate(); err != nil {
+		return Market{}, err
+	}
+	return m, nil
+}
+
+// IsZero reports whether no market is selected.
+func (m Market) IsZero() bool {
+	return m.Currency == "" && m.Region == ""
+}
+
+// Validate checks the currency and the region codes.
+func (m Market) Validate() error {
+	if m.Currency != "" && !currencyRegexp.MatchString(string(m.Currency)) {
+		return fmt.Errorf("currency %q is not an ISO 4217 code like EUR", m.Currency)
+	}
+	if m.Region != "" && !regionRegexp.MatchString(m.Region) {
+		return fmt.Errorf("region %q is not an ISO 3166 country code like DE", m.Region)
+	}
+	return nil
+}
+
+// PriceListID returns the id of the price list of a currency in a region, like "EUR-DE",
+// or "EUR" for the list of the currency in all the regions.
+func PriceListID(currency Currency, region string) string {
+	if region == "" {
+		return string(currency)
+	}
+	return string(currency) + "-" + region
+}
+
+// PriceList prices SKUs in a currency for a region, see deploy/mongo/price_lists.json.
+// SKUs the list has no price for are priced from the base prices, the variation prices
+// in DefaultCurrency, converted at the exchange rate of the list.
+type PriceList struct {
+	ID       string   `bson:"_id" json:"id"`
+	Name     string   `bson:"name" json:"name"`
+	Currency Currency `bson:"currency" json:"currency"`
+	Region   string   `bson:"region,omitempty" json:"region,omitempty"`
+	// ExchangeRate is the amount of the list currency for one unit of DefaultCurrency.
+	ExchangeRate  float64   `bson:"exchange_rate" json:"exchange_rate"`
+	RateUpdatedOn time.Time `bson:"rate_updated_on" json:"rate_updated_on"`
+	CreatedOn     time.Time `bson:"created_on" json:"createdOn"`
+	ModifiedOn    time.Time `bson:"modified_on" json:"modifiedOn"`
+}
+
+// Rate returns the exchange rate of the list, the nil list of the base prices has the rate of 1.
+func (l *PriceList) Rate() *ExchangeRate {
+	if l == nil {
+		return &ExchangeRate{From: DefaultCurrency, To: DefaultCurrency, Rate: 1}
+	}
+	return &ExchangeRate{
+		PriceList: l.ID,
+		From:      DefaultCurrency,
+		To:        l.Currency,
+		Rate:      l.ExchangeRate,
+		On:        l.RateUpdatedOn,
+	}
+}
+
+// Resolve returns the pricing of the SKUs in the list: the list prices,
+// or the base prices converted at the exchange rate. The nil list returns the base prices.
+func (l *PriceList) Resolve(base map[string]*Pricing, listed map[string]*Pricing) map[string]*Pricing {
+	if l == nil {
+		return base
+	}
+	rate := l.Rate()
+	prices := make(map[string]*Pricing, len(base))
+	for sku, pricing := range base {
+		prices[sku] = rate.ConvertPricing(pricing)
+	}
+	for sku, pricing := range listed {
+		prices[sku] = pricing
+	}
+	return prices
+}
+
+// ExchangeRate is the rate amounts in From were converted to To at.
+// Orders keep the rate they were priced with.
+type ExchangeRate struct {
+	PriceList string    `bson:"price_list,omitempty" json:"price_list,omitempty"`
+	From      Currency  `bson:"from" json:"from"`
+	To        Currency  `bson:"to" json:"to"`
+	Rate      float64   `bson:"rate" json:"rate"`
+	On        time.Time `bson:"on,omitempty" json:"on,omitempty"`
+}
+
+// Convert converts an amount in From to To rounding half away from zero to the minor units.
+// Amounts in other currencies are returned as they are.
+func (r *ExchangeRate) Convert(m Money) Money {
+	if r == nil || r.From == r.To || m.CurrencyOrDefault() != r.From {
+		return m
+	}
+	return MoneyFromFloat(m.Float()*r.Rate, r.To)
+}
+
+// ConvertPricing converts the prices of the pricing, see Convert.
+func (r *ExchangeRate) ConvertPricing(p *Pricing) *Pricing {
+	if p == nil {
+		return nil
+	}
+	converted := &Pricing{Price: r.Convert(p.Price)}
+	if p.Sale != nil {
+		converted.Sale = &Sale{
+			SalePrice:   r.Convert(p.Sale.SalePrice),
+			SaleEndDate: p.Sale.SaleEndDate,
+		}
+	}
+	return converted
+}
+
+// PriceListInput is a price list to create.
+type PriceListInput struct {
+	Name         string   `json:"name"`
+	Currency     Currency `json:"currency"`
+	Region       string   `json:"region"`
+	ExchangeRate float64  `json:"exchange_rate"`
+}
+
+// Validate checks the price list, the codes are upper cased.
+func (in *PriceListInput) Validate() error {
+	var v Validation
+	market := NewMarket(string(in.Currency), in.Region)
+	in.Currency, in.Region = market.Currency, market.Region
+	if strings.TrimSpace(in.Name) == "" {
+		v.Add("name", "is required")
+	}
+	if in.Currency == "" {
+		v.Add("currency", "is required")
+	} else if !currencyRegexp.MatchString(string(in.Currency)) {
+		v.Add("currency", "must be an ISO 4217 code like EUR")
+	}
+	if in.Region != "" && !regionRegexp.MatchString(in.Region) {
+		v.Add("region", "must be an ISO 3166 country code like DE")
+	}
+	validateExchangeRate(in.Currency, in.ExchangeRate, &v)
+	return v.Err()
+}
+
+// PriceList returns the price list of a validated input.
+func (in *PriceListInput) PriceList(createdTime time.Time) *PriceList {
+	createdTime = createdTime.Round(time.Second)
+	return &PriceList{
+		ID:            PriceListID(in.Currency, in.Region),
+		Name:          strings.TrimSpace(in.Name),
+		Currency:      in.Currency,
+		Region:        in.Region,
+		ExchangeRate:  in.ExchangeRate,
+		RateUpdatedOn: createdTime,
+		CreatedOn:     createdTime,
+		ModifiedOn:    createdTime,
+	}
+}
+
+// PriceListPatch holds the price list fields to change, missing ones are left as they are.
+type PriceListPatch struct {
+	Name         *string  `json:"name"`
+	ExchangeRate *float64 `json:"exchange_rate"`
+}
+
+// Validate checks the patch of a list in the given currency.
+func (p *PriceListPatch) Validate(currency Currency) error {
+	var v Validation
+	if p.Name != nil && strings.TrimSpace(*p.Name) == "" {
+		v.Add("name", "is required")
+	}
+	if p.ExchangeRate != nil {
+		validateExchangeRate(currency, *p.ExchangeRate, &v)
+	}
+	return v.Err()
+}
+
+// Apply applies the patch to the price list.
+func (p *PriceListPatch) Apply(list *PriceList, modifiedTime time.Time) {
+	modifiedTime = modifiedTime.Round(time.Second)
+	if p.Name != nil {
+		list.Name = strings.TrimSpace(*p.Name)
+	}
+	if p.ExchangeRate != nil && *p.ExchangeRate != list.ExchangeRate {
+		list.ExchangeRate = *p.ExchangeRate
+		list.RateUpdatedOn = modifiedTime
+	}
+	list.ModifiedOn = modifiedTime
+}
+
+func validateExchangeRate(currency Currency, rate float64, v *Validation) {
+	switch {
+	case rate <= 0:
+		v.Add("exchange_rate", "must be positive")
+	case currency == DefaultCurrency && rate != 1:
+		v.Add("exchange_rate", "must be 1 for %s price lists", DefaultCurrency)
+	}
+}
+
+// PriceListPrice is the price of a SKU in a price list, prices are decimal strings like "69.99"
+// in the currency of the list.
+type PriceListPrice struct {
+	SKU         string `json:"sku"`
+	Price       string `json:"price"`
+	SalePrice   string `json:"sale_price,omitempty"`
+	SaleEndDate string `json:"sale_end_date,omitempty"`
+}
+
+// ValidatePriceListPrices checks the prices to set in a list in the given currency.
+func ValidatePriceListPrices(prices []*PriceListPrice, currency Currency) error {
+	var v Validation
+	if len(prices) == 0 {
+		v.Add("prices", "at least one price is required")
+	}
+	seen := map[string]int{}
+	for i, p := range prices {
+		field := fmt.Sprintf("prices[%d]", i)
+		if p == nil {
+			v.Add(field, "is empty")
+			continue
+		}
+		if !productIDRegexp.MatchString(p.SKU) {
+			v.Add(field+".sku", "must be letters and digits like 054VA72303012P")
+		}
+		if j, ok := seen[p.SKU]; ok {
+			v.Add(field+".sku", "duplicates prices[%d].sku", j)
+		}
+		seen[p.SKU] = i
+		validatePricing(field+".", p.Price, p.SalePrice, p.SaleEndDate, currency, &v)
+	}
+	return v.Err()
+}
+
+// Pricing returns the pricing of a validated price in the given currency.
+func (p *PriceListPrice) Pricing(currency Currency) *Pricing {
+	return newPricing(p.Price, p.SalePrice, p.SaleEndDate, currency)
+}
+
+// NewPriceListPrice returns the price of the SKU in the form it is set in.
+func NewPriceListPrice(sku string, pricing *Pricing) *PriceListPrice {
+	price := &PriceListPrice{SKU: sku, Price: pricing.Price.String()}
+	if sale := pricing.Sale; sale != nil && sale.SalePrice.IsPositive() {
+		price.SalePrice = sale.SalePrice.String()
+		price.SaleEndDate = sale.SaleEndDate
+	}
+	return price
+}
@@ -1,29 +1,33 @@
 package erp
 
 import (
+	"errors"
 	"time"
 )
 
+// Product errors.
+var ErrProductNotFound = errors.New("product not found")
+
 type CatalogProduct struct {
-	ID        string  `json:"id,omitempty"`
-	Name      string  `json:"name"`
-	Price     float64 `json:"price"`
-	Thumbnail string  `json:"thumbnail"`
+	ID        string `bson:"_id" json:"id,omitempty"`
+	Name      string `bson:"name" json:"name"`
+	Price     Money  `bson:"-" json:"price"`
+	Thumbnail string `bson:"-" json:"thumbnail"`
 }
 
 type Product struct {
-	ID          int    `json:"id,omitempty"`
-	Category    string `json:"category"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Attributes  []struct {
-		Name  string   `json:"name"`
-		Value []string `json:"value,omitempty"`
-	} `json:"attributes,omitempty"`
-	Variations []*Variation `json:"variations"`
-	Variation  *Variation   `json:"variation"`
-	CreatedOn  time.Time    `json:"createdOn"`
-	ModifiedOn time.Time    `json:"modifiedOn"`
+	ID int `json:"id,omitempty"`
+	// Categories are the ids of the categories the product is listed in,
+	// PrimaryCategory is the one of them used for breadcrumbs.
+	Categories      []string            `bson:"categories" json:"categories"`
+	PrimaryCategory string              `bson:"primary_category" json:"primary_category"`
+	Name            string              `json:"name"`
+	Description     string              `json:"description"`
+	Attributes      []*ProductAttribute `json:"attributes,omitempty"`
+	Variations      []*Variation        `json:"variations"`
+	Variation       *Variation          `json:"variation"`
+	CreatedOn       time.Time           `json:"createdOn"`
+	ModifiedOn      time.Time           `json:"modifiedOn"`
 }
 
 type Variation struct {
@@ -37,7 +41,7 @@ type Variation struct {
 		Name  string `json:"name"`
 		Value string `json:"value"`
 	} `json:"attributes"`
-	Price     string `json:"price"`
+	Price     Money  `json:"price"`
 	Thumbnail string `json:"thumbnail"`
 	Images    []struct {
 		Src string `json:"src"`
@@ -122,11 +126,38 @@ type Dimensions struct {
 }
 
 type Pricing struct {
-	Price float64 `json:"price"`
-	Sale  *Sale   `json:"sale"`
+	Price Money `bson:"price" json:"price"`
+	Sale  *Sale `bson:"sale" json:"sale"`
 }
 
 type Sale struct {
-	SalePrice   float64 `json:"sale_price"`
-	SaleEndDate string  `json:"sale_end_date"`
+	SalePrice   Money  `bson:"salePrice" json:"sale_price"`
+	SaleEndDate string `bson:"saleEndDate" json:"sale_end_date"`
+}
+
+// SaleEndDateLayout is the layout of Sale.SaleEndDate, see deploy/mongo/prices.json.
+const SaleEndDateLayout = "2006-01-02 15:04:05"
+
+// IsActive reports whether the sale is on at the given time.
+// A sale without an end date lasts until it is removed.
+func (s *Sale) IsActive(now time.Time) bool {
+	if s == nil || !s.SalePrice.IsPositive() {
+		return false
+	}
+	if s.SaleEndDate == "" {
+		return true
+	}
+	end, err := time.ParseInLocation(SaleEndDateLayout, s.SaleEndDate, now.Location())
+	if err != nil {
+		return false
+	}
+	return now.Before(end)
+}
+
+// Current returns the price at the given time, the sale price while the sale is on.
+func (p *Pricing) Current(now time.Time) Money {
+	if p.Sale.IsActive(now) && p.Sale.SalePrice.SameCurrency(p.Price) && p.Sale.SalePrice.Less(p.Price) {
+		return p.Sale.SalePrice
+	}
+	return p.Price
 }
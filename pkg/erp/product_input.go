@@ -0,0 +1,289 @@
+package erp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Variation errors.
+var (
+	ErrVariationIsDefault = errors.New("the default variation can't be deleted, make another variation default first")
+	ErrLastVariation      = errors.New("the last variation of a product can't be deleted, delete the product instead")
+	ErrNoDefaultVariation = errors.New("a product needs a default variation, make another variation default instead")
+)
+
+var (
+	productIDRegexp = regexp.MustCompile(`^[A-Za-z0-9]+([-_][A-Za-z0-9]+)*$`)
+	priceRegexp     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+)
+
+// ProductInput is a product to create along with its variations.
+type ProductInput struct {
+	ID              string              `json:"id"`
+	Name            string              `json:"name"`
+	Description     []*LangValue        `json:"description"`
+	Brand           *Brand              `json:"brand,omitempty"`
+	Categories      []string            `json:"categories"`
+	PrimaryCategory string              `json:"primary_category"`
+	Attributes      []*ProductAttribute `json:"attributes"`
+	Variations      []*VariationInput   `json:"variations"`
+}
+
+// VariationInput is a variation to create, prices are decimal strings like "69.99".
+type VariationInput struct {
+	SKU         string       `json:"sku"`
+	Default     bool         `json:"default"`
+	Price       string       `json:"price"`
+	SalePrice   string       `json:"sale_price,omitempty"`
+	SaleEndDate string       `json:"sale_end_date,omitempty"`
+	Thumbnail   string       `json:"thumbnail,omitempty"`
+	Attributes  []*NameValue `json:"attributes"`
+}
+
+// ProductPatch holds the product fields to change, missing ones are left as they are.
+type ProductPatch struct {
+	Name        *string      `json:"name"`
+	Description []*LangValue `json:"description"`
+	Brand       *Brand       `json:"brand"`
+}
+
+// VariationPatch holds the variation fields to change, missing ones are left as they are.
+// Attributes replace all the attributes of the variation.
+type VariationPatch struct {
+	Default     *bool        `json:"default"`
+	Price       *string      `json:"price"`
+	SalePrice   *string      `json:"sale_price"`
+	SaleEndDate *string      `json:"sale_end_date"`
+	Thumbnail   *string      `json:"thumbnail"`
+	Attributes  []*NameValue `json:"attributes"`
+}
+
+// Validate checks the product and its variations.
+// Attribute names are replaced with their dictionary names.
+func (in *ProductInput) Validate(dict AttributeDictionary) error {
+	var v Validation
+	if !productIDRegexp.MatchString(in.ID) {
+		v.Add("id", "must be letters and digits like 054VA72303012P")
+	}
+	if strings.TrimSpace(in.Name) == "" {
+		v.Add("name", "is required")
+	}
+	if in.PrimaryCategory != "" && !containsString(in.Categories, in.PrimaryCategory) {
+		v.Add("primary_category", "must be one of the categories")
+	}
+	for i, a := range in.Attributes {
+		field := fmt.Sprintf("attributes[%d]", i)
+		if a == nil {
+			v.Add(field, "is empty")
+			continue
+		}
+		name, ok := dict.Name(a.Name)
+		if !ok {
+			v.Add(field+".name", "%q is not in the attribute dictionary", a.Name)
+		}
+		a.Name = name
+		if len(a.Value) == 0 {
+			v.Add(field+".value", "is required")
+		}
+		for j, value := range a.Value {
+			if strings.TrimSpace(value) == "" {
+				v.Add(fmt.Sprintf("%s.value[%d]", field, j), "is empty")
+			}
+		}
+	}
+
+	if len(in.Variations) == 0 {
+		v.Add("variations", "at least one variation is required")
+	}
+	defaults := 0
+	for i, variation := range in.Variations {
+		field := fmt.Sprintf("variations[%d]", i)
+		if variation == nil {
+			v.Add(field, "is empty")
+			continue
+		}
+		variation.validate(field+".", dict, &v)
+		if variation.Default {
+			defaults++
+		}
+		for j, other := range in.Variations[:i] {
+			if other == nil {
+				continue
+			}
+			if other.SKU == variation.SKU {
+				v.Add(field+".sku", "duplicates variations[%d].sku", j)
+			}
+			if other.Combination() == variation.Combination() {
+				v.Add(field+".attributes", "same attributes as variations[%d]", j)
+			}
+		}
+	}
+	if len(in.Variations) > 0 && defaults != 1 {
+		v.Add("variations", "exactly one variation must be default, got %d", defaults)
+	}
+	return v.Err()
+}
+
+// Validate checks the patch.
+func (p *ProductPatch) Validate() error {
+	var v Validation
+	if p.Name != nil && strings.TrimSpace(*p.Name) == "" {
+		v.Add("name", "is required")
+	}
+	return v.Err()
+}
+
+// ValidateVariation checks a variation to create or a patched one against the other variations of its product.
+// Attribute names are replaced with their dictionary names.
+func ValidateVariation(variation *VariationInput, others []*VariationInput, dict AttributeDictionary) error {
+	var v Validation
+	variation.validate("", dict, &v)
+	for _, other := range others {
+		if other.SKU == variation.SKU {
+			v.Add("sku", "%s is already a variation of the product", variation.SKU)
+		}
+		if other.Combination() == variation.Combination() {
+			v.Add("attributes", "same attributes as variation %s", other.SKU)
+		}
+	}
+	return v.Err()
+}
+
+// validate records the invalid fields of the variation, prefix is prepended to the field names.
+func (in *VariationInput) validate(prefix string, dict AttributeDictionary, v *Validation) {
+	if !productIDRegexp.MatchString(in.SKU) {
+		v.Add(prefix+"sku", "must be letters and digits like 054VA72303012P")
+	}
+	validatePricing(prefix, in.Price, in.SalePrice, in.SaleEndDate, DefaultCurrency, v)
+	if len(in.Attributes) == 0 {
+		v.Add(prefix+"attributes", "at least one attribute is required")
+	}
+	seen := map[string]int{}
+	for i, a := range in.Attributes {
+		field := fmt.Sprintf("%sattributes[%d]", prefix, i)
+		if a == nil {
+			v.Add(field, "is empty")
+			continue
+		}
+		name, ok := dict.Name(a.Name)
+		if !ok {
+			v.Add(field+".name", "%q is not in the attribute dictionary", a.Name)
+		}
+		a.Name = name
+		if strings.TrimSpace(a.Value) == "" {
+			v.Add(field+".value", "is required")
+		}
+		if j, ok := seen[AttributeKey(a.Name)]; ok {
+			v.Add(field+".name", "duplicates attributes[%d].name", j)
+		}
+		seen[AttributeKey(a.Name)] = i
+	}
+}
+
+// Combination returns the attribute values of the variation in a comparable form,
+// the variations of a product differ in their combinations.
+func (in *VariationInput) Combination() string {
+	ids := make([]string, 0, len(in.Attributes))
+	for _, a := range in.Attributes {
+		if a != nil {
+			ids = append(ids, AttributeID(a.Name, a.Value))
+		}
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, "&")
+}
+
+// Facets returns the facet ids of the variation attributes.
+func (in *VariationInput) Facets() []string {
+	ids := make([]string, 0, len(in.Attributes))
+	for _, a := range in.Attributes {
+		ids = append(ids, AttributeID(a.Name, a.Value))
+	}
+	return ids
+}
+
+// Pricing returns the pricing of a validated variation.
+func (in *VariationInput) Pricing() *Pricing {
+	return newPricing(in.Price, in.SalePrice, in.SaleEndDate, DefaultCurrency)
+}
+
+// Apply applies the patch to the variation.
+func (p *VariationPatch) Apply(variation *VariationInput) {
+	if p.Default != nil {
+		variation.Default = *p.Default
+	}
+	if p.Price != nil {
+		variation.Price = *p.Price
+	}
+	if p.SalePrice != nil {
+		variation.SalePrice = *p.SalePrice
+	}
+	if p.SaleEndDate != nil {
+		variation.SaleEndDate = *p.SaleEndDate
+	}
+	if p.Thumbnail != nil {
+		variation.Thumbnail = *p.Thumbnail
+	}
+	if p.Attributes != nil {
+		variation.Attributes = p.Attributes
+	}
+}
+
+// validatePricing records the invalid price fields, prefix is prepended to the field names.
+func validatePricing(prefix string, price string, salePrice string, saleEndDate string, currency Currency, v *Validation) {
+	regular, err := parsePrice(price, currency)
+	if err != nil {
+		v.Add(prefix+"price", "%s", err)
+	}
+	if salePrice != "" {
+		sale, err := parsePrice(salePrice, currency)
+		if err != nil {
+			v.Add(prefix+"sale_price", "%s", err)
+		} else if regular.IsPositive() && !sale.Less(regular) {
+			v.Add(prefix+"sale_price", "must be lower than the price")
+		}
+	}
+	if saleEndDate != "" {
+		if _, err := time.Parse(SaleEndDateLayout, saleEndDate); err != nil {
+			v.Add(prefix+"sale_end_date", "must be a date like %s", SaleEndDateLayout)
+		}
+	}
+}
+
+// newPricing returns the pricing of validated prices.
+func newPricing(price string, salePrice string, saleEndDate string, currency Currency) *Pricing {
+	pricing := &Pricing{}
+	pricing.Price, _ = parsePrice(price, currency)
+	if salePrice != "" {
+		pricing.Sale = &Sale{SaleEndDate: saleEndDate}
+		pricing.Sale.SalePrice, _ = parsePrice(salePrice, currency)
+	}
+	return pricing
+}
+
+// ParsePrice parses a positive decimal price in DefaultCurrency with two decimal places at most, like 69.99.
+func ParsePrice(s string) (Money, error) {
+	return parsePrice(s, DefaultCurrency)
+}
+
+// parsePrice parses a positive decimal price with the minor units of the currency at most.
+func parsePrice(s string, currency Currency) (Money, error) {
+	if !priceRegexp.MatchString(s) {
+		return Money{}, fmt.Errorf("%q is not a price like 69.99", s)
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 > currency.MinorUnits() {
+		return Money{}, fmt.Errorf("%q has more than %d decimal places", s, currency.MinorUnits())
+	}
+	price, err := ParseMoney(s, currency)
+	if err != nil {
+		return Money{}, fmt.Errorf("%q is not a price like 69.99", s)
+	}
+	if !price.IsPositive() {
+		return Money{}, errors.New("must be positive")
+	}
+	return price, nil
+}
@@ -0,0 +1,29 @@
+package erp
+
+// SearchDocument is a product as the catalog search sees it.
+type SearchDocument struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description []string `json:"description"`
+	Attributes  []string `json:"attributes"`
+	Price       Money    `json:"price"`
+	Thumbnail   string   `json:"thumbnail"`
+}
+
+// CatalogProduct returns the product card of the document.
+func (d *SearchDocument) CatalogProduct() *CatalogProduct {
+	return &CatalogProduct{
+		ID:        d.ID,
+		Name:      d.Name,
+		Price:     d.Price,
+		Thumbnail: d.Thumbnail,
+	}
+}
+
+// SearchResult is a page of search results ordered by relevance.
+type SearchResult struct {
+	Products []*CatalogProduct `json:"products"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	Limit    int               `json:"limit"`
+}
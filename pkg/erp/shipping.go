@@ -0,0 +1,248 @@
+package erp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shipping errors.
+var (
+	ErrNoDeliveryOptions      = errors.New("no delivery options for the destination")
+	ErrUnknownDeliveryOption  = errors.New("delivery option is not available for the cart")
+	ErrMissingShippingDetails = errors.New("sku has no package dimensions or weight")
+)
+
+// Parcel units, dimensions and weights of variations are converted to them.
+const (
+	DimensionUnit = "cm"
+	WeightUnit    = "kg"
+)
+
+var dimensionUnits = map[string]float64{
+	"":   1,
+	"mm": 0.1,
+	"cm": 1,
+	"m":  100,
+	"in": 2.54,
+}
+
+var weightUnits = map[string]float64{
+	"":   1,
+	"g":  0.001,
+	"kg": 1,
+	"lb": 0.45359237,
+}
+
+// Carrier quotes the delivery of parcels, see pkg/shipping.
+type Carrier interface {
+	// Name is stored on the order with the delivery option.
+	Name() string
+	// Quote returns the delivery options of the carrier for the parcel, prices are in DefaultCurrency.
+	// The parcel may not be deliverable by the carrier, then no options are returned.
+	Quote(ctx context.Context, parcel *Parcel, destination *ShippingDestination) ([]*DeliveryOption, error)
+}
+
+// ShippingDestination is where the parcel is delivered to.
+// Zone is the delivery zone of the destination, found by its location.
+type ShippingDestination struct {
+	Country  string    `bson:"country" json:"country"`
+	Postcode string    `bson:"postcode,omitempty" json:"postcode,omitempty"`
+	Location *Location `bson:"location,omitempty" json:"location,omitempty"`
+	Zone     string    `bson:"zone,omitempty" json:"zone,omitempty"`
+}
+
+// Normalize upper cases the country and removes the spaces of the postcode.
+// The zone is not taken from clients, it is found by the location.
+func (d *ShippingDestination) Normalize() {
+	d.Country = strings.ToUpper(strings.TrimSpace(d.Country))
+	d.Postcode = strings.ReplaceAll(strings.TrimSpace(d.Postcode), " ", "")
+	d.Zone = ""
+}
+
+// Validate checks the destination.
+func (d *ShippingDestination) Validate() error {
+	var v Validation
+	if d.Country == "" {
+		v.Add("country", "is required")
+	} else if !regionRegexp.MatchString(d.Country) {
+		v.Add("country", "must be an ISO 3166 country code like RU")
+	}
+	if d.Location != nil {
+		if err := d.Location.Validate(); err != nil {
+			v.Add("location", "%s", err)
+		}
+	}
+	return v.Err()
+}
+
+// Parcel is the package the cart is shipped in, items are stacked on top of each other.
+// Dimensions are in DimensionUnit, weights in WeightUnit.
+type Parcel struct {
+	Items  int     `bson:"items" json:"items"`
+	Length float64 `bson:"length" json:"length"`
+	Width  float64 `bson:"width" json:"width"`
+	Height float64 `bson:"height" json:"height"`
+	Weight float64 `bson:"weight" json:"weight"`
+}
+
+// NewParcel packs the cart lines with the package details of their SKUs.
+// Lines without package details are listed in the error.
+func NewParcel(products []*CartProduct, details map[string]*Shipping) (*Parcel, error) {
+	parcel := &Parcel{}
+	var missing []string
+	for _, p := range products {
+		d, ok := details[p.SKU]
+		if !ok || d == nil {
+			missing = append(missing, p.SKU)
+			continue
+		}
+		length, width, height, err := d.Dimensions.Centimeters()
+		if err != nil {
+			return nil, fmt.Errorf("sku %s: %v", p.SKU, err)
+		}
+		weight, err := d.Weight.Kilograms()
+		if err != nil {
+			return nil, fmt.Errorf("sku %s: %v", p.SKU, err)
+		}
+		parcel.Items += p.Quantity
+		parcel.Length = math.Max(parcel.Length, length)
+		parcel.Width = math.Max(parcel.Width, width)
+		parcel.Height += height * float64(p.Quantity)
+		parcel.Weight += weight * float64(p.Quantity)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%v: %v", ErrMissingShippingDetails, missing)
+	}
+	return parcel, nil
+}
+
+// VolumetricWeight returns the weight carriers charge for the size of the parcel,
+// the volume in cubic centimeters divided by the divisor of the carrier, like 5000.
+func (p *Parcel) VolumetricWeight(divisor float64) float64 {
+	if divisor <= 0 {
+		return 0
+	}
+	return p.Length * p.Width * p.Height / divisor
+}
+
+// ChargeableWeight returns the larger of the actual and the volumetric weights.
+func (p *Parcel) ChargeableWeight(divisor float64) float64 {
+	return math.Max(p.Weight, p.VolumetricWeight(divisor))
+}
+
+// DeliveryOption is a way to deliver the parcel, its price and the delivery estimate.
+type DeliveryOption struct {
+	// ID identifies the option among the options of the cart, like "local/courier".
+	ID      string `bson:"id" json:"id"`
+	Carrier string `bson:"carrier" json:"carrier"`
+	Name    string `bson:"name" json:"name"`
+	Zone    string `bson:"zone,omitempty" json:"zone,omitempty"`
+	Price   Money  `bson:"price" json:"price"`
+	// RegularPrice is the price before a free shipping coupon.
+	RegularPrice *Money `bson:"regular_price,omitempty" json:"regular_price,omitempty"`
+	// ChargeableWeight is the weight the price is calculated for.
+	ChargeableWeight float64 `bson:"chargeable_weight" json:"chargeable_weight"`
+	MinDays          int     `bson:"min_days" json:"min_days"`
+	MaxDays          int     `bson:"max_days" json:"max_days"`
+	// EstimatedFrom and EstimatedTo are the delivery dates expected at the time of the quote.
+	EstimatedFrom time.Time `bson:"estimated_from" json:"estimated_from"`
+	EstimatedTo   time.Time `bson:"estimated_to" json:"estimated_to"`
+}
+
+// Estimate sets the delivery dates of the option for a parcel sent at the given time.
+func (o *DeliveryOption) Estimate(sentTime time.Time) {
+	day := time.Date(sentTime.Year(), sentTime.Month(), sentTime.Day(), 0, 0, 0, 0, sentTime.Location())
+	o.EstimatedFrom = day.AddDate(0, 0, o.MinDays)
+	o.EstimatedTo = day.AddDate(0, 0, o.MaxDays)
+}
+
+// DeliveryQuote lists the delivery options of a cart, cheapest first.
+type DeliveryQuote struct {
+	Destination *ShippingDestination `json:"destination"`
+	Parcel      *Parcel              `json:"parcel"`
+	Currency    Currency             `json:"currency"`
+	Options     []*DeliveryOption    `json:"options"`
+}
+
+// Option returns the option with the given id.
+func (q *DeliveryQuote) Option(id string) (*DeliveryOption, bool) {
+	for _, o := range q.Options {
+		if o.ID == id {
+			return o, true
+		}
+	}
+	return nil, false
+}
+
+// Centimeters returns the length, width and height in centimeters.
+func (d *Dimensions) Centimeters() (float64, float64, float64, error) {
+	if d == nil {
+		return 0, 0, 0, errors.New("dimensions are missing")
+	}
+	scale, ok := dimensionUnits[strings.ToLower(strings.TrimSpace(d.Unit))]
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("unknown dimension unit %q", d.Unit)
+	}
+	var values [3]float64
+	for i, s := range []string{d.Length, d.Width, d.Height} {
+		value, err := parseMeasure(s)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid dimension %q", s)
+		}
+		values[i] = value * scale
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// Kilograms returns the weight in kilograms.
+// Older variations have the weight unit set to "cm", it is read as the default unit.
+func (w *Weight) Kilograms() (float64, error) {
+	if w == nil {
+		return 0, errors.New("weight is missing")
+	}
+	unit := strings.ToLower(strings.TrimSpace(w.Unit))
+	if unit == "cm" {
+		unit = ""
+	}
+	scale, ok := weightUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown weight unit %q", w.Unit)
+	}
+	value, err := parseMeasure(w.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid weight %q", w.Value)
+	}
+	return value * scale, nil
+}
+
+// NewDimensions returns the dimensions of the parcel in DimensionUnit.
+func NewDimensions(length float64, width float64, height float64) *Dimensions {
+	return &Dimensions{
+		Length: formatMeasure(length),
+		Width:  formatMeasure(width),
+		Height: formatMeasure(height),
+		Unit:   DimensionUnit,
+	}
+}
+
+// NewWeight returns the weight in WeightUnit.
+func NewWeight(weight float64) *Weight {
+	return &Weight{Value: formatMeasure(weight), Unit: WeightUnit}
+}
+
+func parseMeasure(s string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", "."), 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, errors.New("invalid measure")
+	}
+	return value, nil
+}
+
+func formatMeasure(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
@@ -28,6 +28,11 @@ type User struct {
 	Phone            int64              `bson:"phone" json:"phone"`
 	Birthday         time.Time          `bson:"birthday" json:"birthday"`
 	ConstDiscount    uint8              `bson:"const_discount" json:"const_discount"`
+	// IsStaff lets the user to the admin endpoints, it is only set in the database.
+	IsStaff bool `bson:"is_staff" json:"is_staff"`
+	// Currency and Region are the market the user shops in unless a request selects another one.
+	Currency Currency `bson:"currency,omitempty" json:"currency,omitempty"`
+	Region   string   `bson:"region,omitempty" json:"region,omitempty"`
 }
 
 type UserInput struct {
@@ -40,6 +45,8 @@ type UserInput struct {
 	Phone      int64     `json:"phone"`
 	Birthday   time.Time `json:"birthday"`
 	Gender     Gender    `json:"gender"`
+	Currency   string    `json:"currency"`
+	Region     string    `json:"region"`
 }
 
 type UserOutput struct {
@@ -72,6 +79,8 @@ func (in *UserInput) Init(createdTime time.Time) *User {
 		Gender:           in.Gender,
 		ConstDiscount:    0,
 	}
+	market := NewMarket(in.Currency, in.Region)
+	u.Currency, u.Region = market.Currency, market.Region
 	return u
 }
 
@@ -82,7 +91,15 @@ func (in *UserInput) Validate() error {
 	if !in.Gender.IsValid() {
 		return errInvalidGender
 	}
-	return nil
+	return NewMarket(in.Currency, in.Region).Validate()
+}
+
+// Market returns the market of the user profile, a zero one for anonymous users.
+func (u *User) Market() Market {
+	if u == nil {
+		return Market{}
+	}
+	return Market{Currency: u.Currency, Region: u.Region}
 }
 
 type Gender string
@@ -0,0 +1,37 @@
+package erp
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Wishlist keeps products a user saved for later.
+// It is keyed by the user id like the active cart, anonymous users get a tmp user id.
+type Wishlist struct {
+	ID primitive.ObjectID `bson:"_id" json:"-"`
+	// ShareToken gives read-only access to the wishlist to anyone who has it.
+	ShareToken string             `bson:"share_token,omitempty" json:"share_token,omitempty"`
+	Products   []*WishlistProduct `bson:"products" json:"products"`
+	CreatedAt  time.Time          `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt  time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+type WishlistProduct struct {
+	SKU     string    `bson:"sku" json:"sku"`
+	Price   Money     `bson:"-" json:"price"`
+	AddedAt time.Time `bson:"added_at" json:"added_at"`
+}
+
+// Contains reports whether the SKU is in the wishlist.
+func (w *Wishlist) Contains(sku string) bool {
+	if w == nil {
+		return false
+	}
+	for _, p := range w.Products {
+		if p.SKU == sku {
+			return true
+		}
+	}
+	return false
+}
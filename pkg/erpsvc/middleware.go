@@ -24,78 +24,159 @@ type LoggingMiddleware struct {
 	next   Service
 }
 
-func (mw *LoggingMiddleware) GetProduct(ctx context.Context, sku string) (*erp.Product, error) {
+func (mw *LoggingMiddleware) GetProduct(ctx context.Context, sku string, userID string, market erp.Market) (*erp.Product, error) {
 	begin := time.Now()
-	resp, err := mw.next.GetProduct(ctx, sku)
+	resp, err := mw.next.GetProduct(ctx, sku, userID, market)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "GetProduct", "err", err, "took", time.Since(begin))
 	}
 	return resp, err
 }
 
-func (mw *LoggingMiddleware) GetProducts(ctx context.Context) ([]*erp.Product, error) {
+func (mw *LoggingMiddleware) GetProducts(ctx context.Context, page *erp.PageRequest) (*erp.ProductPage, error) {
 	begin := time.Now()
-	resp, err := mw.next.GetProducts(ctx)
+	resp, err := mw.next.GetProducts(ctx, page)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "GetProducts", "err", err, "took", time.Since(begin))
 	}
 	return resp, err
 }
 
-func (mw *LoggingMiddleware) GetCategories(ctx context.Context) ([]*erp.Category, error) {
+func (mw *LoggingMiddleware) GetCategories(ctx context.Context, page *erp.PageRequest) (*erp.CategoryPage, error) {
 	begin := time.Now()
-	resp, err := mw.next.GetCategories(ctx)
+	resp, err := mw.next.GetCategories(ctx, page)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "GetCategories", "err", err, "took", time.Since(begin))
 	}
 	return resp, err
 }
 
-func (mw *LoggingMiddleware) AddAttribute(ctx context.Context, sku string, attribute *erp.NameValue) error {
+func (mw *LoggingMiddleware) GetAttributes(ctx context.Context) ([]*erp.Attribute, error) {
 	begin := time.Now()
-	err := mw.next.AddAttribute(ctx, sku, attribute)
+	attributes, err := mw.next.GetAttributes(ctx)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetAttributes", "err", err, "took", time.Since(begin))
+	}
+	return attributes, err
+}
+
+func (mw *LoggingMiddleware) CreateAttribute(ctx context.Context, name string, value string) (*erp.Attribute, error) {
+	begin := time.Now()
+	attribute, err := mw.next.CreateAttribute(ctx, name, value)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "CreateAttribute", "err", err, "took", time.Since(begin))
+	}
+	return attribute, err
+}
+
+func (mw *LoggingMiddleware) AddAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
+	begin := time.Now()
+	err := mw.next.AddAttribute(ctx, edit)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "AddAttribute", "err", err, "took", time.Since(begin))
 	}
 	return err
 }
 
-func (mw *LoggingMiddleware) RemoveAttribute(ctx context.Context, sku string, attribute string) error {
+func (mw *LoggingMiddleware) RemoveAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
 	begin := time.Now()
-	err := mw.next.RemoveAttribute(ctx, sku, attribute)
+	err := mw.next.RemoveAttribute(ctx, edit)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "RemoveAttribute", "err", err, "took", time.Since(begin))
 	}
 	return err
 }
 
-func (mw *LoggingMiddleware) AddCategory(ctx context.Context, sku string, category *erp.Category) error {
+func (mw *LoggingMiddleware) AddCategory(ctx context.Context, productID string, categoryID string, primary bool) error {
 	begin := time.Now()
-	err := mw.next.AddCategory(ctx, sku, category)
+	err := mw.next.AddCategory(ctx, productID, categoryID, primary)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "AddCategory", "err", err, "took", time.Since(begin))
 	}
 	return err
 }
 
-func (mw *LoggingMiddleware) RemoveCategory(ctx context.Context, sku string, category *erp.Category) error {
+func (mw *LoggingMiddleware) RemoveCategory(ctx context.Context, productID string, categoryID string) error {
 	begin := time.Now()
-	err := mw.next.RemoveCategory(ctx, sku, category)
+	err := mw.next.RemoveCategory(ctx, productID, categoryID)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "RemoveCategory", "err", err, "took", time.Since(begin))
 	}
 	return err
 }
 
-func (mw *LoggingMiddleware) UpdateProduct(ctx context.Context, product *erp.Product) error {
+func (mw *LoggingMiddleware) CreateProduct(ctx context.Context, input *erp.ProductInput) (*erp.ProductInput, error) {
 	begin := time.Now()
-	err := mw.next.UpdateProduct(ctx, product)
+	product, err := mw.next.CreateProduct(ctx, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "CreateProduct", "err", err, "took", time.Since(begin))
+	}
+	return product, err
+}
+
+func (mw *LoggingMiddleware) UpdateProduct(ctx context.Context, id string, patch *erp.ProductPatch) error {
+	begin := time.Now()
+	err := mw.next.UpdateProduct(ctx, id, patch)
 	if err != nil {
 		level.Error(mw.logger).Log("method", "UpdateProduct", "err", err, "took", time.Since(begin))
 	}
 	return err
 }
 
+func (mw *LoggingMiddleware) DeleteProduct(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteProduct(ctx, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeleteProduct", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) CreateVariation(ctx context.Context, productID string, input *erp.VariationInput) (*erp.VariationInput, error) {
+	begin := time.Now()
+	variation, err := mw.next.CreateVariation(ctx, productID, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "CreateVariation", "err", err, "took", time.Since(begin))
+	}
+	return variation, err
+}
+
+func (mw *LoggingMiddleware) UpdateVariation(ctx context.Context, productID string, sku string, patch *erp.VariationPatch) (*erp.VariationInput, error) {
+	begin := time.Now()
+	variation, err := mw.next.UpdateVariation(ctx, productID, sku, patch)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "UpdateVariation", "err", err, "took", time.Since(begin))
+	}
+	return variation, err
+}
+
+func (mw *LoggingMiddleware) DeleteVariation(ctx context.Context, productID string, sku string) error {
+	begin := time.Now()
+	err := mw.next.DeleteVariation(ctx, productID, sku)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeleteVariation", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) ChangeOrderStatus(ctx context.Context, orderID string, status erp.OrderStatus, by string, comment string) (*erp.Order, error) {
+	begin := time.Now()
+	resp, err := mw.next.ChangeOrderStatus(ctx, orderID, status, by, comment)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "ChangeOrderStatus", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) GetOrders(ctx context.Context, status erp.OrderStatus, page *erp.PageRequest) (*erp.OrderPage, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetOrders(ctx, status, page)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetOrders", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
 func NewInstrumentingMiddleware(next Service, prefix string) *InstrumentingMiddleware {
 	return &InstrumentingMiddleware{
 		next: next,
@@ -121,66 +202,597 @@ type InstrumentingMiddleware struct {
 	reqMetrics metrics.Histogram
 }
 
-func (mw *InstrumentingMiddleware) GetProduct(ctx context.Context, sku string) (*erp.Product, error) {
+func (mw *InstrumentingMiddleware) GetProduct(ctx context.Context, sku string, userID string, market erp.Market) (*erp.Product, error) {
 	begin := time.Now()
-	product, err := mw.next.GetProduct(ctx, sku)
+	product, err := mw.next.GetProduct(ctx, sku, userID, market)
 	labels := []string{"method", "GetProduct", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return product, err
 }
 
-func (mw *InstrumentingMiddleware) GetProducts(ctx context.Context) ([]*erp.Product, error) {
+func (mw *InstrumentingMiddleware) GetProducts(ctx context.Context, page *erp.PageRequest) (*erp.ProductPage, error) {
 	begin := time.Now()
-	products, err := mw.next.GetProducts(ctx)
+	products, err := mw.next.GetProducts(ctx, page)
 	labels := []string{"method", "GetProducts", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return products, err
 }
 
-func (mw *InstrumentingMiddleware) GetCategories(ctx context.Context) ([]*erp.Category, error) {
+func (mw *InstrumentingMiddleware) GetCategories(ctx context.Context, page *erp.PageRequest) (*erp.CategoryPage, error) {
 	begin := time.Now()
-	categories, err := mw.next.GetCategories(ctx)
+	categories, err := mw.next.GetCategories(ctx, page)
 	labels := []string{"method", "GetCategories", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return categories, err
 }
 
-func (mw *InstrumentingMiddleware) AddAttribute(ctx context.Context, sku string, attribute *erp.NameValue) error {
+func (mw *InstrumentingMiddleware) GetAttributes(ctx context.Context) ([]*erp.Attribute, error) {
+	begin := time.Now()
+	attributes, err := mw.next.GetAttributes(ctx)
+	labels := []string{"method", "GetAttributes", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return attributes, err
+}
+
+func (mw *InstrumentingMiddleware) CreateAttribute(ctx context.Context, name string, value string) (*erp.Attribute, error) {
+	begin := time.Now()
+	attribute, err := mw.next.CreateAttribute(ctx, name, value)
+	labels := []string{"method", "CreateAttribute", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return attribute, err
+}
+
+func (mw *InstrumentingMiddleware) AddAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
 	begin := time.Now()
-	err := mw.next.AddAttribute(ctx, sku, attribute)
+	err := mw.next.AddAttribute(ctx, edit)
 	labels := []string{"method", "AddAttribute", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return err
 }
 
-func (mw *InstrumentingMiddleware) RemoveAttribute(ctx context.Context, sku string, attribute string) error {
+func (mw *InstrumentingMiddleware) RemoveAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
 	begin := time.Now()
-	err := mw.next.RemoveAttribute(ctx, sku, attribute)
+	err := mw.next.RemoveAttribute(ctx, edit)
 	labels := []string{"method", "RemoveAttribute", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return err
 }
 
-func (mw *InstrumentingMiddleware) AddCategory(ctx context.Context, sku string, category *erp.Category) error {
+func (mw *InstrumentingMiddleware) AddCategory(ctx context.Context, productID string, categoryID string, primary bool) error {
 	begin := time.Now()
-	err := mw.next.AddCategory(ctx, sku, category)
+	err := mw.next.AddCategory(ctx, productID, categoryID, primary)
 	labels := []string{"method", "AddCategory", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return err
 }
 
-func (mw *InstrumentingMiddleware) RemoveCategory(ctx context.Context, sku string, category *erp.Category) error {
+func (mw *InstrumentingMiddleware) RemoveCategory(ctx context.Context, productID string, categoryID string) error {
 	begin := time.Now()
-	err := mw.next.RemoveCategory(ctx, sku, category)
+	err := mw.next.RemoveCategory(ctx, productID, categoryID)
 	labels := []string{"method", "RemoveCategory", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return err
 }
 
-func (mw *InstrumentingMiddleware) UpdateProduct(ctx context.Context, product *erp.Product) error {
+func (mw *InstrumentingMiddleware) CreateProduct(ctx context.Context, input *erp.ProductInput) (*erp.ProductInput, error) {
 	begin := time.Now()
-	err := mw.next.UpdateProduct(ctx, product)
+	product, err := mw.next.CreateProduct(ctx, input)
+	labels := []string{"method", "CreateProduct", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return product, err
+}
+
+func (mw *InstrumentingMiddleware) UpdateProduct(ctx context.Context, id string, patch *erp.ProductPatch) error {
+	begin := time.Now()
+	err := mw.next.UpdateProduct(ctx, id, patch)
 	labels := []string{"method", "UpdateProduct", "error", strconv.FormatBool(err != nil)}
 	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
 	return err
 }
+
+func (mw *InstrumentingMiddleware) DeleteProduct(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteProduct(ctx, id)
+	labels := []string{"method", "DeleteProduct", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) CreateVariation(ctx context.Context, productID string, input *erp.VariationInput) (*erp.VariationInput, error) {
+	begin := time.Now()
+	variation, err := mw.next.CreateVariation(ctx, productID, input)
+	labels := []string{"method", "CreateVariation", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return variation, err
+}
+
+func (mw *InstrumentingMiddleware) UpdateVariation(ctx context.Context, productID string, sku string, patch *erp.VariationPatch) (*erp.VariationInput, error) {
+	begin := time.Now()
+	variation, err := mw.next.UpdateVariation(ctx, productID, sku, patch)
+	labels := []string{"method", "UpdateVariation", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return variation, err
+}
+
+func (mw *InstrumentingMiddleware) DeleteVariation(ctx context.Context, productID string, sku string) error {
+	begin := time.Now()
+	err := mw.next.DeleteVariation(ctx, productID, sku)
+	labels := []string{"method", "DeleteVariation", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) ChangeOrderStatus(ctx context.Context, orderID string, status erp.OrderStatus, by string, comment string) (*erp.Order, error) {
+	begin := time.Now()
+	order, err := mw.next.ChangeOrderStatus(ctx, orderID, status, by, comment)
+	labels := []string{"method", "ChangeOrderStatus", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return order, err
+}
+
+func (mw *InstrumentingMiddleware) GetOrders(ctx context.Context, status erp.OrderStatus, page *erp.PageRequest) (*erp.OrderPage, error) {
+	begin := time.Now()
+	orders, err := mw.next.GetOrders(ctx, status, page)
+	labels := []string{"method", "GetOrders", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return orders, err
+}
+
+func (mw *LoggingMiddleware) Search(ctx context.Context, query string, page int, limit int, userID string, market erp.Market) (*erp.SearchResult, error) {
+	begin := time.Now()
+	resp, err := mw.next.Search(ctx, query, page, limit, userID, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "Search", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) Search(ctx context.Context, query string, page int, limit int, userID string, market erp.Market) (*erp.SearchResult, error) {
+	begin := time.Now()
+	resp, err := mw.next.Search(ctx, query, page, limit, userID, market)
+	labels := []string{"method", "Search", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) FilterProducts(ctx context.Context, filter *erp.FacetFilter, page int, limit int, userID string, market erp.Market) (*erp.FacetedProducts, error) {
+	begin := time.Now()
+	resp, err := mw.next.FilterProducts(ctx, filter, page, limit, userID, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "FilterProducts", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) GetPriceLists(ctx context.Context) ([]*erp.PriceList, error) {
+	begin := time.Now()
+	lists, err := mw.next.GetPriceLists(ctx)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetPriceLists", "err", err, "took", time.Since(begin))
+	}
+	return lists, err
+}
+
+func (mw *LoggingMiddleware) CreatePriceList(ctx context.Context, input *erp.PriceListInput) (*erp.PriceList, error) {
+	begin := time.Now()
+	list, err := mw.next.CreatePriceList(ctx, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "CreatePriceList", "err", err, "took", time.Since(begin))
+	}
+	return list, err
+}
+
+func (mw *LoggingMiddleware) UpdatePriceList(ctx context.Context, id string, patch *erp.PriceListPatch) (*erp.PriceList, error) {
+	begin := time.Now()
+	list, err := mw.next.UpdatePriceList(ctx, id, patch)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "UpdatePriceList", "err", err, "took", time.Since(begin))
+	}
+	return list, err
+}
+
+func (mw *LoggingMiddleware) DeletePriceList(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeletePriceList(ctx, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeletePriceList", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) GetPriceListPrices(ctx context.Context, id string) ([]*erp.PriceListPrice, error) {
+	begin := time.Now()
+	prices, err := mw.next.GetPriceListPrices(ctx, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetPriceListPrices", "err", err, "took", time.Since(begin))
+	}
+	return prices, err
+}
+
+func (mw *LoggingMiddleware) SetPriceListPrices(ctx context.Context, id string, prices []*erp.PriceListPrice) error {
+	begin := time.Now()
+	err := mw.next.SetPriceListPrices(ctx, id, prices)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "SetPriceListPrices", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) RemovePriceListPrice(ctx context.Context, id string, sku string) error {
+	begin := time.Now()
+	err := mw.next.RemovePriceListPrice(ctx, id, sku)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "RemovePriceListPrice", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) GetConfigurator(ctx context.Context, productID string) (*erp.Configurator, error) {
+	begin := time.Now()
+	configurator, err := mw.next.GetConfigurator(ctx, productID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetConfigurator", "err", err, "took", time.Since(begin))
+	}
+	return configurator, err
+}
+
+func (mw *LoggingMiddleware) SetConfigurator(ctx context.Context, productID string, input *erp.ConfiguratorInput) (*erp.Configurator, error) {
+	begin := time.Now()
+	configurator, err := mw.next.SetConfigurator(ctx, productID, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "SetConfigurator", "err", err, "took", time.Since(begin))
+	}
+	return configurator, err
+}
+
+func (mw *LoggingMiddleware) DeleteConfigurator(ctx context.Context, productID string) error {
+	begin := time.Now()
+	err := mw.next.DeleteConfigurator(ctx, productID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeleteConfigurator", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) ConfigureProduct(ctx context.Context, productID string, selected map[string]string, userID string, market erp.Market) (*erp.Configuration, error) {
+	begin := time.Now()
+	conf, err := mw.next.ConfigureProduct(ctx, productID, selected, userID, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "ConfigureProduct", "err", err, "took", time.Since(begin))
+	}
+	return conf, err
+}
+
+func (mw *LoggingMiddleware) GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error) {
+	begin := time.Now()
+	zones, err := mw.next.GetDeliveryZones(ctx)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetDeliveryZones", "err", err, "took", time.Since(begin))
+	}
+	return zones, err
+}
+
+func (mw *LoggingMiddleware) SetDeliveryZone(ctx context.Context, id string, input *erp.DeliveryZoneInput) (*erp.DeliveryZone, error) {
+	begin := time.Now()
+	zone, err := mw.next.SetDeliveryZone(ctx, id, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "SetDeliveryZone", "err", err, "took", time.Since(begin))
+	}
+	return zone, err
+}
+
+func (mw *LoggingMiddleware) DeleteDeliveryZone(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteDeliveryZone(ctx, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeleteDeliveryZone", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *LoggingMiddleware) GetPickupPoints(ctx context.Context) ([]*erp.PickupPoint, error) {
+	begin := time.Now()
+	points, err := mw.next.GetPickupPoints(ctx)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetPickupPoints", "err", err, "took", time.Since(begin))
+	}
+	return points, err
+}
+
+func (mw *LoggingMiddleware) NearestPickupPoints(ctx context.Context, location erp.Location, limit int, radiusKm float64) ([]*erp.NearbyPickupPoint, error) {
+	begin := time.Now()
+	points, err := mw.next.NearestPickupPoints(ctx, location, limit, radiusKm)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "NearestPickupPoints", "err", err, "took", time.Since(begin))
+	}
+	return points, err
+}
+
+func (mw *LoggingMiddleware) SetPickupPoint(ctx context.Context, id string, input *erp.PickupPointInput) (*erp.PickupPoint, error) {
+	begin := time.Now()
+	point, err := mw.next.SetPickupPoint(ctx, id, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "SetPickupPoint", "err", err, "took", time.Since(begin))
+	}
+	return point, err
+}
+
+func (mw *LoggingMiddleware) DeletePickupPoint(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeletePickupPoint(ctx, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeletePickupPoint", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *InstrumentingMiddleware) FilterProducts(ctx context.Context, filter *erp.FacetFilter, page int, limit int, userID string, market erp.Market) (*erp.FacetedProducts, error) {
+	begin := time.Now()
+	resp, err := mw.next.FilterProducts(ctx, filter, page, limit, userID, market)
+	labels := []string{"method", "FilterProducts", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) GetPriceLists(ctx context.Context) ([]*erp.PriceList, error) {
+	begin := time.Now()
+	lists, err := mw.next.GetPriceLists(ctx)
+	labels := []string{"method", "GetPriceLists", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return lists, err
+}
+
+func (mw *InstrumentingMiddleware) CreatePriceList(ctx context.Context, input *erp.PriceListInput) (*erp.PriceList, error) {
+	begin := time.Now()
+	list, err := mw.next.CreatePriceList(ctx, input)
+	labels := []string{"method", "CreatePriceList", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return list, err
+}
+
+func (mw *InstrumentingMiddleware) UpdatePriceList(ctx context.Context, id string, patch *erp.PriceListPatch) (*erp.PriceList, error) {
+	begin := time.Now()
+	list, err := mw.next.UpdatePriceList(ctx, id, patch)
+	labels := []string{"method", "UpdatePriceList", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return list, err
+}
+
+func (mw *InstrumentingMiddleware) DeletePriceList(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeletePriceList(ctx, id)
+	labels := []string{"method", "DeletePriceList", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) GetPriceListPrices(ctx context.Context, id string) ([]*erp.PriceListPrice, error) {
+	begin := time.Now()
+	prices, err := mw.next.GetPriceListPrices(ctx, id)
+	labels := []string{"method", "GetPriceListPrices", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return prices, err
+}
+
+func (mw *InstrumentingMiddleware) SetPriceListPrices(ctx context.Context, id string, prices []*erp.PriceListPrice) error {
+	begin := time.Now()
+	err := mw.next.SetPriceListPrices(ctx, id, prices)
+	labels := []string{"method", "SetPriceListPrices", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) RemovePriceListPrice(ctx context.Context, id string, sku string) error {
+	begin := time.Now()
+	err := mw.next.RemovePriceListPrice(ctx, id, sku)
+	labels := []string{"method", "RemovePriceListPrice", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) GetConfigurator(ctx context.Context, productID string) (*erp.Configurator, error) {
+	begin := time.Now()
+	configurator, err := mw.next.GetConfigurator(ctx, productID)
+	labels := []string{"method", "GetConfigurator", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return configurator, err
+}
+
+func (mw *InstrumentingMiddleware) SetConfigurator(ctx context.Context, productID string, input *erp.ConfiguratorInput) (*erp.Configurator, error) {
+	begin := time.Now()
+	configurator, err := mw.next.SetConfigurator(ctx, productID, input)
+	labels := []string{"method", "SetConfigurator", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return configurator, err
+}
+
+func (mw *InstrumentingMiddleware) DeleteConfigurator(ctx context.Context, productID string) error {
+	begin := time.Now()
+	err := mw.next.DeleteConfigurator(ctx, productID)
+	labels := []string{"method", "DeleteConfigurator", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) ConfigureProduct(ctx context.Context, productID string, selected map[string]string, userID string, market erp.Market) (*erp.Configuration, error) {
+	begin := time.Now()
+	conf, err := mw.next.ConfigureProduct(ctx, productID, selected, userID, market)
+	labels := []string{"method", "ConfigureProduct", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return conf, err
+}
+
+func (mw *InstrumentingMiddleware) GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error) {
+	begin := time.Now()
+	zones, err := mw.next.GetDeliveryZones(ctx)
+	labels := []string{"method", "GetDeliveryZones", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return zones, err
+}
+
+func (mw *InstrumentingMiddleware) SetDeliveryZone(ctx context.Context, id string, input *erp.DeliveryZoneInput) (*erp.DeliveryZone, error) {
+	begin := time.Now()
+	zone, err := mw.next.SetDeliveryZone(ctx, id, input)
+	labels := []string{"method", "SetDeliveryZone", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return zone, err
+}
+
+func (mw *InstrumentingMiddleware) DeleteDeliveryZone(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteDeliveryZone(ctx, id)
+	labels := []string{"method", "DeleteDeliveryZone", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *InstrumentingMiddleware) GetPickupPoints(ctx context.Context) ([]*erp.PickupPoint, error) {
+	begin := time.Now()
+	points, err := mw.next.GetPickupPoints(ctx)
+	labels := []string{"method", "GetPickupPoints", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return points, err
+}
+
+func (mw *InstrumentingMiddleware) NearestPickupPoints(ctx context.Context, location erp.Location, limit int, radiusKm float64) ([]*erp.NearbyPickupPoint, error) {
+	begin := time.Now()
+	points, err := mw.next.NearestPickupPoints(ctx, location, limit, radiusKm)
+	labels := []string{"method", "NearestPickupPoints", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return points, err
+}
+
+func (mw *InstrumentingMiddleware) SetPickupPoint(ctx context.Context, id string, input *erp.PickupPointInput) (*erp.PickupPoint, error) {
+	begin := time.Now()
+	point, err := mw.next.SetPickupPoint(ctx, id, input)
+	labels := []string{"method", "SetPickupPoint", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return point, err
+}
+
+func (mw *InstrumentingMiddleware) DeletePickupPoint(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeletePickupPoint(ctx, id)
+	labels := []string{"method", "DeletePickupPoint", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *LoggingMiddleware) GetCategory(ctx context.Context, id string) (*erp.Category, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetCategory(ctx, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetCategory", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) GetCategory(ctx context.Context, id string) (*erp.Category, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetCategory(ctx, id)
+	labels := []string{"method", "GetCategory", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) CreateCategory(ctx context.Context, input *erp.CategoryInput) (*erp.Category, error) {
+	begin := time.Now()
+	resp, err := mw.next.CreateCategory(ctx, input)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "CreateCategory", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) CreateCategory(ctx context.Context, input *erp.CategoryInput) (*erp.Category, error) {
+	begin := time.Now()
+	resp, err := mw.next.CreateCategory(ctx, input)
+	labels := []string{"method", "CreateCategory", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) RenameCategory(ctx context.Context, id string, name []*erp.LangValue, description []*erp.LangValue) error {
+	begin := time.Now()
+	err := mw.next.RenameCategory(ctx, id, name, description)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "RenameCategory", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *InstrumentingMiddleware) RenameCategory(ctx context.Context, id string, name []*erp.LangValue, description []*erp.LangValue) error {
+	begin := time.Now()
+	err := mw.next.RenameCategory(ctx, id, name, description)
+	labels := []string{"method", "RenameCategory", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *LoggingMiddleware) MoveCategory(ctx context.Context, id string, parentID string) (*erp.Category, error) {
+	begin := time.Now()
+	resp, err := mw.next.MoveCategory(ctx, id, parentID)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "MoveCategory", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) MoveCategory(ctx context.Context, id string, parentID string) (*erp.Category, error) {
+	begin := time.Now()
+	resp, err := mw.next.MoveCategory(ctx, id, parentID)
+	labels := []string{"method", "MoveCategory", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
+
+func (mw *LoggingMiddleware) ReorderCategories(ctx context.Context, parentID string, ids []string) error {
+	begin := time.Now()
+	err := mw.next.ReorderCategories(ctx, parentID, ids)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "ReorderCategories", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *InstrumentingMiddleware) ReorderCategories(ctx context.Context, parentID string, ids []string) error {
+	begin := time.Now()
+	err := mw.next.ReorderCategories(ctx, parentID, ids)
+	labels := []string{"method", "ReorderCategories", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *LoggingMiddleware) DeleteCategory(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteCategory(ctx, id)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "DeleteCategory", "err", err, "took", time.Since(begin))
+	}
+	return err
+}
+
+func (mw *InstrumentingMiddleware) DeleteCategory(ctx context.Context, id string) error {
+	begin := time.Now()
+	err := mw.next.DeleteCategory(ctx, id)
+	labels := []string{"method", "DeleteCategory", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return err
+}
+
+func (mw *LoggingMiddleware) GetCategoryProducts(ctx context.Context, categoryID string, page *erp.PageRequest, userID string, market erp.Market) (*erp.CatalogPage, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetCategoryProducts(ctx, categoryID, page, userID, market)
+	if err != nil {
+		level.Error(mw.logger).Log("method", "GetCategoryProducts", "err", err, "took", time.Since(begin))
+	}
+	return resp, err
+}
+
+func (mw *InstrumentingMiddleware) GetCategoryProducts(ctx context.Context, categoryID string, page *erp.PageRequest, userID string, market erp.Market) (*erp.CatalogPage, error) {
+	begin := time.Now()
+	resp, err := mw.next.GetCategoryProducts(ctx, categoryID, page, userID, market)
+	labels := []string{"method", "GetCategoryProducts", "error", strconv.FormatBool(err != nil)}
+	mw.reqMetrics.With(labels...).Observe(time.Since(begin).Seconds())
+	return resp, err
+}
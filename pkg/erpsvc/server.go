@@ -2,6 +2,10 @@ package erpsvc
 
 import (
 	"context"
+	"github.com/anabiozz/core/lapkins/pkg/payment"
+	"github.com/anabiozz/core/lapkins/pkg/search"
+	"github.com/anabiozz/core/lapkins/pkg/storage/mongo"
+	"github.com/anabiozz/core/lapkins/pkg/worker"
 	"github.com/gorilla/handlers"
 	"net/http"
 	"net/http/pprof"
@@ -23,24 +27,58 @@ type ServerConfig struct {
 	ShutdownTimeout time.Duration
 	MetricPrefix    string
 	AllowedOrigins  []string
+	Payment         payment.Config
+	// SearchRefreshInterval is how often the search index is rebuilt from the catalog.
+	SearchRefreshInterval time.Duration
 }
 
 // Server is a service server.
 type Server struct {
-	cfg *ServerConfig
-	srv *http.Server
+	cfg     *ServerConfig
+	srv     *http.Server
+	workers []*worker.Worker
 }
 
 // NewServer creates a new server.
 func NewServer(cfg ServerConfig) (*Server, error) {
-	var svc Service
-	svc, err := newService(&ServiceConfig{
+	storage, err := mongo.New(mongo.Config{
 		Logger: cfg.Logger,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	payments, err := payment.NewProvider(cfg.Payment)
+	if err != nil {
+		return nil, err
+	}
+
+	index := search.New(storage)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := index.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	refreshInterval := cfg.SearchRefreshInterval
+	if refreshInterval == 0 {
+		refreshInterval = 5 * time.Minute
+	}
+	workers := []*worker.Worker{
+		worker.New("search-indexer", refreshInterval, cfg.Logger, index.Refresh),
+	}
+
+	var svc Service
+	svc, err = newService(&ServiceConfig{
+		Logger:   cfg.Logger,
+		Storage:  storage,
+		Payments: payments,
+		Search:   index,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	svc = NewLoggingMiddleware(svc, cfg.Logger)
 	svc = NewInstrumentingMiddleware(svc, cfg.MetricPrefix+"_api")
 
@@ -58,15 +96,19 @@ func NewServer(cfg ServerConfig) (*Server, error) {
 	}
 
 	s := &Server{
-		cfg: &cfg,
-		srv: srv,
+		cfg:     &cfg,
+		srv:     srv,
+		workers: workers,
 	}
 
 	return s, nil
 }
 
-// Serve starts the HTTP server.
+// Serve starts the background workers and the HTTP server.
 func (s *Server) Serve() error {
+	for _, w := range s.workers {
+		go w.Run()
+	}
 	if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
 		return err
 	}
@@ -74,13 +116,16 @@ func (s *Server) Serve() error {
 	return nil
 }
 
-// Shutdown stops the HTTP server.
+// Shutdown stops the HTTP server and the background workers.
 func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
 	defer cancel()
 	if err := s.srv.Shutdown(ctx); err != nil {
 		return err
 	}
+	for _, w := range s.workers {
+		w.Stop()
+	}
 	return nil
 }
 
@@ -91,24 +136,59 @@ func makeHandler(svc Service) http.Handler {
 
 	router := mux.NewRouter()
 
-	router.Path("/api/v1/products").Methods(http.MethodGet).Handler(kithttp.NewServer(
-		makeGetProductsEndpoint(svc),
+	router.Path("/api/v1/product").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetProductEndpoint(svc),
 		decodeGetProductRequest,
 		encodeGetProductResponse,
 		opts...,
 	))
 
-	router.Path("/api/v1/product").Methods(http.MethodPost).Handler(kithttp.NewServer(
+	router.Path("/api/v1/products").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetProductsEndpoint(svc),
+		decodeGetProductsRequest,
+		encodeGetProductsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeCreateProductEndpoint(svc),
+		decodeCreateProductRequest,
+		encodeCreateProductResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}").Methods(http.MethodPatch).Handler(kithttp.NewServer(
 		makeUpdateProductEndpoint(svc),
 		decodeUpdateProductRequest,
 		encodeUpdateProductResponse,
 		opts...,
 	))
 
-	router.Path("/api/v1/products").Methods(http.MethodGet).Handler(kithttp.NewServer(
-		makeGetProductsEndpoint(svc),
-		decodeGetProductsRequest,
-		encodeGetProductsResponse,
+	router.Path("/api/v1/products/{id}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeleteProductEndpoint(svc),
+		decodeDeleteProductRequest,
+		encodeDeleteProductResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}/variations").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeCreateVariationEndpoint(svc),
+		decodeCreateVariationRequest,
+		encodeCreateVariationResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}/variations/{sku}").Methods(http.MethodPatch).Handler(kithttp.NewServer(
+		makeUpdateVariationEndpoint(svc),
+		decodeUpdateVariationRequest,
+		encodeUpdateVariationResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}/variations/{sku}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeleteVariationEndpoint(svc),
+		decodeDeleteVariationRequest,
+		encodeDeleteVariationResponse,
 		opts...,
 	))
 
@@ -119,6 +199,69 @@ func makeHandler(svc Service) http.Handler {
 		opts...,
 	))
 
+	router.Path("/api/v1/categories/order").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeReorderCategoriesEndpoint(svc),
+		decodeReorderCategoriesRequest,
+		encodeReorderCategoriesResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/categories").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeCreateCategoryEndpoint(svc),
+		decodeCreateCategoryRequest,
+		encodeCreateCategoryResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/categories/{id}").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetCategoryEndpoint(svc),
+		decodeGetCategoryRequest,
+		encodeGetCategoryResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/categories/{id}").Methods(http.MethodPatch).Handler(kithttp.NewServer(
+		makeRenameCategoryEndpoint(svc),
+		decodeRenameCategoryRequest,
+		encodeRenameCategoryResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/categories/{id}/products").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetCategoryProductsEndpoint(svc),
+		decodeGetCategoryProductsRequest,
+		encodeGetCategoryProductsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/categories/{id}/parent").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeMoveCategoryEndpoint(svc),
+		decodeMoveCategoryRequest,
+		encodeMoveCategoryResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/categories/{id}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeleteCategoryEndpoint(svc),
+		decodeDeleteCategoryRequest,
+		encodeDeleteCategoryResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/attributes").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetAttributesEndpoint(svc),
+		decodeGetAttributesRequest,
+		encodeGetAttributesResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/attributes").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeCreateAttributeEndpoint(svc),
+		decodeCreateAttributeRequest,
+		encodeCreateAttributeResponse,
+		opts...,
+	))
+
 	router.Path("/api/v1/attribute").Methods(http.MethodPost).Handler(kithttp.NewServer(
 		makeAddAttributeEndpoint(svc),
 		decodeAddAttributeRequest,
@@ -147,5 +290,159 @@ func makeHandler(svc Service) http.Handler {
 		opts...,
 	))
 
+	router.Path("/api/v1/order/status").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeChangeOrderStatusEndpoint(svc),
+		decodeChangeOrderStatusRequest,
+		encodeChangeOrderStatusResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/admin/orders").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetOrdersEndpoint(svc),
+		decodeGetOrdersRequest,
+		encodeGetOrdersResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/search").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeSearchEndpoint(svc),
+		decodeSearchRequest,
+		encodeSearchResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/catalog").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeFilterProductsEndpoint(svc),
+		decodeFilterProductsRequest,
+		encodeFilterProductsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/price-lists").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetPriceListsEndpoint(svc),
+		decodeGetPriceListsRequest,
+		encodeGetPriceListsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/price-lists").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeCreatePriceListEndpoint(svc),
+		decodeCreatePriceListRequest,
+		encodeCreatePriceListResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/price-lists/{id}").Methods(http.MethodPatch).Handler(kithttp.NewServer(
+		makeUpdatePriceListEndpoint(svc),
+		decodeUpdatePriceListRequest,
+		encodeUpdatePriceListResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/price-lists/{id}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeletePriceListEndpoint(svc),
+		decodeDeletePriceListRequest,
+		encodeDeletePriceListResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/price-lists/{id}/prices").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetPriceListPricesEndpoint(svc),
+		decodeGetPriceListPricesRequest,
+		encodeGetPriceListPricesResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/price-lists/{id}/prices").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeSetPriceListPricesEndpoint(svc),
+		decodeSetPriceListPricesRequest,
+		encodeSetPriceListPricesResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/price-lists/{id}/prices/{sku}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeRemovePriceListPriceEndpoint(svc),
+		decodeRemovePriceListPriceRequest,
+		encodeRemovePriceListPriceResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}/configurator").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetConfiguratorEndpoint(svc),
+		decodeGetConfiguratorRequest,
+		encodeGetConfiguratorResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}/configurator").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeSetConfiguratorEndpoint(svc),
+		decodeSetConfiguratorRequest,
+		encodeSetConfiguratorResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}/configurator").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeleteConfiguratorEndpoint(svc),
+		decodeDeleteConfiguratorRequest,
+		encodeDeleteConfiguratorResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/products/{id}/configure").Methods(http.MethodPost).Handler(kithttp.NewServer(
+		makeConfigureProductEndpoint(svc),
+		decodeConfigureProductRequest,
+		encodeConfigureProductResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/delivery-zones").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetDeliveryZonesEndpoint(svc),
+		decodeGetDeliveryZonesRequest,
+		encodeGetDeliveryZonesResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/delivery-zones/{id}").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeSetDeliveryZoneEndpoint(svc),
+		decodeSetDeliveryZoneRequest,
+		encodeSetDeliveryZoneResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/delivery-zones/{id}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeleteDeliveryZoneEndpoint(svc),
+		decodeDeleteDeliveryZoneRequest,
+		encodeDeleteDeliveryZoneResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/pickup-points").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeGetPickupPointsEndpoint(svc),
+		decodeGetPickupPointsRequest,
+		encodeGetPickupPointsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/pickup-points/nearest").Methods(http.MethodGet).Handler(kithttp.NewServer(
+		makeNearestPickupPointsEndpoint(svc),
+		decodeNearestPickupPointsRequest,
+		encodeNearestPickupPointsResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/pickup-points/{id}").Methods(http.MethodPut).Handler(kithttp.NewServer(
+		makeSetPickupPointEndpoint(svc),
+		decodeSetPickupPointRequest,
+		encodeSetPickupPointResponse,
+		opts...,
+	))
+
+	router.Path("/api/v1/pickup-points/{id}").Methods(http.MethodDelete).Handler(kithttp.NewServer(
+		makeDeletePickupPointEndpoint(svc),
+		decodeDeletePickupPointRequest,
+		encodeDeletePickupPointResponse,
+		opts...,
+	))
+
 	return router
 }
@@ -2,40 +2,141 @@ package erpsvc
 
 import (
 	"context"
+	"fmt"
 	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"github.com/anabiozz/core/lapkins/pkg/pricing"
+	"github.com/anabiozz/core/lapkins/pkg/search"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Storage interface {
 	GetProduct(ctx context.Context, sku string) (*erp.Product, error)
-	GetProducts(ctx context.Context) ([]*erp.Product, error)
-	GetCategories(ctx context.Context) ([]*erp.Category, error)
-	AddAttribute(ctx context.Context, sku string, attribute *erp.NameValue) error
-	RemoveAttribute(ctx context.Context, sku string, attribute string) error
-	AddCategory(ctx context.Context, sku string, category *erp.Category) error
-	RemoveCategory(ctx context.Context, sku string, category *erp.Category) error
-	UpdateProduct(ctx context.Context, product *erp.Product) error
+	GetProducts(ctx context.Context, page *erp.PageRequest) (*erp.ProductPage, error)
+	GetCategories(ctx context.Context, page *erp.PageRequest) (*erp.CategoryPage, error)
+	GetCategory(ctx context.Context, id string) (*erp.Category, error)
+	CreateCategory(ctx context.Context, category *erp.Category) error
+	RenameCategory(ctx context.Context, id string, name []*erp.LangValue, description []*erp.LangValue) error
+	MoveCategory(ctx context.Context, id string, parentID string) error
+	ReorderCategories(ctx context.Context, parentID string, ids []string) error
+	DeleteCategory(ctx context.Context, id string) error
+	GetAttributes(ctx context.Context) ([]*erp.Attribute, error)
+	CreateAttribute(ctx context.Context, attribute *erp.Attribute) error
+	AddAttribute(ctx context.Context, edit *erp.AttributeEdit) error
+	RemoveAttribute(ctx context.Context, edit *erp.AttributeEdit) error
+	AddProductCategory(ctx context.Context, productID string, categoryID string, primary bool) error
+	RemoveProductCategory(ctx context.Context, productID string, categoryID string) error
+	GetCategoryProducts(ctx context.Context, categoryID string, page *erp.PageRequest) (*erp.CatalogPage, error)
+	CreateProduct(ctx context.Context, product *erp.ProductInput) error
+	UpdateProduct(ctx context.Context, id string, patch *erp.ProductPatch) error
+	DeleteProduct(ctx context.Context, id string) error
+	FindSKUs(ctx context.Context, skus []string) ([]string, error)
+	GetProductVariations(ctx context.Context, productID string) ([]*erp.VariationInput, error)
+	CreateVariation(ctx context.Context, productID string, variation *erp.VariationInput) error
+	UpdateVariation(ctx context.Context, productID string, variation *erp.VariationInput, patch *erp.VariationPatch) error
+	DeleteVariation(ctx context.Context, productID string, sku string) error
+	GetOrder(ctx context.Context, id string) (*erp.Order, error)
+	GetOrders(ctx context.Context, status erp.OrderStatus, page *erp.PageRequest) (*erp.OrderPage, error)
+	UpdateOrderStatus(ctx context.Context, id string, change *erp.OrderStatusChange) error
+	UpdateOrderPayment(ctx context.Context, id string, payment *erp.Payment) error
+	CommitReservation(ctx context.Context, id string) error
+	ReleaseReservation(ctx context.Context, id string) error
+	GetSearchDocuments(ctx context.Context) ([]*erp.SearchDocument, error)
+	GetFacets(ctx context.Context) ([]*erp.Facet, error)
+	FilterProducts(ctx context.Context, filter *erp.FacetFilter) (*erp.FacetedProducts, error)
+	GetUser(ctx context.Context, id string) (*erp.User, error)
+	GetPrices(ctx context.Context, skus []string) (map[string]*erp.Pricing, error)
+	GetProductPrices(ctx context.Context, productIDs []string) (map[string]map[string]*erp.Pricing, error)
+	GetVariationCategories(ctx context.Context, skus []string) (map[string][]string, error)
+	GetPriceLists(ctx context.Context) ([]*erp.PriceList, error)
+	GetPriceList(ctx context.Context, id string) (*erp.PriceList, error)
+	CreatePriceList(ctx context.Context, list *erp.PriceList) error
+	UpdatePriceList(ctx context.Context, list *erp.PriceList) error
+	DeletePriceList(ctx context.Context, id string) error
+	GetPriceListPrices(ctx context.Context, id string, skus []string) (map[string]*erp.Pricing, error)
+	SetPriceListPrices(ctx context.Context, id string, prices map[string]*erp.Pricing) error
+	RemovePriceListPrice(ctx context.Context, id string, sku string) error
+	GetConfigurator(ctx context.Context, productID string) (*erp.Configurator, error)
+	SetConfigurator(ctx context.Context, configurator *erp.Configurator) error
+	DeleteConfigurator(ctx context.Context, productID string) error
+	GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error)
+	SetDeliveryZone(ctx context.Context, zone *erp.DeliveryZone) error
+	DeleteDeliveryZone(ctx context.Context, id string) error
+	GetPickupPoints(ctx context.Context) ([]*erp.PickupPoint, error)
+	SetPickupPoint(ctx context.Context, point *erp.PickupPoint) error
+	DeletePickupPoint(ctx context.Context, id string) error
 }
 
 type Service interface {
-	GetCategories(ctx context.Context) ([]*erp.Category, error)
-	GetProducts(ctx context.Context) ([]*erp.Product, error)
-	GetProduct(ctx context.Context, sku string) (*erp.Product, error)
-	AddAttribute(ctx context.Context, sku string, attribute *erp.NameValue) error
-	RemoveAttribute(ctx context.Context, sku string, attribute string) error
-	AddCategory(ctx context.Context, sku string, category *erp.Category) error
-	RemoveCategory(ctx context.Context, sku string, category *erp.Category) error
-	UpdateProduct(ctx context.Context, product *erp.Product) error
+	GetCategories(ctx context.Context, page *erp.PageRequest) (*erp.CategoryPage, error)
+	GetCategory(ctx context.Context, id string) (*erp.Category, error)
+	CreateCategory(ctx context.Context, input *erp.CategoryInput) (*erp.Category, error)
+	RenameCategory(ctx context.Context, id string, name []*erp.LangValue, description []*erp.LangValue) error
+	MoveCategory(ctx context.Context, id string, parentID string) (*erp.Category, error)
+	ReorderCategories(ctx context.Context, parentID string, ids []string) error
+	DeleteCategory(ctx context.Context, id string) error
+	GetProducts(ctx context.Context, page *erp.PageRequest) (*erp.ProductPage, error)
+	GetProduct(ctx context.Context, sku string, userID string, market erp.Market) (*erp.Product, error)
+	GetAttributes(ctx context.Context) ([]*erp.Attribute, error)
+	CreateAttribute(ctx context.Context, name string, value string) (*erp.Attribute, error)
+	AddAttribute(ctx context.Context, edit *erp.AttributeEdit) error
+	RemoveAttribute(ctx context.Context, edit *erp.AttributeEdit) error
+	AddCategory(ctx context.Context, productID string, categoryID string, primary bool) error
+	RemoveCategory(ctx context.Context, productID string, categoryID string) error
+	GetCategoryProducts(ctx context.Context, categoryID string, page *erp.PageRequest, userID string, market erp.Market) (*erp.CatalogPage, error)
+	CreateProduct(ctx context.Context, input *erp.ProductInput) (*erp.ProductInput, error)
+	UpdateProduct(ctx context.Context, id string, patch *erp.ProductPatch) error
+	DeleteProduct(ctx context.Context, id string) error
+	CreateVariation(ctx context.Context, productID string, input *erp.VariationInput) (*erp.VariationInput, error)
+	UpdateVariation(ctx context.Context, productID string, sku string, patch *erp.VariationPatch) (*erp.VariationInput, error)
+	DeleteVariation(ctx context.Context, productID string, sku string) error
+	ChangeOrderStatus(ctx context.Context, orderID string, status erp.OrderStatus, by string, comment string) (*erp.Order, error)
+	GetOrders(ctx context.Context, status erp.OrderStatus, page *erp.PageRequest) (*erp.OrderPage, error)
+	Search(ctx context.Context, query string, page int, limit int, userID string, market erp.Market) (*erp.SearchResult, error)
+	FilterProducts(ctx context.Context, filter *erp.FacetFilter, page int, limit int, userID string, market erp.Market) (*erp.FacetedProducts, error)
+	GetPriceLists(ctx context.Context) ([]*erp.PriceList, error)
+	CreatePriceList(ctx context.Context, input *erp.PriceListInput) (*erp.PriceList, error)
+	UpdatePriceList(ctx context.Context, id string, patch *erp.PriceListPatch) (*erp.PriceList, error)
+	DeletePriceList(ctx context.Context, id string) error
+	GetPriceListPrices(ctx context.Context, id string) ([]*erp.PriceListPrice, error)
+	SetPriceListPrices(ctx context.Context, id string, prices []*erp.PriceListPrice) error
+	RemovePriceListPrice(ctx context.Context, id string, sku string) error
+	GetConfigurator(ctx context.Context, productID string) (*erp.Configurator, error)
+	SetConfigurator(ctx context.Context, productID string, input *erp.ConfiguratorInput) (*erp.Configurator, error)
+	DeleteConfigurator(ctx context.Context, productID string) error
+	ConfigureProduct(ctx context.Context, productID string, selected map[string]string, userID string, market erp.Market) (*erp.Configuration, error)
+	GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error)
+	SetDeliveryZone(ctx context.Context, id string, input *erp.DeliveryZoneInput) (*erp.DeliveryZone, error)
+	DeleteDeliveryZone(ctx context.Context, id string) error
+	GetPickupPoints(ctx context.Context) ([]*erp.PickupPoint, error)
+	NearestPickupPoints(ctx context.Context, location erp.Location, limit int, radiusKm float64) ([]*erp.NearbyPickupPoint, error)
+	SetPickupPoint(ctx context.Context, id string, input *erp.PickupPointInput) (*erp.PickupPoint, error)
+	DeletePickupPoint(ctx context.Context, id string) error
 }
 
+// Catalog page size limits.
+const (
+	defaultPageLimit = 24
+	maxPageLimit     = 100
+)
+
 type service struct {
-	logger  log.Logger
-	storage Storage
+	logger   log.Logger
+	storage  Storage
+	payments erp.PaymentProvider
+	pricing  *pricing.Engine
+	search   *search.Index
 }
 
 type ServiceConfig struct {
-	Logger  log.Logger
-	Storage Storage
+	Logger   log.Logger
+	Storage  Storage
+	Payments erp.PaymentProvider
+	Search   *search.Index
 }
 
 func newService(cfg *ServiceConfig) (*service, error) {
@@ -45,73 +146,997 @@ func newService(cfg *ServiceConfig) (*service, error) {
 	}
 
 	svc := &service{
-		logger:  logger,
-		storage: cfg.Storage,
+		logger:   logger,
+		storage:  cfg.Storage,
+		payments: cfg.Payments,
+		pricing:  pricing.NewEngine(cfg.Storage),
+		search:   cfg.Search,
 	}
 
 	return svc, nil
 }
 
-func (s *service) GetCategories(ctx context.Context) ([]*erp.Category, error) {
-	categories, err := s.storage.GetCategories(ctx)
+func (s *service) GetCategories(ctx context.Context, page *erp.PageRequest) (*erp.CategoryPage, error) {
+	categories, err := s.storage.GetCategories(ctx, page)
 	if err != nil {
+		if err == erp.ErrInvalidCursor {
+			return nil, erp.ErrBadRequest("%s", err)
+		}
 		return nil, err
 	}
 	return categories, nil
 }
 
-func (s *service) GetProduct(ctx context.Context, sku string) (*erp.Product, error) {
+// GetCategory returns the category with its subcategory tree.
+func (s *service) GetCategory(ctx context.Context, id string) (*erp.Category, error) {
+	category, err := s.storage.GetCategory(ctx, id)
+	if err != nil {
+		return nil, categoryError(err, id)
+	}
+	return category, nil
+}
+
+// CreateCategory adds a category as the last subcategory of its parent.
+func (s *service) CreateCategory(ctx context.Context, input *erp.CategoryInput) (*erp.Category, error) {
+	if err := input.Validate(); err != nil {
+		return nil, erp.ErrBadRequest("validation error: %v", err)
+	}
+	now := time.Now()
+	category := &erp.Category{
+		ID:          input.ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Facets:      input.Facets,
+		CreatedOn:   now,
+		ModifiedOn:  now,
+	}
+	if input.Parent != "" {
+		category.Parents = []string{input.Parent}
+	}
+	if err := s.storage.CreateCategory(ctx, category); err != nil {
+		return nil, categoryError(err, input.ID)
+	}
+	return category, nil
+}
+
+// RenameCategory changes the name, and the description if given, of the category.
+func (s *service) RenameCategory(ctx context.Context, id string, name []*erp.LangValue, description []*erp.LangValue) error {
+	if err := erp.ValidateCategoryName(name); err != nil {
+		return erp.ErrBadRequest("validation error: %v", err)
+	}
+	if err := s.storage.RenameCategory(ctx, id, name, description); err != nil {
+		return categoryError(err, id)
+	}
+	return nil
+}
+
+// MoveCategory moves the category with its subtree under another parent, or to the root.
+func (s *service) MoveCategory(ctx context.Context, id string, parentID string) (*erp.Category, error) {
+	if id == parentID {
+		return nil, erp.ErrConflict("%s", erp.ErrCategoryCycle)
+	}
+	if err := s.storage.MoveCategory(ctx, id, parentID); err != nil {
+		return nil, categoryError(err, id)
+	}
+	return s.GetCategory(ctx, id)
+}
+
+// ReorderCategories sets the order of the subcategories of the parent, or of the root categories.
+func (s *service) ReorderCategories(ctx context.Context, parentID string, ids []string) error {
+	if err := s.storage.ReorderCategories(ctx, parentID, ids); err != nil {
+		return categoryError(err, parentID)
+	}
+	return nil
+}
+
+// DeleteCategory deletes a category without subcategories and products.
+func (s *service) DeleteCategory(ctx context.Context, id string) error {
+	if err := s.storage.DeleteCategory(ctx, id); err != nil {
+		return categoryError(err, id)
+	}
+	return nil
+}
+
+// categoryError turns category storage errors into service errors.
+func categoryError(err error, id string) error {
+	switch err {
+	case erp.ErrNotFoundInStorage:
+		return erp.ErrNotFound("category %s not found", id)
+	case erp.ErrDuplicateKeyInStorage:
+		return erp.ErrConflict("category %s already exists", id)
+	case erp.ErrCategoryParentNotFound:
+		return erp.ErrNotFound("%s", err)
+	case erp.ErrCategoryCycle, erp.ErrCategoryHasSubcategories, erp.ErrCategoryHasProducts:
+		return erp.ErrConflict("%s", err)
+	case erp.ErrCategoryOrderMismatch:
+		return erp.ErrBadRequest("%s", err)
+	}
+	return err
+}
+
+// GetProduct returns the product of the SKU with the prices of the market price list.
+func (s *service) GetProduct(ctx context.Context, sku string, userID string, market erp.Market) (*erp.Product, error) {
 	product, err := s.storage.GetProduct(ctx, sku)
 	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return nil, erp.ErrNotFound("product %s not found", sku)
+		}
 		return nil, err
 	}
+	list, err := s.priceList(ctx, userID, market)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return product, nil
+	}
+
+	skus := make([]string, 0, len(product.Variations))
+	for _, v := range product.Variations {
+		skus = append(skus, strconv.Itoa(v.SKU))
+	}
+	prices, err := s.pricing.Prices(ctx, skus, list)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, v := range product.Variations {
+		v.Price = erp.Money{Currency: list.Currency}
+		if pricing, ok := prices[strconv.Itoa(v.SKU)]; ok {
+			v.Price = pricing.Current(now)
+		}
+	}
 	return product, nil
 }
 
-func (s *service) GetProducts(ctx context.Context) ([]*erp.Product, error) {
-	products, err := s.storage.GetProducts(ctx)
+func (s *service) GetProducts(ctx context.Context, page *erp.PageRequest) (*erp.ProductPage, error) {
+	products, err := s.storage.GetProducts(ctx, page)
 	if err != nil {
+		if err == erp.ErrInvalidCursor {
+			return nil, erp.ErrBadRequest("%s", err)
+		}
 		return nil, err
 	}
 	return products, nil
 }
 
-func (s *service) UpdateProduct(ctx context.Context, product *erp.Product) error {
-	err := s.storage.UpdateProduct(ctx, product)
+// CreateProduct adds a product with its variations.
+func (s *service) CreateProduct(ctx context.Context, input *erp.ProductInput) (*erp.ProductInput, error) {
+	dict, err := s.attributeDictionary(ctx)
 	if err != nil {
+		return nil, err
+	}
+	if err := input.Validate(dict); err != nil {
+		return nil, err
+	}
+
+	skus := make([]string, 0, len(input.Variations))
+	for _, v := range input.Variations {
+		skus = append(skus, v.SKU)
+	}
+	used, err := s.storage.FindSKUs(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	if len(used) > 0 {
+		var v erp.Validation
+		for i, sku := range skus {
+			for _, u := range used {
+				if sku == u {
+					v.Add(fmt.Sprintf("variations[%d].sku", i), "%s is already used by another variation", sku)
+				}
+			}
+		}
+		return nil, v.Err()
+	}
+
+	err = s.storage.CreateProduct(ctx, input)
+	if err != nil {
+		switch err {
+		case erp.ErrDuplicateKeyInStorage:
+			return nil, erp.ErrConflict("product %s or one of its variations already exists", input.ID)
+		case erp.ErrCategoryNotFound:
+			var v erp.Validation
+			v.Add("categories", "%s", err)
+			return nil, v.Err()
+		}
+		return nil, err
+	}
+	return input, nil
+}
+
+// UpdateProduct changes the product fields given in the patch.
+func (s *service) UpdateProduct(ctx context.Context, id string, patch *erp.ProductPatch) error {
+	if err := patch.Validate(); err != nil {
 		return err
 	}
+	err := s.storage.UpdateProduct(ctx, id, patch)
+	if err != nil {
+		return variationError(err, id, "")
+	}
 	return nil
 }
 
-func (s *service) AddAttribute(ctx context.Context, sku string, attribute *erp.NameValue) error {
-	err := s.storage.AddAttribute(ctx, sku, attribute)
+// DeleteProduct deletes the product with its variations.
+func (s *service) DeleteProduct(ctx context.Context, id string) error {
+	err := s.storage.DeleteProduct(ctx, id)
 	if err != nil {
-		return err
+		return variationError(err, id, "")
 	}
 	return nil
 }
 
-func (s *service) RemoveAttribute(ctx context.Context, sku string, attribute string) error {
-	err := s.storage.RemoveAttribute(ctx, sku, attribute)
+// CreateVariation adds a variation to the product, a default one takes over from the current default variation.
+func (s *service) CreateVariation(ctx context.Context, productID string, input *erp.VariationInput) (*erp.VariationInput, error) {
+	dict, err := s.attributeDictionary(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	variations, err := s.storage.GetProductVariations(ctx, productID)
+	if err != nil {
+		return nil, variationError(err, productID, input.SKU)
+	}
+	if err := erp.ValidateVariation(input, variations, dict); err != nil {
+		return nil, err
+	}
+	used, err := s.storage.FindSKUs(ctx, []string{input.SKU})
+	if err != nil {
+		return nil, err
+	}
+	if len(used) > 0 {
+		var v erp.Validation
+		v.Add("sku", "%s is already used by another variation", input.SKU)
+		return nil, v.Err()
+	}
+
+	err = s.storage.CreateVariation(ctx, productID, input)
+	if err != nil {
+		return nil, variationError(err, productID, input.SKU)
+	}
+	return input, nil
+}
+
+// UpdateVariation changes the variation fields given in the patch
+// and returns the patched variation.
+func (s *service) UpdateVariation(ctx context.Context, productID string, sku string, patch *erp.VariationPatch) (*erp.VariationInput, error) {
+	dict, err := s.attributeDictionary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	variations, err := s.storage.GetProductVariations(ctx, productID)
+	if err != nil {
+		return nil, variationError(err, productID, sku)
+	}
+	var variation *erp.VariationInput
+	others := make([]*erp.VariationInput, 0, len(variations))
+	for _, v := range variations {
+		if v.SKU == sku {
+			variation = v
+		} else {
+			others = append(others, v)
+		}
+	}
+	if variation == nil {
+		return nil, erp.ErrNotFound("variation %s of product %s not found", sku, productID)
+	}
+
+	patch.Apply(variation)
+	if err := erp.ValidateVariation(variation, others, dict); err != nil {
+		return nil, err
+	}
+	err = s.storage.UpdateVariation(ctx, productID, variation, patch)
+	if err != nil {
+		return nil, variationError(err, productID, sku)
+	}
+	return variation, nil
+}
+
+// DeleteVariation deletes a variation of the product.
+func (s *service) DeleteVariation(ctx context.Context, productID string, sku string) error {
+	err := s.storage.DeleteVariation(ctx, productID, sku)
+	if err != nil {
+		return variationError(err, productID, sku)
+	}
+	return nil
+}
+
+func (s *service) attributeDictionary(ctx context.Context) (erp.AttributeDictionary, error) {
+	attributes, err := s.storage.GetAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return erp.NewAttributeDictionary(attributes), nil
+}
+
+// variationError turns product and variation storage errors into service errors.
+func variationError(err error, productID string, sku string) error {
+	switch err {
+	case erp.ErrProductNotFound:
+		return erp.ErrNotFound("product %s not found", productID)
+	case erp.ErrVariationNotFound:
+		return erp.ErrNotFound("variation %s of product %s not found", sku, productID)
+	case erp.ErrCategoryNotFound:
+		return erp.ErrConflict("the primary category of product %s doesn't exist", productID)
+	case erp.ErrDuplicateKeyInStorage:
+		return erp.ErrConflict("variation %s already exists", sku)
+	case erp.ErrVariationIsDefault, erp.ErrLastVariation:
+		return erp.ErrConflict("%s", err)
+	case erp.ErrNoDefaultVariation:
+		var v erp.Validation
+		v.Add("default", "%s", err)
+		return v.Err()
+	}
+	return err
+}
+
+// GetAttributes returns the attribute dictionary.
+func (s *service) GetAttributes(ctx context.Context) ([]*erp.Attribute, error) {
+	return s.storage.GetAttributes(ctx)
+}
+
+// CreateAttribute adds a value to the attribute dictionary,
+// adding the first value of an attribute name allows products and variations to have it.
+func (s *service) CreateAttribute(ctx context.Context, name string, value string) (*erp.Attribute, error) {
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if name == "" || value == "" {
+		return nil, erp.ErrBadRequest("%s", "attribute name and value are required")
+	}
+	attribute := &erp.Attribute{
+		ID:    erp.AttributeID(name, value),
+		Name:  name,
+		Value: value,
+	}
+	err := s.storage.CreateAttribute(ctx, attribute)
+	if err != nil {
+		if err == erp.ErrDuplicateKeyInStorage {
+			return nil, erp.ErrConflict("attribute %s already exists", attribute.ID)
+		}
+		return nil, err
+	}
+	return attribute, nil
+}
+
+// AddAttribute adds attribute values to a product or sets an attribute value of a variation.
+func (s *service) AddAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
+	if err := edit.Validate(false); err != nil {
+		return erp.ErrBadRequest("%s", err)
+	}
+	err := s.storage.AddAttribute(ctx, edit)
+	if err != nil {
+		return attributeError(err, edit)
+	}
+	return nil
+}
+
+// RemoveAttribute removes attribute values of a product or an attribute of a variation.
+func (s *service) RemoveAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
+	if err := edit.Validate(true); err != nil {
+		return erp.ErrBadRequest("%s", err)
+	}
+	err := s.storage.RemoveAttribute(ctx, edit)
+	if err != nil {
+		return attributeError(err, edit)
+	}
+	return nil
+}
+
+// attributeError turns attribute storage errors into service errors.
+func attributeError(err error, edit *erp.AttributeEdit) error {
+	switch err {
+	case erp.ErrAttributeUnknown:
+		return erp.ErrBadRequest("attribute %q is not in the attribute dictionary", edit.Name)
+	case erp.ErrProductNotFound:
+		return erp.ErrNotFound("product %s not found", edit.ProductID)
+	case erp.ErrVariationNotFound:
+		return erp.ErrNotFound("variation %s not found", edit.SKU)
+	}
+	return err
+}
+
+// AddCategory lists the product in the category, optionally as its primary category.
+func (s *service) AddCategory(ctx context.Context, productID string, categoryID string, primary bool) error {
+	if productID == "" || categoryID == "" {
+		return erp.ErrBadRequest("%s", "product_id and category_id are required")
+	}
+	err := s.storage.AddProductCategory(ctx, productID, categoryID, primary)
+	if err != nil {
+		return membershipError(err, productID, categoryID)
 	}
 	return nil
 }
 
-func (s *service) AddCategory(ctx context.Context, sku string, category *erp.Category) error {
-	err := s.storage.AddCategory(ctx, sku, category)
+// RemoveCategory unlists the product from the category.
+func (s *service) RemoveCategory(ctx context.Context, productID string, categoryID string) error {
+	if productID == "" || categoryID == "" {
+		return erp.ErrBadRequest("%s", "product_id and category_id are required")
+	}
+	err := s.storage.RemoveProductCategory(ctx, productID, categoryID)
+	if err != nil {
+		return membershipError(err, productID, categoryID)
+	}
+	return nil
+}
+
+// GetCategoryProducts lists the products of the category and of its subcategories
+// with the prices of the market price list.
+func (s *service) GetCategoryProducts(ctx context.Context, categoryID string, page *erp.PageRequest, userID string, market erp.Market) (*erp.CatalogPage, error) {
+	products, err := s.storage.GetCategoryProducts(ctx, categoryID, page)
+	if err != nil {
+		switch err {
+		case erp.ErrInvalidCursor:
+			return nil, erp.ErrBadRequest("%s", err)
+		case erp.ErrCategoryNotFound:
+			return nil, erp.ErrNotFound("category %s not found", categoryID)
+		}
+		return nil, err
+	}
+	err = s.priceCatalog(ctx, userID, market, products.Products)
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// membershipError turns product category storage errors into service errors.
+func membershipError(err error, productID string, categoryID string) error {
+	switch err {
+	case erp.ErrProductNotFound:
+		return erp.ErrNotFound("product %s not found", productID)
+	case erp.ErrCategoryNotFound:
+		return erp.ErrNotFound("category %s not found", categoryID)
+	}
+	return err
+}
+
+// ChangeOrderStatus moves the order to the given status and records who did it.
+func (s *service) ChangeOrderStatus(ctx context.Context, orderID string, status erp.OrderStatus, by string, comment string) (*erp.Order, error) {
+	if orderID == "" {
+		return nil, erp.ErrBadRequest("%s", "provided order id is empty")
+	}
+	order, err := s.storage.GetOrder(ctx, orderID)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return nil, erp.ErrNotFound("order %s not found", orderID)
+		}
+		return nil, err
+	}
+
+	change, err := order.ChangeStatus(status, by, comment, time.Now())
+	if err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+
+	// Free orders were never charged, there is nothing to give back.
+	refund := change.To == erp.OrderStatusRefunded && order.Payment != nil &&
+		order.Payment.Status == erp.PaymentStatusCaptured && order.Payment.TransactionID != ""
+	if refund && order.Payment.Provider != s.payments.Name() {
+		return nil, erp.ErrBadRequest("payment provider %q is not available", order.Payment.Provider)
+	}
+
+	// The status is changed first, so a concurrent change can't leave a refunded payment
+	// on an order that is still paid or shipped.
+	err = s.storage.UpdateOrderStatus(ctx, orderID, change)
+	if err != nil {
+		if err == erp.ErrModifiedInStorage {
+			return nil, erp.ErrConflict("order %s was changed concurrently, try again", orderID)
+		}
+		return nil, err
+	}
+
+	order.Status = change.To
+	order.ModifiedOn = change.At
+	order.History = append(order.History, change)
+
+	if refund {
+		err = s.refundPayment(ctx, order, change)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.settleReservation(ctx, order)
+	return order, nil
+}
+
+// settleReservation takes the reserved stock of a paid order and gives back the stock of a cancelled one.
+func (s *service) settleReservation(ctx context.Context, order *erp.Order) {
+	var err error
+	switch order.Status {
+	case erp.OrderStatusPaid:
+		err = s.storage.CommitReservation(ctx, order.ID)
+	case erp.OrderStatusCancelled:
+		err = s.storage.ReleaseReservation(ctx, order.ID)
+	}
+	if err != nil && err != erp.ErrNotFoundInStorage {
+		level.Error(s.logger).Log("msg", "failed to settle reservation", "order", order.ID, "err", err)
+	}
+}
+
+// refundPayment gives the captured money back after the order is marked as refunded.
+// If the provider fails the order is moved back to the status it was refunded from,
+// so the refund can be retried.
+func (s *service) refundPayment(ctx context.Context, order *erp.Order, change *erp.OrderStatusChange) error {
+	result, err := s.payments.Refund(ctx, order.Payment.TransactionID, order.Payment.Amount)
+	if err != nil {
+		revert := &erp.OrderStatusChange{
+			From:    change.To,
+			To:      change.From,
+			By:      erp.PaymentActor,
+			Comment: fmt.Sprintf("refund failed: %v", err),
+			At:      time.Now().Round(time.Second),
+		}
+		if rerr := s.storage.UpdateOrderStatus(ctx, order.ID, revert); rerr != nil {
+			level.Error(s.logger).Log("msg", "failed to revert refunded order", "order", order.ID, "err", rerr)
+		}
+		return erp.ErrInternal("failed to refund payment: %v", err)
+	}
+	order.Payment.Status = result.Status
+	order.Payment.ModifiedOn = time.Now()
+	return s.storage.UpdateOrderPayment(ctx, order.ID, order.Payment)
+}
+
+func (s *service) GetOrders(ctx context.Context, status erp.OrderStatus, page *erp.PageRequest) (*erp.OrderPage, error) {
+	if status != "" && !status.IsValid() {
+		return nil, erp.ErrBadRequest("invalid order status %q", status)
+	}
+	orders, err := s.storage.GetOrders(ctx, status, page)
+	if err != nil {
+		if err == erp.ErrInvalidCursor {
+			return nil, erp.ErrBadRequest("%s", err)
+		}
+		return nil, err
+	}
+	return orders, nil
+}
+
+// Search finds products by name, description and attribute values
+// and prices them with the market price list. Pages are numbered from 1.
+func (s *service) Search(ctx context.Context, query string, page int, limit int, userID string, market erp.Market) (*erp.SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, erp.ErrBadRequest("%s", "search query is empty")
+	}
+	page, limit = pageLimits(page, limit)
+	docs, total := s.search.Search(query, (page-1)*limit, limit)
+	result := &erp.SearchResult{
+		Products: make([]*erp.CatalogProduct, 0, len(docs)),
+		Total:    total,
+		Page:     page,
+		Limit:    limit,
+	}
+	for _, doc := range docs {
+		result.Products = append(result.Products, doc.CatalogProduct())
+	}
+	err := s.priceCatalog(ctx, userID, market, result.Products)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FilterProducts lists the products matching the facet filter with the prices of the market price list
+// and with the facet counts for the storefront sidebar. Pages are numbered from 1.
+func (s *service) FilterProducts(ctx context.Context, filter *erp.FacetFilter, page int, limit int, userID string, market erp.Market) (*erp.FacetedProducts, error) {
+	page, limit = pageLimits(page, limit)
+	filter.Offset = (page - 1) * limit
+	filter.Limit = limit
+
+	result, err := s.storage.FilterProducts(ctx, filter)
+	if err != nil {
+		if err == erp.ErrCategoryNotFound {
+			return nil, erp.ErrNotFound("category %s not found", filter.Category)
+		}
+		return nil, err
+	}
+	err = s.priceCatalog(ctx, userID, market, result.Products)
+	if err != nil {
+		return nil, err
+	}
+	facets, err := s.storage.GetFacets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Product attribute values are facets too, they follow the variation ones.
+	attributes, err := s.storage.GetAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attributes {
+		name, _, _ := erp.ParseFacetID(a.ID)
+		facets = append(facets, &erp.Facet{ID: a.ID, Name: name, Display: a.Name, Value: a.Value})
+	}
+	result.Facets = erp.GroupFacets(facets, result.Counts, filter)
+	result.Page = page
+	result.Limit = limit
+	return result, nil
+}
+
+// priceList returns the price list of the market selected by the request,
+// or of the market of the user profile when the request selects none.
+func (s *service) priceList(ctx context.Context, userID string, market erp.Market) (*erp.PriceList, error) {
+	if market.IsZero() && userID != "" {
+		user, err := s.storage.GetUser(ctx, userID)
+		if err != nil && err != erp.ErrNotFoundInStorage {
+			return nil, err
+		}
+		market = user.Market()
+	}
+	return s.pricing.PriceList(ctx, market)
+}
+
+// priceCatalog prices the product cards with the lowest current price of their variations
+// in the market price list. Storage prices the cards with the base prices already.
+func (s *service) priceCatalog(ctx context.Context, userID string, market erp.Market, products []*erp.CatalogProduct) error {
+	if len(products) == 0 {
+		return nil
+	}
+	list, err := s.priceList(ctx, userID, market)
+	if err != nil || list == nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	productPrices, err := s.storage.GetProductPrices(ctx, ids)
 	if err != nil {
 		return err
 	}
+	base := map[string]*erp.Pricing{}
+	skus := []string{}
+	for _, prices := range productPrices {
+		for sku, pricing := range prices {
+			base[sku] = pricing
+			skus = append(skus, sku)
+		}
+	}
+	listed, err := s.storage.GetPriceListPrices(ctx, list.ID, skus)
+	if err != nil {
+		return err
+	}
+	prices := list.Resolve(base, listed)
+
+	now := time.Now()
+	for _, p := range products {
+		p.Price = erp.Money{Currency: list.Currency}
+		for sku := range productPrices[p.ID] {
+			if price := prices[sku].Current(now); p.Price.IsZero() || price.Less(p.Price) {
+				p.Price = price
+			}
+		}
+	}
 	return nil
 }
 
-func (s *service) RemoveCategory(ctx context.Context, sku string, category *erp.Category) error {
-	err := s.storage.RemoveCategory(ctx, sku, category)
+// GetPriceLists returns all the price lists.
+func (s *service) GetPriceLists(ctx context.Context) ([]*erp.PriceList, error) {
+	return s.storage.GetPriceLists(ctx)
+}
+
+// CreatePriceList adds a price list of a currency in a region, or in all the regions without one.
+// Until prices are set in the list its SKUs are priced from the base prices at the exchange rate.
+func (s *service) CreatePriceList(ctx context.Context, input *erp.PriceListInput) (*erp.PriceList, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	list := input.PriceList(time.Now())
+	err := s.storage.CreatePriceList(ctx, list)
+	if err != nil {
+		if err == erp.ErrDuplicateKeyInStorage {
+			return nil, erp.ErrConflict("price list %s already exists", list.ID)
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdatePriceList renames the price list or changes its exchange rate.
+// Orders keep the rate they were placed at.
+func (s *service) UpdatePriceList(ctx context.Context, id string, patch *erp.PriceListPatch) (*erp.PriceList, error) {
+	list, err := s.storage.GetPriceList(ctx, id)
+	if err != nil {
+		return nil, priceListError(err, id)
+	}
+	if err := patch.Validate(list.Currency); err != nil {
+		return nil, err
+	}
+	patch.Apply(list, time.Now())
+	err = s.storage.UpdatePriceList(ctx, list)
 	if err != nil {
+		return nil, priceListError(err, id)
+	}
+	return list, nil
+}
+
+// DeletePriceList deletes the price list with its prices, its market is priced with the base prices.
+func (s *service) DeletePriceList(ctx context.Context, id string) error {
+	return priceListError(s.storage.DeletePriceList(ctx, id), id)
+}
+
+// GetPriceListPrices returns the prices set in the price list ordered by SKU.
+func (s *service) GetPriceListPrices(ctx context.Context, id string) ([]*erp.PriceListPrice, error) {
+	if _, err := s.storage.GetPriceList(ctx, id); err != nil {
+		return nil, priceListError(err, id)
+	}
+	prices, err := s.storage.GetPriceListPrices(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*erp.PriceListPrice, 0, len(prices))
+	for sku, pricing := range prices {
+		result = append(result, erp.NewPriceListPrice(sku, pricing))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].SKU < result[j].SKU })
+	return result, nil
+}
+
+// SetPriceListPrices sets the prices of SKUs in the price list, the prices are in the list currency.
+func (s *service) SetPriceListPrices(ctx context.Context, id string, prices []*erp.PriceListPrice) error {
+	list, err := s.storage.GetPriceList(ctx, id)
+	if err != nil {
+		return priceListError(err, id)
+	}
+	if err := erp.ValidatePriceListPrices(prices, list.Currency); err != nil {
 		return err
 	}
+
+	skus := make([]string, 0, len(prices))
+	pricing := make(map[string]*erp.Pricing, len(prices))
+	for _, p := range prices {
+		skus = append(skus, p.SKU)
+		pricing[p.SKU] = p.Pricing(list.Currency)
+	}
+	found, err := s.storage.FindSKUs(ctx, skus)
+	if err != nil {
+		return err
+	}
+	if len(found) != len(skus) {
+		var v erp.Validation
+		for i, sku := range skus {
+			known := false
+			for _, f := range found {
+				if sku == f {
+					known = true
+				}
+			}
+			if !known {
+				v.Add(fmt.Sprintf("prices[%d].sku", i), "%s", erp.ErrUnknownSKU)
+			}
+		}
+		return v.Err()
+	}
+	return priceListError(s.storage.SetPriceListPrices(ctx, id, pricing), id)
+}
+
+// RemovePriceListPrice removes the price of the SKU from the price list,
+// the SKU is priced from its base price at the exchange rate again.
+func (s *service) RemovePriceListPrice(ctx context.Context, id string, sku string) error {
+	err := s.storage.RemovePriceListPrice(ctx, id, sku)
+	if err == erp.ErrNotFoundInStorage {
+		return erp.ErrNotFound("price list %s has no price for %s", id, sku)
+	}
+	return err
+}
+
+// priceListError turns price list storage errors into service errors.
+func priceListError(err error, id string) error {
+	if err == erp.ErrPriceListNotFound {
+		return erp.ErrNotFound("price list %s not found", id)
+	}
+	return err
+}
+
+// GetConfigurator returns the configurator of the product.
+func (s *service) GetConfigurator(ctx context.Context, productID string) (*erp.Configurator, error) {
+	configurator, err := s.storage.GetConfigurator(ctx, productID)
+	if err != nil {
+		return nil, configuratorError(err, productID)
+	}
+	return configurator, nil
+}
+
+// SetConfigurator creates or replaces the configurator of the product.
+// Option names have to be in the attribute dictionary.
+func (s *service) SetConfigurator(ctx context.Context, productID string, input *erp.ConfiguratorInput) (*erp.Configurator, error) {
+	dict, err := s.attributeDictionary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := input.Validate(dict); err != nil {
+		return nil, err
+	}
+	configurator := input.Configurator(productID, time.Now())
+	if err := s.storage.SetConfigurator(ctx, configurator); err != nil {
+		return nil, configuratorError(err, productID)
+	}
+	return configurator, nil
+}
+
+// DeleteConfigurator deletes the configurator of the product.
+func (s *service) DeleteConfigurator(ctx context.Context, productID string) error {
+	return configuratorError(s.storage.DeleteConfigurator(ctx, productID), productID)
+}
+
+// ConfigureProduct returns the variation of the product with the selected option values,
+// the variation is created when the product has none with the values yet.
+// The variation is priced in the price list of the market.
+func (s *service) ConfigureProduct(ctx context.Context, productID string, selected map[string]string, userID string, market erp.Market) (*erp.Configuration, error) {
+	configurator, err := s.storage.GetConfigurator(ctx, productID)
+	if err != nil {
+		return nil, configuratorError(err, productID)
+	}
+	conf, err := configurator.Configure(selected)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.configuredVariation(ctx, productID, conf); err != nil {
+		return nil, err
+	}
+
+	list, err := s.priceList(ctx, userID, market)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := s.pricing.Prices(ctx, []string{conf.SKU}, list)
+	if err != nil {
+		return nil, err
+	}
+	conf.Pricing = prices[conf.SKU]
+	return conf, nil
+}
+
+// configuredVariation sets the SKU of the variation with the values of the combination,
+// creating the variation with the generated SKU and the thumbnail of the default variation
+// when there is none.
+func (s *service) configuredVariation(ctx context.Context, productID string, conf *erp.Configuration) error {
+	variations, err := s.storage.GetProductVariations(ctx, productID)
+	if err != nil {
+		return variationError(err, productID, conf.SKU)
+	}
+	if sku, ok := matchConfiguration(variations, conf); ok {
+		conf.SKU = sku
+		return nil
+	}
+
+	dict, err := s.attributeDictionary(ctx)
+	if err != nil {
+		return err
+	}
+	input := &erp.VariationInput{SKU: conf.SKU, Price: conf.Price.String(), Attributes: conf.Attributes}
+	for _, v := range variations {
+		if v.Default {
+			input.Thumbnail = v.Thumbnail
+		}
+	}
+	if err := erp.ValidateVariation(input, variations, dict); err != nil {
+		return err
+	}
+	err = s.storage.CreateVariation(ctx, productID, input)
+	if err == erp.ErrDuplicateKeyInStorage {
+		// The combination may have been configured meanwhile.
+		variations, err := s.storage.GetProductVariations(ctx, productID)
+		if err != nil {
+			return variationError(err, productID, conf.SKU)
+		}
+		if sku, ok := matchConfiguration(variations, conf); ok {
+			conf.SKU = sku
+			return nil
+		}
+		return erp.ErrConflict("sku %s is already used by another product", conf.SKU)
+	}
+	if err != nil {
+		return variationError(err, productID, conf.SKU)
+	}
+	conf.Created = true
 	return nil
 }
+
+// matchConfiguration returns the SKU of the first variation with the values of the combination.
+func matchConfiguration(variations []*erp.VariationInput, conf *erp.Configuration) (string, bool) {
+	for _, v := range variations {
+		if conf.Matches(v) {
+			return v.SKU, true
+		}
+	}
+	return "", false
+}
+
+// configuratorError turns configurator storage errors into service errors.
+func configuratorError(err error, productID string) error {
+	switch err {
+	case erp.ErrConfiguratorNotFound:
+		return erp.ErrNotFound("product %s has no configurator", productID)
+	case erp.ErrProductNotFound:
+		return erp.ErrNotFound("product %s not found", productID)
+	}
+	return err
+}
+
+// GetDeliveryZones returns all the delivery zones.
+func (s *service) GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error) {
+	return s.storage.GetDeliveryZones(ctx)
+}
+
+// SetDeliveryZone creates or replaces the delivery zone.
+func (s *service) SetDeliveryZone(ctx context.Context, id string, input *erp.DeliveryZoneInput) (*erp.DeliveryZone, error) {
+	if err := input.Validate(id); err != nil {
+		return nil, err
+	}
+	zone := input.DeliveryZone(id, time.Now())
+	if err := s.storage.SetDeliveryZone(ctx, zone); err != nil {
+		return nil, err
+	}
+	return zone, nil
+}
+
+// DeleteDeliveryZone deletes the delivery zone, addresses in it can't be delivered to anymore.
+func (s *service) DeleteDeliveryZone(ctx context.Context, id string) error {
+	err := s.storage.DeleteDeliveryZone(ctx, id)
+	if err == erp.ErrDeliveryZoneNotFound {
+		return erp.ErrNotFound("delivery zone %s not found", id)
+	}
+	return err
+}
+
+// GetPickupPoints returns all the pickup points, the inactive ones too.
+func (s *service) GetPickupPoints(ctx context.Context) ([]*erp.PickupPoint, error) {
+	return s.storage.GetPickupPoints(ctx)
+}
+
+// NearestPickupPoints returns the active pickup points closest to the location.
+// Points farther than the radius are left out, a zero radius doesn't limit the distance.
+func (s *service) NearestPickupPoints(ctx context.Context, location erp.Location, limit int, radiusKm float64) ([]*erp.NearbyPickupPoint, error) {
+	if err := location.Validate(); err != nil {
+		return nil, erp.ErrBadRequest("%s", err)
+	}
+	if radiusKm < 0 {
+		return nil, erp.ErrBadRequest("%s", "radius must not be negative")
+	}
+	if limit < 1 {
+		limit = erp.DefaultPickupPointsLimit
+	}
+	if limit > erp.MaxPickupPointsLimit {
+		limit = erp.MaxPickupPointsLimit
+	}
+	points, err := s.storage.GetPickupPoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return erp.NearestPickupPoints(points, location, limit, radiusKm), nil
+}
+
+// SetPickupPoint creates or replaces the pickup point.
+func (s *service) SetPickupPoint(ctx context.Context, id string, input *erp.PickupPointInput) (*erp.PickupPoint, error) {
+	if err := input.Validate(id); err != nil {
+		return nil, err
+	}
+	point := input.PickupPoint(id, time.Now())
+	if err := s.storage.SetPickupPoint(ctx, point); err != nil {
+		return nil, err
+	}
+	return point, nil
+}
+
+// DeletePickupPoint deletes the pickup point.
+func (s *service) DeletePickupPoint(ctx context.Context, id string) error {
+	err := s.storage.DeletePickupPoint(ctx, id)
+	if err == erp.ErrPickupPointNotFound {
+		return erp.ErrNotFound("pickup point %s not found", id)
+	}
+	return err
+}
+
+// pageLimits applies the defaults and bounds to the page number and size.
+func pageLimits(page int, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return page, limit
+}
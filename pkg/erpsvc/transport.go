@@ -4,18 +4,22 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/anabiozz/core/lapkins/pkg/auth"
-	"github.com/anabiozz/core/lapkins/pkg/cookies"
 	"github.com/anabiozz/core/lapkins/pkg/erp"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/gorilla/mux"
 )
 
 // ***************** GET PRODUCT ********************
 
 type getProductRequest struct {
-	SKU string
+	SKU    string
+	UserID string
+	Market erp.Market
 }
 
 type getProductResponse struct {
@@ -26,6 +30,10 @@ type getProductResponse struct {
 func decodeGetProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	req := getProductRequest{}
 	req.SKU = r.URL.Query().Get("sku")
+	var err error
+	if req.UserID, req.Market, err = decodeMarket(r); err != nil {
+		return nil, err
+	}
 	return req, nil
 }
 
@@ -42,24 +50,77 @@ func encodeGetProductResponse(ctx context.Context, w http.ResponseWriter, respon
 func makeGetProductEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(getProductRequest)
-		product, err := s.GetProduct(ctx, req.SKU)
+		product, err := s.GetProduct(ctx, req.SKU, req.UserID, req.Market)
 		return getProductResponse{Err: err, Product: product}, nil
 	}
 }
 
+// ***************** CREATE PRODUCT ********************
+
+type createProductRequest struct {
+	Input *erp.ProductInput
+}
+
+type createProductResponse struct {
+	Product *erp.ProductInput `json:"product"`
+	Err     error             `json:"err"`
+}
+
+func makeCreateProductEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createProductRequest)
+		product, err := s.CreateProduct(ctx, req.Input)
+		return createProductResponse{Err: err, Product: product}, nil
+	}
+}
+
+func decodeCreateProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := createProductRequest{Input: &erp.ProductInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeCreateProductResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(createProductResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(res.Product)
+}
+
 // ***************** UPDATE PRODUCT ********************
 
 type updateProductRequest struct {
-	Product *erp.Product
+	ID    string
+	Patch *erp.ProductPatch
 }
 
 type updateProductResponse struct {
 	Err error `json:"err"`
 }
 
+func makeUpdateProductEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(updateProductRequest)
+		err = s.UpdateProduct(ctx, req.ID, req.Patch)
+		return updateProductResponse{Err: err}, nil
+	}
+}
+
 func decodeUpdateProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := updateProductRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := updateProductRequest{ID: mux.Vars(r)["id"], Patch: &erp.ProductPatch{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Patch); err != nil {
 		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
 	}
 	return req, nil
@@ -72,36 +133,193 @@ func encodeUpdateProductResponse(ctx context.Context, w http.ResponseWriter, res
 		return nil
 	}
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(res)
+	return json.NewEncoder(w).Encode(true)
 }
 
-func makeUpdateProductEndpoint(s Service) endpoint.Endpoint {
+// ***************** DELETE PRODUCT ********************
+
+type deleteProductRequest struct {
+	ID string
+}
+
+type deleteProductResponse struct {
+	Err error `json:"err"`
+}
+
+func makeDeleteProductEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(updateProductRequest)
-		err = s.UpdateProduct(ctx, req.Product)
-		return updateProductResponse{Err: err}, nil
+		req := request.(deleteProductRequest)
+		err = s.DeleteProduct(ctx, req.ID)
+		return deleteProductResponse{Err: err}, nil
+	}
+}
+
+func decodeDeleteProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := deleteProductRequest{ID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeDeleteProductResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deleteProductResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// ***************** CREATE VARIATION ********************
+
+type createVariationRequest struct {
+	ProductID string
+	Input     *erp.VariationInput
+}
+
+type createVariationResponse struct {
+	Variation *erp.VariationInput `json:"variation"`
+	Err       error               `json:"err"`
+}
+
+func makeCreateVariationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createVariationRequest)
+		variation, err := s.CreateVariation(ctx, req.ProductID, req.Input)
+		return createVariationResponse{Err: err, Variation: variation}, nil
+	}
+}
+
+func decodeCreateVariationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := createVariationRequest{ProductID: mux.Vars(r)["id"], Input: &erp.VariationInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeCreateVariationResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(createVariationResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(res.Variation)
+}
+
+// ***************** UPDATE VARIATION ********************
+
+type updateVariationRequest struct {
+	ProductID string
+	SKU       string
+	Patch     *erp.VariationPatch
+}
+
+type updateVariationResponse struct {
+	Variation *erp.VariationInput `json:"variation"`
+	Err       error               `json:"err"`
+}
+
+func makeUpdateVariationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(updateVariationRequest)
+		variation, err := s.UpdateVariation(ctx, req.ProductID, req.SKU, req.Patch)
+		return updateVariationResponse{Err: err, Variation: variation}, nil
+	}
+}
+
+func decodeUpdateVariationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	vars := mux.Vars(r)
+	req := updateVariationRequest{ProductID: vars["id"], SKU: vars["sku"], Patch: &erp.VariationPatch{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Patch); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeUpdateVariationResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(updateVariationResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Variation)
+}
+
+// ***************** DELETE VARIATION ********************
+
+type deleteVariationRequest struct {
+	ProductID string
+	SKU       string
+}
+
+type deleteVariationResponse struct {
+	Err error `json:"err"`
+}
+
+func makeDeleteVariationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteVariationRequest)
+		err = s.DeleteVariation(ctx, req.ProductID, req.SKU)
+		return deleteVariationResponse{Err: err}, nil
+	}
+}
+
+func decodeDeleteVariationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	vars := mux.Vars(r)
+	req := deleteVariationRequest{ProductID: vars["id"], SKU: vars["sku"]}
+	return req, nil
+}
+
+func encodeDeleteVariationResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deleteVariationResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
 	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
 }
 
 // **************** GET PRODUCTS *******************
 
 type getProductsRequest struct {
+	Page *erp.PageRequest
 }
 
 type getProductsResponse struct {
-	Products []*erp.Product `json:"products"`
-	Err      error          `json:"err"`
+	Products *erp.ProductPage `json:"products"`
+	Err      error            `json:"err"`
 }
 
 func makeGetProductsEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		products, err := s.GetProducts(ctx)
+		req := request.(getProductsRequest)
+		products, err := s.GetProducts(ctx, req.Page)
 		return getProductsResponse{Err: err, Products: products}, nil
 	}
 }
 
 func decodeGetProductsRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := getProductsRequest{}
+	page, err := erp.DecodePageRequest(r.URL.Query(), "id", "name")
+	if err != nil {
+		return nil, err
+	}
+	req := getProductsRequest{Page: page}
 	return req, nil
 }
 
@@ -117,22 +335,32 @@ func encodeGetProductsResponse(ctx context.Context, w http.ResponseWriter, respo
 
 // **************************** GET CATEGORIES *************************
 
-type getCategoriesRequest struct{}
+type getCategoriesRequest struct {
+	Page *erp.PageRequest
+}
 
 type getCategoriesResponse struct {
-	Categories []*erp.Category `json:"categories"`
-	Err        error           `json:"err"`
+	Categories *erp.CategoryPage `json:"categories"`
+	Err        error             `json:"err"`
 }
 
 func makeGetCategoriesEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		categories, err := s.GetCategories(ctx)
+		req := request.(getCategoriesRequest)
+		categories, err := s.GetCategories(ctx, req.Page)
 		return getCategoriesResponse{Err: err, Categories: categories}, nil
 	}
 }
 
 func decodeGetCategoriesRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := getCategoriesRequest{}
+	page, err := erp.DecodePageRequest(r.URL.Query(), "id", "position")
+	if err != nil {
+		return nil, err
+	}
+	if page.Sort == "" {
+		page.Sort = "position"
+	}
+	req := getCategoriesRequest{Page: page}
 	return req, nil
 }
 
@@ -146,146 +374,159 @@ func encodeGetCategoriesResponse(ctx context.Context, w http.ResponseWriter, res
 	return json.NewEncoder(w).Encode(res.Categories)
 }
 
-// **************************** GET ATTRIBUTES *************************
+// **************************** GET CATEGORY *************************
 
-type addAttributeRequest struct {
-	SKU       string
-	Attribute *erp.NameValue
+type getCategoryRequest struct {
+	ID string
 }
 
-type addAttributeResponse struct {
-	Err error `json:"err"`
+type getCategoryResponse struct {
+	Category *erp.Category `json:"category"`
+	Err      error         `json:"err"`
 }
 
-func makeAddAttributeEndpoint(s Service) endpoint.Endpoint {
+func makeGetCategoryEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(addAttributeRequest)
-		err = s.AddAttribute(ctx, req.SKU, req.Attribute)
-		return addAttributeResponse{Err: err}, nil
+		req := request.(getCategoryRequest)
+		category, err := s.GetCategory(ctx, req.ID)
+		return getCategoryResponse{Err: err, Category: category}, nil
 	}
 }
 
-func decodeAddAttributeRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := addAttributeRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
-	}
+func decodeGetCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := getCategoryRequest{ID: mux.Vars(r)["id"]}
 	return req, nil
 }
 
-func encodeAddAttributeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	res := response.(addAttributeResponse)
+func encodeGetCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getCategoryResponse)
 	if res.Err != nil {
 		encodeError(ctx, res.Err, w)
 		return nil
 	}
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(true)
+	return json.NewEncoder(w).Encode(res.Category)
 }
 
-// **************************** REMOVE ATTRIBUTES *************************
+// **************************** GET CATEGORY PRODUCTS *************************
 
-type removeAttributeRequest struct {
-	SKU       string
-	Attribute string
+type getCategoryProductsRequest struct {
+	ID     string
+	Page   *erp.PageRequest
+	UserID string
+	Market erp.Market
 }
 
-type removeAttributeResponse struct {
-	Err error `json:"err"`
+type getCategoryProductsResponse struct {
+	Products *erp.CatalogPage `json:"products"`
+	Err      error            `json:"err"`
 }
 
-func makeRemoveAttributeEndpoint(s Service) endpoint.Endpoint {
+func makeGetCategoryProductsEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(removeAttributeRequest)
-		err = s.RemoveAttribute(ctx, req.SKU, req.Attribute)
-		return removeAttributeResponse{Err: err}, nil
+		req := request.(getCategoryProductsRequest)
+		products, err := s.GetCategoryProducts(ctx, req.ID, req.Page, req.UserID, req.Market)
+		return getCategoryProductsResponse{Err: err, Products: products}, nil
 	}
 }
 
-func decodeRemoveAttributeRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := removeAttributeRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+func decodeGetCategoryProductsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	page, err := erp.DecodePageRequest(r.URL.Query(), "id", "name")
+	if err != nil {
+		return nil, err
+	}
+	req := getCategoryProductsRequest{ID: mux.Vars(r)["id"], Page: page}
+	if req.UserID, req.Market, err = decodeMarket(r); err != nil {
+		return nil, err
 	}
 	return req, nil
 }
 
-func encodeRemoveAttributeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	res := response.(removeAttributeResponse)
+func encodeGetCategoryProductsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getCategoryProductsResponse)
 	if res.Err != nil {
 		encodeError(ctx, res.Err, w)
 		return nil
 	}
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(true)
+	return json.NewEncoder(w).Encode(res.Products)
 }
 
-// **************************** ADD CATEGORY *************************
+// **************************** CREATE CATEGORY *************************
 
-type addCategoryRequest struct {
-	SKU      string
-	Category *erp.Category
+type createCategoryRequest struct {
+	Input *erp.CategoryInput
 }
 
-type addCategoryResponse struct {
-	Err error `json:"err"`
+type createCategoryResponse struct {
+	Category *erp.Category `json:"category"`
+	Err      error         `json:"err"`
 }
 
-func makeAddCategoryEndpoint(s Service) endpoint.Endpoint {
+func makeCreateCategoryEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(addCategoryRequest)
-		err = s.AddCategory(ctx, req.SKU, req.Category)
-		return addCategoryResponse{Err: err}, nil
+		req := request.(createCategoryRequest)
+		category, err := s.CreateCategory(ctx, req.Input)
+		return createCategoryResponse{Err: err, Category: category}, nil
 	}
 }
 
-func decodeAddCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := addCategoryRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+func decodeCreateCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := createCategoryRequest{Input: &erp.CategoryInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
 		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
 	}
 	return req, nil
 }
 
-func encodeAddCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	res := response.(addCategoryResponse)
+func encodeCreateCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(createCategoryResponse)
 	if res.Err != nil {
 		encodeError(ctx, res.Err, w)
 		return nil
 	}
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(true)
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(res.Category)
 }
 
-// **************************** REMOVE CATEGORY *************************
+// **************************** RENAME CATEGORY *************************
 
-type removeCategoryRequest struct {
-	SKU      string
-	Category *erp.Category
+type renameCategoryRequest struct {
+	ID          string           `json:"-"`
+	Name        []*erp.LangValue `json:"name"`
+	Description []*erp.LangValue `json:"description"`
 }
 
-type removeCategoryResponse struct {
+type renameCategoryResponse struct {
 	Err error `json:"err"`
 }
 
-func makeRemoveCategoryEndpoint(s Service) endpoint.Endpoint {
+func makeRenameCategoryEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		req := request.(removeCategoryRequest)
-		err = s.RemoveCategory(ctx, req.SKU, req.Category)
-		return removeCategoryResponse{Err: err}, nil
+		req := request.(renameCategoryRequest)
+		err = s.RenameCategory(ctx, req.ID, req.Name, req.Description)
+		return renameCategoryResponse{Err: err}, nil
 	}
 }
 
-func decodeRemoveCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := removeCategoryRequest{}
+func decodeRenameCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := renameCategoryRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
 	}
+	req.ID = mux.Vars(r)["id"]
 	return req, nil
 }
 
-func encodeRemoveCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	res := response.(removeCategoryResponse)
+func encodeRenameCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(renameCategoryResponse)
 	if res.Err != nil {
 		encodeError(ctx, res.Err, w)
 		return nil
@@ -294,56 +535,1327 @@ func encodeRemoveCategoryResponse(ctx context.Context, w http.ResponseWriter, re
 	return json.NewEncoder(w).Encode(true)
 }
 
-type addProductRequest struct {
-	SKU            string `json:"sku"`
-	UserID         string `json:"user_id"`
-	IsLoggedIn     bool   `json:"is_logged_in"`
-	IsTmpUserIDSet bool   `json:"is_tmp_user_id_set"`
+// **************************** MOVE CATEGORY *************************
+
+type moveCategoryRequest struct {
+	ID     string `json:"-"`
+	Parent string `json:"parent"`
 }
 
-type addProductResponse struct {
-	Err                error  `json:"err"`
-	UserID             string `json:"user_id"`
-	SetTmpUserIDCookie bool   `json:"set_tmp_user_id_cookie"`
+type moveCategoryResponse struct {
+	Category *erp.Category `json:"category"`
+	Err      error         `json:"err"`
 }
 
-func decodeAddProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	req := addProductRequest{}
+func makeMoveCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(moveCategoryRequest)
+		category, err := s.MoveCategory(ctx, req.ID, req.Parent)
+		return moveCategoryResponse{Err: err, Category: category}, nil
+	}
+}
+
+func decodeMoveCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := moveCategoryRequest{}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
 	}
-	userID, isLoggedIn, err := auth.GetUserID(r)
-	if err != nil {
-		return nil, err
+	req.ID = mux.Vars(r)["id"]
+	return req, nil
+}
+
+func encodeMoveCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(moveCategoryResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
 	}
-	req.UserID = userID
-	if userID != "" && isLoggedIn {
-		req.IsLoggedIn = true
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Category)
+}
+
+// **************************** REORDER CATEGORIES *************************
+
+type reorderCategoriesRequest struct {
+	Parent string   `json:"parent"`
+	IDs    []string `json:"ids"`
+}
+
+type reorderCategoriesResponse struct {
+	Err error `json:"err"`
+}
+
+func makeReorderCategoriesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reorderCategoriesRequest)
+		err = s.ReorderCategories(ctx, req.Parent, req.IDs)
+		return reorderCategoriesResponse{Err: err}, nil
 	}
-	tmpUserID, err := cookies.GetCookieValue(r, "tmp-user-id")
-	if err != nil {
-		if err != http.ErrNoCookie {
-			return nil, err
-		}
+}
+
+func decodeReorderCategoriesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
 	}
-	if tmpUserID != "" {
-		req.IsTmpUserIDSet = true
+	req := reorderCategoriesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
 	}
 	return req, nil
 }
 
-func encodeAddProductResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	res := response.(addProductResponse)
+func encodeReorderCategoriesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(reorderCategoriesResponse)
 	if res.Err != nil {
 		encodeError(ctx, res.Err, w)
 		return nil
 	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
 
-	if res.SetTmpUserIDCookie {
-		http.SetCookie(w, &http.Cookie{
-			Path:    "/",
-			Name:    "tmp-user-id",
-			Value:   res.UserID,
+// **************************** DELETE CATEGORY *************************
+
+type deleteCategoryRequest struct {
+	ID string
+}
+
+type deleteCategoryResponse struct {
+	Err error `json:"err"`
+}
+
+func makeDeleteCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteCategoryRequest)
+		err = s.DeleteCategory(ctx, req.ID)
+		return deleteCategoryResponse{Err: err}, nil
+	}
+}
+
+func decodeDeleteCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := deleteCategoryRequest{ID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeDeleteCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deleteCategoryResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** GET ATTRIBUTES *************************
+
+type getAttributesRequest struct{}
+
+type getAttributesResponse struct {
+	Attributes []*erp.Attribute `json:"attributes"`
+	Err        error            `json:"err"`
+}
+
+func makeGetAttributesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		attributes, err := s.GetAttributes(ctx)
+		return getAttributesResponse{Err: err, Attributes: attributes}, nil
+	}
+}
+
+func decodeGetAttributesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return getAttributesRequest{}, nil
+}
+
+func encodeGetAttributesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getAttributesResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Attributes)
+}
+
+// **************************** CREATE ATTRIBUTE *************************
+
+type createAttributeRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type createAttributeResponse struct {
+	Attribute *erp.Attribute `json:"attribute"`
+	Err       error          `json:"err"`
+}
+
+func makeCreateAttributeEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createAttributeRequest)
+		attribute, err := s.CreateAttribute(ctx, req.Name, req.Value)
+		return createAttributeResponse{Err: err, Attribute: attribute}, nil
+	}
+}
+
+func decodeCreateAttributeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := createAttributeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeCreateAttributeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(createAttributeResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(res.Attribute)
+}
+
+// **************************** ADD ATTRIBUTE *************************
+
+type addAttributeRequest struct {
+	Edit *erp.AttributeEdit
+}
+
+type addAttributeResponse struct {
+	Err error `json:"err"`
+}
+
+func makeAddAttributeEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(addAttributeRequest)
+		err = s.AddAttribute(ctx, req.Edit)
+		return addAttributeResponse{Err: err}, nil
+	}
+}
+
+func decodeAddAttributeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := addAttributeRequest{Edit: &erp.AttributeEdit{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Edit); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeAddAttributeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(addAttributeResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** REMOVE ATTRIBUTE *************************
+
+type removeAttributeRequest struct {
+	Edit *erp.AttributeEdit
+}
+
+type removeAttributeResponse struct {
+	Err error `json:"err"`
+}
+
+func makeRemoveAttributeEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(removeAttributeRequest)
+		err = s.RemoveAttribute(ctx, req.Edit)
+		return removeAttributeResponse{Err: err}, nil
+	}
+}
+
+func decodeRemoveAttributeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := removeAttributeRequest{Edit: &erp.AttributeEdit{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Edit); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeRemoveAttributeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(removeAttributeResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** ADD CATEGORY *************************
+
+type addCategoryRequest struct {
+	ProductID  string `json:"product_id"`
+	CategoryID string `json:"category_id"`
+	Primary    bool   `json:"primary"`
+}
+
+type addCategoryResponse struct {
+	Err error `json:"err"`
+}
+
+func makeAddCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(addCategoryRequest)
+		err = s.AddCategory(ctx, req.ProductID, req.CategoryID, req.Primary)
+		return addCategoryResponse{Err: err}, nil
+	}
+}
+
+func decodeAddCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := addCategoryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeAddCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(addCategoryResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** REMOVE CATEGORY *************************
+
+type removeCategoryRequest struct {
+	ProductID  string `json:"product_id"`
+	CategoryID string `json:"category_id"`
+}
+
+type removeCategoryResponse struct {
+	Err error `json:"err"`
+}
+
+func makeRemoveCategoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(removeCategoryRequest)
+		err = s.RemoveCategory(ctx, req.ProductID, req.CategoryID)
+		return removeCategoryResponse{Err: err}, nil
+	}
+}
+
+func decodeRemoveCategoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := removeCategoryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeRemoveCategoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(removeCategoryResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** CHANGE ORDER STATUS *************************
+
+type changeOrderStatusRequest struct {
+	OrderID string          `json:"order_id"`
+	Status  erp.OrderStatus `json:"status"`
+	Comment string          `json:"comment"`
+	By      string          `json:"-"`
+}
+
+type changeOrderStatusResponse struct {
+	Order *erp.Order `json:"order"`
+	Err   error      `json:"err"`
+}
+
+func makeChangeOrderStatusEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(changeOrderStatusRequest)
+		order, err := s.ChangeOrderStatus(ctx, req.OrderID, req.Status, req.By, req.Comment)
+		return changeOrderStatusResponse{Err: err, Order: order}, nil
+	}
+}
+
+func decodeChangeOrderStatusRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	claims, err := checkStaff(r)
+	if err != nil {
+		return nil, err
+	}
+	req := changeOrderStatusRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	req.By = claims.Subject
+	return req, nil
+}
+
+func encodeChangeOrderStatusResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(changeOrderStatusResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Order)
+}
+
+// **************************** GET ORDERS *************************
+
+type getOrdersRequest struct {
+	Status erp.OrderStatus
+	Page   *erp.PageRequest
+}
+
+type getOrdersResponse struct {
+	Orders *erp.OrderPage `json:"orders"`
+	Err    error          `json:"err"`
+}
+
+func makeGetOrdersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getOrdersRequest)
+		orders, err := s.GetOrders(ctx, req.Status, req.Page)
+		return getOrdersResponse{Err: err, Orders: orders}, nil
+	}
+}
+
+func decodeGetOrdersRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	query := r.URL.Query()
+	page, err := erp.DecodePageRequest(query, "id", "created_on", "total_price")
+	if err != nil {
+		return nil, err
+	}
+	// The newest orders come first unless asked otherwise.
+	if page.Sort == "" {
+		page.Sort = "created_on"
+		page.Desc = query.Get("order") != "asc"
+	}
+	req := getOrdersRequest{Page: page}
+	req.Status = erp.OrderStatus(query.Get("status"))
+	return req, nil
+}
+
+func encodeGetOrdersResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getOrdersResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Orders)
+}
+
+// **************************** SEARCH *************************
+
+type searchRequest struct {
+	Query  string
+	Page   int
+	Limit  int
+	UserID string
+	Market erp.Market
+}
+
+type searchResponse struct {
+	Result *erp.SearchResult `json:"result"`
+	Err    error             `json:"err"`
+}
+
+func makeSearchEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(searchRequest)
+		result, err := s.Search(ctx, req.Query, req.Page, req.Limit, req.UserID, req.Market)
+		return searchResponse{Err: err, Result: result}, nil
+	}
+}
+
+func decodeSearchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	req := searchRequest{
+		Query: query.Get("q"),
+	}
+	var err error
+	if req.Page, req.Limit, err = decodePage(query); err != nil {
+		return nil, err
+	}
+	if req.UserID, req.Market, err = decodeMarket(r); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func encodeSearchResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(searchResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Result)
+}
+
+// **************************** FILTER PRODUCTS *************************
+
+type filterProductsRequest struct {
+	Filter *erp.FacetFilter
+	Page   int
+	Limit  int
+	UserID string
+	Market erp.Market
+}
+
+type filterProductsResponse struct {
+	Result *erp.FacetedProducts `json:"result"`
+	Err    error                `json:"err"`
+}
+
+func makeFilterProductsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(filterProductsRequest)
+		result, err := s.FilterProducts(ctx, req.Filter, req.Page, req.Limit, req.UserID, req.Market)
+		return filterProductsResponse{Err: err, Result: result}, nil
+	}
+}
+
+// decodeFilterProductsRequest reads the filter from a query like
+// ?category=posters-without-frame&facet=size=250x400&facet=frame=без рамки
+func decodeFilterProductsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	req := filterProductsRequest{
+		Filter: &erp.FacetFilter{
+			Category: query.Get("category"),
+		},
+	}
+	for _, id := range query["facet"] {
+		name, value, ok := erp.ParseFacetID(id)
+		if !ok {
+			return nil, erp.ErrBadRequest("invalid facet %q", id)
+		}
+		req.Filter.Select(name, value)
+	}
+	var err error
+	if req.Page, req.Limit, err = decodePage(query); err != nil {
+		return nil, err
+	}
+	if req.UserID, req.Market, err = decodeMarket(r); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func encodeFilterProductsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(filterProductsResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Result)
+}
+
+// **************************** GET PRICE LISTS *************************
+
+type getPriceListsRequest struct{}
+
+type getPriceListsResponse struct {
+	PriceLists []*erp.PriceList `json:"price_lists"`
+	Err        error            `json:"err"`
+}
+
+func makeGetPriceListsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		lists, err := s.GetPriceLists(ctx)
+		return getPriceListsResponse{Err: err, PriceLists: lists}, nil
+	}
+}
+
+func decodeGetPriceListsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	return getPriceListsRequest{}, nil
+}
+
+func encodeGetPriceListsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getPriceListsResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.PriceLists)
+}
+
+// **************************** CREATE PRICE LIST *************************
+
+type createPriceListRequest struct {
+	Input *erp.PriceListInput
+}
+
+type createPriceListResponse struct {
+	PriceList *erp.PriceList `json:"price_list"`
+	Err       error          `json:"err"`
+}
+
+func makeCreatePriceListEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createPriceListRequest)
+		list, err := s.CreatePriceList(ctx, req.Input)
+		return createPriceListResponse{Err: err, PriceList: list}, nil
+	}
+}
+
+func decodeCreatePriceListRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := createPriceListRequest{Input: &erp.PriceListInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeCreatePriceListResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(createPriceListResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(res.PriceList)
+}
+
+// **************************** UPDATE PRICE LIST *************************
+
+type updatePriceListRequest struct {
+	ID    string
+	Patch *erp.PriceListPatch
+}
+
+type updatePriceListResponse struct {
+	PriceList *erp.PriceList `json:"price_list"`
+	Err       error          `json:"err"`
+}
+
+func makeUpdatePriceListEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(updatePriceListRequest)
+		list, err := s.UpdatePriceList(ctx, req.ID, req.Patch)
+		return updatePriceListResponse{Err: err, PriceList: list}, nil
+	}
+}
+
+func decodeUpdatePriceListRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := updatePriceListRequest{ID: mux.Vars(r)["id"], Patch: &erp.PriceListPatch{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Patch); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeUpdatePriceListResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(updatePriceListResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.PriceList)
+}
+
+// **************************** DELETE PRICE LIST *************************
+
+type deletePriceListRequest struct {
+	ID string
+}
+
+type deletePriceListResponse struct {
+	Err error `json:"err"`
+}
+
+func makeDeletePriceListEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deletePriceListRequest)
+		err = s.DeletePriceList(ctx, req.ID)
+		return deletePriceListResponse{Err: err}, nil
+	}
+}
+
+func decodeDeletePriceListRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := deletePriceListRequest{ID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeDeletePriceListResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deletePriceListResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** GET PRICE LIST PRICES *************************
+
+type getPriceListPricesRequest struct {
+	ID string
+}
+
+type getPriceListPricesResponse struct {
+	Prices []*erp.PriceListPrice `json:"prices"`
+	Err    error                 `json:"err"`
+}
+
+func makeGetPriceListPricesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getPriceListPricesRequest)
+		prices, err := s.GetPriceListPrices(ctx, req.ID)
+		return getPriceListPricesResponse{Err: err, Prices: prices}, nil
+	}
+}
+
+func decodeGetPriceListPricesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := getPriceListPricesRequest{ID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeGetPriceListPricesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getPriceListPricesResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Prices)
+}
+
+// **************************** SET PRICE LIST PRICES *************************
+
+type setPriceListPricesRequest struct {
+	ID     string
+	Prices []*erp.PriceListPrice
+}
+
+type setPriceListPricesResponse struct {
+	Err error `json:"err"`
+}
+
+func makeSetPriceListPricesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setPriceListPricesRequest)
+		err = s.SetPriceListPrices(ctx, req.ID, req.Prices)
+		return setPriceListPricesResponse{Err: err}, nil
+	}
+}
+
+func decodeSetPriceListPricesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := setPriceListPricesRequest{ID: mux.Vars(r)["id"]}
+	if err := json.NewDecoder(r.Body).Decode(&req.Prices); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeSetPriceListPricesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(setPriceListPricesResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** REMOVE PRICE LIST PRICE *************************
+
+type removePriceListPriceRequest struct {
+	ID  string
+	SKU string
+}
+
+type removePriceListPriceResponse struct {
+	Err error `json:"err"`
+}
+
+func makeRemovePriceListPriceEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(removePriceListPriceRequest)
+		err = s.RemovePriceListPrice(ctx, req.ID, req.SKU)
+		return removePriceListPriceResponse{Err: err}, nil
+	}
+}
+
+func decodeRemovePriceListPriceRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	vars := mux.Vars(r)
+	req := removePriceListPriceRequest{ID: vars["id"], SKU: vars["sku"]}
+	return req, nil
+}
+
+func encodeRemovePriceListPriceResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(removePriceListPriceResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** GET CONFIGURATOR *************************
+
+type getConfiguratorRequest struct {
+	ProductID string
+}
+
+type getConfiguratorResponse struct {
+	Configurator *erp.Configurator `json:"configurator"`
+	Err          error             `json:"err"`
+}
+
+func makeGetConfiguratorEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getConfiguratorRequest)
+		configurator, err := s.GetConfigurator(ctx, req.ProductID)
+		return getConfiguratorResponse{Err: err, Configurator: configurator}, nil
+	}
+}
+
+func decodeGetConfiguratorRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := getConfiguratorRequest{ProductID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeGetConfiguratorResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getConfiguratorResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Configurator)
+}
+
+// **************************** SET CONFIGURATOR *************************
+
+type setConfiguratorRequest struct {
+	ProductID string
+	Input     *erp.ConfiguratorInput
+}
+
+type setConfiguratorResponse struct {
+	Configurator *erp.Configurator `json:"configurator"`
+	Err          error             `json:"err"`
+}
+
+func makeSetConfiguratorEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setConfiguratorRequest)
+		configurator, err := s.SetConfigurator(ctx, req.ProductID, req.Input)
+		return setConfiguratorResponse{Err: err, Configurator: configurator}, nil
+	}
+}
+
+func decodeSetConfiguratorRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := setConfiguratorRequest{ProductID: mux.Vars(r)["id"], Input: &erp.ConfiguratorInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeSetConfiguratorResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(setConfiguratorResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Configurator)
+}
+
+// **************************** DELETE CONFIGURATOR *************************
+
+type deleteConfiguratorRequest struct {
+	ProductID string
+}
+
+type deleteConfiguratorResponse struct {
+	Err error `json:"err"`
+}
+
+func makeDeleteConfiguratorEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteConfiguratorRequest)
+		err = s.DeleteConfigurator(ctx, req.ProductID)
+		return deleteConfiguratorResponse{Err: err}, nil
+	}
+}
+
+func decodeDeleteConfiguratorRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := deleteConfiguratorRequest{ProductID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeDeleteConfiguratorResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deleteConfiguratorResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** CONFIGURE PRODUCT *************************
+
+type configureProductRequest struct {
+	ProductID string            `json:"-"`
+	Options   map[string]string `json:"options"`
+	UserID    string            `json:"-"`
+	Market    erp.Market        `json:"-"`
+}
+
+type configureProductResponse struct {
+	Configuration *erp.Configuration `json:"configuration"`
+	Err           error              `json:"err"`
+}
+
+func makeConfigureProductEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(configureProductRequest)
+		conf, err := s.ConfigureProduct(ctx, req.ProductID, req.Options, req.UserID, req.Market)
+		return configureProductResponse{Err: err, Configuration: conf}, nil
+	}
+}
+
+func decodeConfigureProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := configureProductRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	req.ProductID = mux.Vars(r)["id"]
+	var err error
+	if req.UserID, req.Market, err = decodeMarket(r); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func encodeConfigureProductResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(configureProductResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if res.Configuration.Created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	return json.NewEncoder(w).Encode(res.Configuration)
+}
+
+// **************************** GET DELIVERY ZONES *************************
+
+type getDeliveryZonesRequest struct{}
+
+type getDeliveryZonesResponse struct {
+	Zones []*erp.DeliveryZone `json:"zones"`
+	Err   error               `json:"err"`
+}
+
+func makeGetDeliveryZonesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		zones, err := s.GetDeliveryZones(ctx)
+		return getDeliveryZonesResponse{Err: err, Zones: zones}, nil
+	}
+}
+
+func decodeGetDeliveryZonesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return getDeliveryZonesRequest{}, nil
+}
+
+func encodeGetDeliveryZonesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getDeliveryZonesResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Zones)
+}
+
+// **************************** SET DELIVERY ZONE *************************
+
+type setDeliveryZoneRequest struct {
+	ID    string
+	Input *erp.DeliveryZoneInput
+}
+
+type setDeliveryZoneResponse struct {
+	Zone *erp.DeliveryZone `json:"zone"`
+	Err  error             `json:"err"`
+}
+
+func makeSetDeliveryZoneEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setDeliveryZoneRequest)
+		zone, err := s.SetDeliveryZone(ctx, req.ID, req.Input)
+		return setDeliveryZoneResponse{Err: err, Zone: zone}, nil
+	}
+}
+
+func decodeSetDeliveryZoneRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := setDeliveryZoneRequest{ID: mux.Vars(r)["id"], Input: &erp.DeliveryZoneInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeSetDeliveryZoneResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(setDeliveryZoneResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Zone)
+}
+
+// **************************** DELETE DELIVERY ZONE *************************
+
+type deleteDeliveryZoneRequest struct {
+	ID string
+}
+
+type deleteDeliveryZoneResponse struct {
+	Err error `json:"err"`
+}
+
+func makeDeleteDeliveryZoneEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteDeliveryZoneRequest)
+		err = s.DeleteDeliveryZone(ctx, req.ID)
+		return deleteDeliveryZoneResponse{Err: err}, nil
+	}
+}
+
+func decodeDeleteDeliveryZoneRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := deleteDeliveryZoneRequest{ID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeDeleteDeliveryZoneResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deleteDeliveryZoneResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// **************************** GET PICKUP POINTS *************************
+
+type getPickupPointsRequest struct{}
+
+type getPickupPointsResponse struct {
+	Points []*erp.PickupPoint `json:"points"`
+	Err    error              `json:"err"`
+}
+
+func makeGetPickupPointsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		points, err := s.GetPickupPoints(ctx)
+		return getPickupPointsResponse{Err: err, Points: points}, nil
+	}
+}
+
+func decodeGetPickupPointsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	return getPickupPointsRequest{}, nil
+}
+
+func encodeGetPickupPointsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(getPickupPointsResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Points)
+}
+
+// **************************** NEAREST PICKUP POINTS *************************
+
+type nearestPickupPointsRequest struct {
+	Location erp.Location
+	Limit    int
+	RadiusKm float64
+}
+
+type nearestPickupPointsResponse struct {
+	Points []*erp.NearbyPickupPoint `json:"points"`
+	Err    error                    `json:"err"`
+}
+
+func makeNearestPickupPointsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(nearestPickupPointsRequest)
+		points, err := s.NearestPickupPoints(ctx, req.Location, req.Limit, req.RadiusKm)
+		return nearestPickupPointsResponse{Err: err, Points: points}, nil
+	}
+}
+
+func decodeNearestPickupPointsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	req := nearestPickupPointsRequest{}
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil {
+		return nil, erp.ErrBadRequest("invalid lat %q", query.Get("lat"))
+	}
+	lng, err := strconv.ParseFloat(query.Get("lng"), 64)
+	if err != nil {
+		return nil, erp.ErrBadRequest("invalid lng %q", query.Get("lng"))
+	}
+	req.Location = erp.Location{Lat: lat, Lng: lng}
+	if v := query.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, erp.ErrBadRequest("invalid limit %q", v)
+		}
+	}
+	if v := query.Get("radius"); v != "" {
+		if req.RadiusKm, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, erp.ErrBadRequest("invalid radius %q", v)
+		}
+	}
+	return req, nil
+}
+
+func encodeNearestPickupPointsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(nearestPickupPointsResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Points)
+}
+
+// **************************** SET PICKUP POINT *************************
+
+type setPickupPointRequest struct {
+	ID    string
+	Input *erp.PickupPointInput
+}
+
+type setPickupPointResponse struct {
+	Point *erp.PickupPoint `json:"point"`
+	Err   error            `json:"err"`
+}
+
+func makeSetPickupPointEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setPickupPointRequest)
+		point, err := s.SetPickupPoint(ctx, req.ID, req.Input)
+		return setPickupPointResponse{Err: err, Point: point}, nil
+	}
+}
+
+func decodeSetPickupPointRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := setPickupPointRequest{ID: mux.Vars(r)["id"], Input: &erp.PickupPointInput{}}
+	if err := json.NewDecoder(r.Body).Decode(req.Input); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	return req, nil
+}
+
+func encodeSetPickupPointResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(setPickupPointResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(res.Point)
+}
+
+// **************************** DELETE PICKUP POINT *************************
+
+type deletePickupPointRequest struct {
+	ID string
+}
+
+type deletePickupPointResponse struct {
+	Err error `json:"err"`
+}
+
+func makeDeletePickupPointEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deletePickupPointRequest)
+		err = s.DeletePickupPoint(ctx, req.ID)
+		return deletePickupPointResponse{Err: err}, nil
+	}
+}
+
+func decodeDeletePickupPointRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if _, err := checkStaff(r); err != nil {
+		return nil, err
+	}
+	req := deletePickupPointRequest{ID: mux.Vars(r)["id"]}
+	return req, nil
+}
+
+func encodeDeletePickupPointResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(deletePickupPointResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(true)
+}
+
+// decodePage reads the optional page number and size of a list request.
+func decodePage(query url.Values) (page int, limit int, err error) {
+	if v := query.Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil {
+			return 0, 0, erp.ErrBadRequest("invalid page %q", v)
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return 0, 0, erp.ErrBadRequest("invalid limit %q", v)
+		}
+	}
+	return page, limit, nil
+}
+
+// decodeMarket returns the user of a catalog request and the market the request selects.
+// Catalog pages are public, a stale token only loses the market of the user profile.
+func decodeMarket(r *http.Request) (string, erp.Market, error) {
+	userID, isLoggedIn, err := auth.GetUserID(r)
+	if err != nil || !isLoggedIn {
+		userID = ""
+	}
+	market, err := erp.RequestMarket(r)
+	if err != nil {
+		return "", erp.Market{}, erp.ErrBadRequest("%s", err)
+	}
+	return userID, market, nil
+}
+
+type addProductRequest struct {
+	SKU            string `json:"sku"`
+	UserID         string `json:"user_id"`
+	IsLoggedIn     bool   `json:"is_logged_in"`
+	IsTmpUserIDSet bool   `json:"is_tmp_user_id_set"`
+}
+
+type addProductResponse struct {
+	Err                error  `json:"err"`
+	UserID             string `json:"user_id"`
+	SetTmpUserIDCookie bool   `json:"set_tmp_user_id_cookie"`
+}
+
+func decodeAddProductRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := addProductRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, erp.ErrBadRequest("failed to decode JSON request: %v", err)
+	}
+	userID, isLoggedIn, err := auth.GetUserID(r)
+	if err != nil {
+		return nil, err
+	}
+	req.UserID = userID
+	if userID != "" && isLoggedIn {
+		req.IsLoggedIn = true
+	}
+	if _, err := auth.GetTmpUserID(r); err == nil {
+		req.IsTmpUserIDSet = true
+	}
+	return req, nil
+}
+
+func encodeAddProductResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(addProductResponse)
+	if res.Err != nil {
+		encodeError(ctx, res.Err, w)
+		return nil
+	}
+
+	if res.SetTmpUserIDCookie {
+		http.SetCookie(w, &http.Cookie{
+			Path:    "/",
+			Name:    "tmp-user-id",
+			Value:   auth.SignTmpUserID(res.UserID),
 			Expires: time.Now().Add(168 * time.Hour),
 		})
 	}
@@ -352,6 +1864,20 @@ func encodeAddProductResponse(ctx context.Context, w http.ResponseWriter, respon
 	return json.NewEncoder(w).Encode(true)
 }
 
+// ****************** Auth *********************
+
+// checkStaff lets only staff tokens through to the admin endpoints.
+func checkStaff(r *http.Request) (*auth.Claims, error) {
+	claims, err := auth.CheckStaff(r.Header.Get("Authorization"))
+	if err == auth.ErrNotStaff {
+		return nil, erp.ErrForbidden("%s", err)
+	}
+	if err != nil {
+		return nil, erp.ErrUnauthorized("%s", err)
+	}
+	return claims, nil
+}
+
 // ****************** Errors *********************
 
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
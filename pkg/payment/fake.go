@@ -0,0 +1,184 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const FakeProviderName = "fake"
+
+// FakeMode defines how the fake provider answers.
+type FakeMode string
+
+const (
+	FakeModeSucceed FakeMode = "succeed"
+	FakeModeDecline FakeMode = "decline"
+	FakeModeTimeout FakeMode = "timeout"
+)
+
+// FakeConfig is a fake provider configuration.
+type FakeConfig struct {
+	Mode FakeMode
+	// WebhookSecret signs the webhooks, it is required so that nobody can mark orders paid.
+	WebhookSecret string
+	// Timeout is how long the provider hangs in timeout mode.
+	Timeout time.Duration
+}
+
+// Fake is an in-process payment provider for development and end to end tests.
+// The mode can be changed on the fly with SetMode, or for a single payment
+// by passing the mode name as the payment token, e.g. "decline".
+// Transactions are not kept, so capture and refund accept any fake transaction.
+type Fake struct {
+	mu      sync.RWMutex
+	mode    FakeMode
+	secret  []byte
+	timeout time.Duration
+}
+
+// NewFake creates a new fake provider.
+func NewFake(cfg FakeConfig) (*Fake, error) {
+	if cfg.Mode == "" {
+		cfg.Mode = FakeModeSucceed
+	}
+	if !cfg.Mode.isValid() {
+		return nil, fmt.Errorf("unknown fake payment mode %q", cfg.Mode)
+	}
+	if cfg.WebhookSecret == "" {
+		return nil, errors.New("payment webhook secret is required")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	f := &Fake{
+		mode:    cfg.Mode,
+		secret:  []byte(cfg.WebhookSecret),
+		timeout: cfg.Timeout,
+	}
+	return f, nil
+}
+
+func (m FakeMode) isValid() bool {
+	switch m {
+	case FakeModeSucceed:
+	case FakeModeDecline:
+	case FakeModeTimeout:
+	default:
+		return false
+	}
+	return true
+}
+
+// SetMode changes how the provider answers the following requests.
+func (f *Fake) SetMode(mode FakeMode) error {
+	if !mode.isValid() {
+		return fmt.Errorf("unknown fake payment mode %q", mode)
+	}
+	f.mu.Lock()
+	f.mode = mode
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *Fake) Name() string {
+	return FakeProviderName
+}
+
+func (f *Fake) Authorize(ctx context.Context, req *erp.PaymentRequest) (*erp.PaymentResult, error) {
+	if !req.Amount.IsPositive() {
+		return nil, erp.ErrPaymentDeclined
+	}
+	if err := f.answer(ctx, FakeMode(req.Token)); err != nil {
+		return nil, err
+	}
+	result := &erp.PaymentResult{
+		TransactionID: "fake_" + primitive.NewObjectID().Hex(),
+		Status:        erp.PaymentStatusAuthorized,
+	}
+	return result, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, transactionID string, amount erp.Money) (*erp.PaymentResult, error) {
+	if !strings.HasPrefix(transactionID, "fake_") {
+		return nil, fmt.Errorf("unknown transaction %q", transactionID)
+	}
+	if err := f.answer(ctx, ""); err != nil {
+		return nil, err
+	}
+	result := &erp.PaymentResult{
+		TransactionID: transactionID,
+		Status:        erp.PaymentStatusCaptured,
+	}
+	return result, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, transactionID string, amount erp.Money) (*erp.PaymentResult, error) {
+	if !strings.HasPrefix(transactionID, "fake_") {
+		return nil, fmt.Errorf("unknown transaction %q", transactionID)
+	}
+	if err := f.answer(ctx, ""); err != nil {
+		return nil, err
+	}
+	result := &erp.PaymentResult{
+		TransactionID: transactionID,
+		Status:        erp.PaymentStatusRefunded,
+	}
+	return result, nil
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, signature string) (*erp.PaymentEvent, error) {
+	if !hmac.Equal([]byte(f.Sign(payload)), []byte(signature)) {
+		return nil, erp.ErrInvalidWebhookPayload
+	}
+	event := &erp.PaymentEvent{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, erp.ErrInvalidWebhookPayload
+	}
+	if event.OrderID == "" || event.TransactionID == "" {
+		return nil, erp.ErrInvalidWebhookPayload
+	}
+	return event, nil
+}
+
+// Sign returns the signature the provider puts on a webhook payload.
+func (f *Fake) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// answer simulates the provider response in the current mode
+// or in the requested one if it is a valid mode.
+func (f *Fake) answer(ctx context.Context, requested FakeMode) error {
+	f.mu.RLock()
+	mode := f.mode
+	f.mu.RUnlock()
+	if requested.isValid() {
+		mode = requested
+	}
+
+	switch mode {
+	case FakeModeDecline:
+		return erp.ErrPaymentDeclined
+	case FakeModeTimeout:
+		t := time.NewTimer(f.timeout)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
+		return erp.ErrPaymentTimeout
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+// Config is a payment provider configuration.
+type Config struct {
+	Provider      string
+	WebhookSecret string
+	FakeMode      string
+	// DevMode allows the fake provider, it must never be set in production.
+	DevMode bool
+}
+
+// NewProvider creates the payment provider with the given name.
+func NewProvider(cfg Config) (erp.PaymentProvider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, fmt.Errorf("payment provider is not set")
+	case FakeProviderName:
+		if !cfg.DevMode {
+			return nil, fmt.Errorf("payment provider %q is allowed only in dev mode", cfg.Provider)
+		}
+		return NewFake(FakeConfig{
+			Mode:          FakeMode(cfg.FakeMode),
+			WebhookSecret: cfg.WebhookSecret,
+		})
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+}
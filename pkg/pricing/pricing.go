@@ -0,0 +1,205 @@
+package pricing
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+// PriceSource provides the base pricing of SKUs, the price lists with their prices
+// and the categories of SKUs to check coupon restrictions.
+type PriceSource interface {
+	GetPrices(ctx context.Context, skus []string) (map[string]*erp.Pricing, error)
+	GetPriceList(ctx context.Context, id string) (*erp.PriceList, error)
+	GetPriceListPrices(ctx context.Context, id string, skus []string) (map[string]*erp.Pricing, error)
+	GetVariationCategories(ctx context.Context, skus []string) (map[string][]string, error)
+}
+
+// Engine prices carts on the server side, prices sent by clients are never trusted.
+type Engine struct {
+	prices PriceSource
+	now    func() time.Time
+}
+
+// NewEngine creates a new pricing engine.
+func NewEngine(prices PriceSource) *Engine {
+	return &Engine{
+		prices: prices,
+		now:    time.Now,
+	}
+}
+
+// PriceList returns the price list of the market: the list of the currency in the region,
+// or the list of the currency in all the regions. Markets without a list, as well as
+// the zero market, are priced with the base prices, the nil list.
+func (e *Engine) PriceList(ctx context.Context, market erp.Market) (*erp.PriceList, error) {
+	if market.IsZero() {
+		return nil, nil
+	}
+	ids := []string{erp.PriceListID(market.Currency, "")}
+	if market.Region != "" {
+		ids = append([]string{erp.PriceListID(market.Currency, market.Region)}, ids...)
+	}
+	for _, id := range ids {
+		list, err := e.prices.GetPriceList(ctx, id)
+		switch err {
+		case nil:
+			return list, nil
+		case erp.ErrPriceListNotFound:
+		default:
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// Prices returns the pricing of the SKUs in the price list keyed by SKU, see erp.PriceList.Resolve.
+// SKUs without pricing are left out.
+func (e *Engine) Prices(ctx context.Context, skus []string, list *erp.PriceList) (map[string]*erp.Pricing, error) {
+	base, err := e.prices.GetPrices(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return base, nil
+	}
+	listed, err := e.prices.GetPriceListPrices(ctx, list.ID, skus)
+	if err != nil {
+		return nil, err
+	}
+	return list.Resolve(base, listed), nil
+}
+
+// Price resolves the current price of every line in the price list, the sale price while the sale is on,
+// and applies the constant discount of the user to the subtotal.
+// The user may be nil for anonymous carts, the list is nil for the base prices.
+// Lines without a price, or priced in another currency than the list, are priced at zero
+// and listed in PricedCart.Unpriced.
+func (e *Engine) Price(ctx context.Context, products []*erp.CartProduct, user *erp.User, list *erp.PriceList) (*erp.PricedCart, error) {
+	rate := list.Rate()
+	cart := &erp.PricedCart{
+		Currency:     rate.To,
+		Products:     make([]*erp.CartProduct, 0, len(products)),
+		Subtotal:     erp.Money{Currency: rate.To},
+		Discount:     erp.Money{Currency: rate.To},
+		Total:        erp.Money{Currency: rate.To},
+		ExchangeRate: rate,
+	}
+	if len(products) == 0 {
+		return cart, nil
+	}
+
+	skus := make([]string, 0, len(products))
+	for _, p := range products {
+		skus = append(skus, p.SKU)
+	}
+	prices, err := e.Prices(ctx, skus, list)
+	if err != nil {
+		return nil, err
+	}
+
+	now := e.now()
+	for _, p := range products {
+		line := *p
+		line.Price = erp.Money{Currency: cart.Currency}
+		line.RegularPrice = nil
+		pricing, ok := prices[p.SKU]
+		if !ok || !pricing.Price.IsPositive() || pricing.Price.CurrencyOrDefault() != cart.Currency {
+			cart.Unpriced = append(cart.Unpriced, p.SKU)
+		} else {
+			line.Price = pricing.Current(now)
+			if line.Price != pricing.Price {
+				regular := pricing.Price
+				line.RegularPrice = &regular
+			}
+		}
+		line.LineTotal = line.Price.Mul(line.Quantity)
+
+		cart.Products = append(cart.Products, &line)
+		cart.Quantity += line.Quantity
+		cart.Subtotal, err = cart.Subtotal.Add(line.LineTotal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if user != nil && user.ConstDiscount > 0 {
+		percent := math.Min(float64(user.ConstDiscount), 100)
+		cart.Discount = cart.Subtotal.Percent(percent)
+	}
+	cart.Total, err = cart.Subtotal.Sub(cart.Discount)
+	if err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// ApplyCoupon adds the coupon discount to the priced cart.
+// The coupon has to be checked for validity and usage limits beforehand.
+func (e *Engine) ApplyCoupon(ctx context.Context, cart *erp.PricedCart, coupon *erp.Coupon) error {
+	minCartValue := cart.ExchangeRate.Convert(coupon.MinCartValue)
+	if !cart.Subtotal.SameCurrency(minCartValue) || cart.Subtotal.Less(minCartValue) {
+		return erp.ErrCouponMinCartValue
+	}
+
+	eligible := cart.Subtotal
+	if coupon.IsRestricted() {
+		skus := make([]string, 0, len(cart.Products))
+		for _, p := range cart.Products {
+			skus = append(skus, p.SKU)
+		}
+		categories, err := e.prices.GetVariationCategories(ctx, skus)
+		if err != nil {
+			return err
+		}
+		eligible = erp.Money{Currency: cart.Subtotal.Currency}
+		for _, p := range cart.Products {
+			if coupon.AppliesTo(p.SKU, categories[p.SKU]) {
+				eligible, err = eligible.Add(p.LineTotal)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if eligible.IsZero() {
+			return erp.ErrCouponNotApplicable
+		}
+	}
+
+	applied := &erp.AppliedCoupon{
+		Code:     coupon.Code,
+		Type:     coupon.Type,
+		Discount: erp.Money{Currency: cart.Currency},
+	}
+	switch coupon.Type {
+	case erp.CouponTypePercent:
+		applied.Discount = eligible.Percent(math.Min(coupon.Value, 100))
+	case erp.CouponTypeFixed:
+		value := cart.ExchangeRate.Convert(erp.MoneyFromFloat(coupon.Value, erp.DefaultCurrency))
+		if !value.SameCurrency(eligible) {
+			return erp.ErrCouponNotApplicable
+		}
+		applied.Discount = value.Min(eligible)
+	case erp.CouponTypeFreeShipping:
+		applied.FreeShipping = true
+	default:
+		return erp.ErrCouponNotApplicable
+	}
+	// Discounts never make the total negative.
+	applied.Discount = applied.Discount.Min(cart.Total).Max(erp.Money{Currency: cart.Currency})
+
+	discount, err := cart.Discount.Add(applied.Discount)
+	if err != nil {
+		return err
+	}
+	total, err := cart.Subtotal.Sub(discount)
+	if err != nil {
+		return err
+	}
+	cart.Coupon = applied
+	cart.Discount = discount
+	cart.Total = total
+	return nil
+}
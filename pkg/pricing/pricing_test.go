@@ -0,0 +1,271 @@
+package pricing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+// fakePrices is a price source with the base prices, the prices of the USD list and the categories.
+type fakePrices struct {
+	base       map[string]*erp.Pricing
+	listed     map[string]*erp.Pricing
+	categories map[string][]string
+}
+
+func (f *fakePrices) GetPrices(_ context.Context, skus []string) (map[string]*erp.Pricing, error) {
+	return pick(f.base, skus), nil
+}
+
+func (f *fakePrices) GetPriceList(_ context.Context, id string) (*erp.PriceList, error) {
+	if id != usdList.ID {
+		return nil, erp.ErrPriceListNotFound
+	}
+	return usdList, nil
+}
+
+func (f *fakePrices) GetPriceListPrices(_ context.Context, _ string, skus []string) (map[string]*erp.Pricing, error) {
+	return pick(f.listed, skus), nil
+}
+
+func (f *fakePrices) GetVariationCategories(_ context.Context, skus []string) (map[string][]string, error) {
+	categories := map[string][]string{}
+	for _, sku := range skus {
+		categories[sku] = f.categories[sku]
+	}
+	return categories, nil
+}
+
+func pick(prices map[string]*erp.Pricing, skus []string) map[string]*erp.Pricing {
+	picked := map[string]*erp.Pricing{}
+	for _, sku := range skus {
+		if p, ok := prices[sku]; ok {
+			picked[sku] = p
+		}
+	}
+	return picked
+}
+
+var usdList = &erp.PriceList{ID: "usd", Currency: "USD", ExchangeRate: 0.01}
+
+func rub(kopecks int64) erp.Money {
+	return erp.NewMoney(kopecks, erp.DefaultCurrency)
+}
+
+func newTestEngine() *Engine {
+	e := NewEngine(&fakePrices{
+		base: map[string]*erp.Pricing{
+			"poster":  {Price: rub(100000)},
+			"frame":   {Price: rub(50000), Sale: &erp.Sale{SalePrice: rub(40000), SaleEndDate: "2021-02-01 00:00:00"}},
+			"print":   {Price: rub(30000), Sale: &erp.Sale{SalePrice: rub(20000), SaleEndDate: "2020-12-01 00:00:00"}},
+			"free":    {Price: rub(0)},
+			"dollars": {Price: erp.NewMoney(1000, "USD")},
+		},
+		listed: map[string]*erp.Pricing{
+			"poster": {Price: erp.NewMoney(1500, "USD")},
+		},
+		categories: map[string][]string{
+			"poster": {"posters", "wall-art"},
+			"frame":  {"frames"},
+		},
+	})
+	e.now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+	return e
+}
+
+func TestEnginePrice(t *testing.T) {
+	tests := []struct {
+		name         string
+		products     []*erp.CartProduct
+		user         *erp.User
+		list         *erp.PriceList
+		wantPrices   []erp.Money
+		wantUnpriced []string
+		wantSubtotal erp.Money
+		wantDiscount erp.Money
+		wantTotal    erp.Money
+	}{
+		{
+			name:         "base prices",
+			products:     []*erp.CartProduct{{SKU: "poster", Quantity: 2}},
+			wantPrices:   []erp.Money{rub(100000)},
+			wantSubtotal: rub(200000),
+			wantDiscount: rub(0),
+			wantTotal:    rub(200000),
+		},
+		{
+			name:         "sales on and over",
+			products:     []*erp.CartProduct{{SKU: "frame", Quantity: 1}, {SKU: "print", Quantity: 3}},
+			wantPrices:   []erp.Money{rub(40000), rub(30000)},
+			wantSubtotal: rub(130000),
+			wantDiscount: rub(0),
+			wantTotal:    rub(130000),
+		},
+		{
+			name:         "user discount",
+			products:     []*erp.CartProduct{{SKU: "poster", Quantity: 1}, {SKU: "frame", Quantity: 1}},
+			user:         &erp.User{ConstDiscount: 10},
+			wantPrices:   []erp.Money{rub(100000), rub(40000)},
+			wantSubtotal: rub(140000),
+			wantDiscount: rub(14000),
+			wantTotal:    rub(126000),
+		},
+		{
+			name:         "unpriced lines",
+			products:     []*erp.CartProduct{{SKU: "poster", Quantity: 1}, {SKU: "free", Quantity: 1}, {SKU: "missing", Quantity: 1}, {SKU: "dollars", Quantity: 1}},
+			wantPrices:   []erp.Money{rub(100000), rub(0), rub(0), rub(0)},
+			wantUnpriced: []string{"free", "missing", "dollars"},
+			wantSubtotal: rub(100000),
+			wantDiscount: rub(0),
+			wantTotal:    rub(100000),
+		},
+		{
+			name:         "price list",
+			products:     []*erp.CartProduct{{SKU: "poster", Quantity: 1}, {SKU: "frame", Quantity: 2}},
+			list:         usdList,
+			wantPrices:   []erp.Money{erp.NewMoney(1500, "USD"), erp.NewMoney(400, "USD")},
+			wantSubtotal: erp.NewMoney(2300, "USD"),
+			wantDiscount: erp.NewMoney(0, "USD"),
+			wantTotal:    erp.NewMoney(2300, "USD"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart, err := newTestEngine().Price(context.Background(), tt.products, tt.user, tt.list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prices := make([]erp.Money, 0, len(cart.Products))
+			for _, p := range cart.Products {
+				prices = append(prices, p.Price)
+			}
+			if !reflect.DeepEqual(prices, tt.wantPrices) {
+				t.Errorf("prices %v, want %v", prices, tt.wantPrices)
+			}
+			if !reflect.DeepEqual(cart.Unpriced, tt.wantUnpriced) {
+				t.Errorf("unpriced %v, want %v", cart.Unpriced, tt.wantUnpriced)
+			}
+			if cart.Subtotal != tt.wantSubtotal || cart.Discount != tt.wantDiscount || cart.Total != tt.wantTotal {
+				t.Errorf("subtotal %v, discount %v, total %v, want %v, %v, %v",
+					cart.Subtotal, cart.Discount, cart.Total, tt.wantSubtotal, tt.wantDiscount, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestEngineApplyCoupon(t *testing.T) {
+	products := []*erp.CartProduct{{SKU: "poster", Quantity: 1}, {SKU: "frame", Quantity: 1}}
+	tests := []struct {
+		name         string
+		coupon       *erp.Coupon
+		user         *erp.User
+		list         *erp.PriceList
+		wantErr      error
+		wantDiscount erp.Money
+		wantTotal    erp.Money
+		wantFree     bool
+	}{
+		{
+			name:         "percent",
+			coupon:       &erp.Coupon{Code: "TEN", Type: erp.CouponTypePercent, Value: 10},
+			wantDiscount: rub(14000),
+			wantTotal:    rub(126000),
+		},
+		{
+			name:         "percent over 100",
+			coupon:       &erp.Coupon{Code: "ALL", Type: erp.CouponTypePercent, Value: 150},
+			wantDiscount: rub(140000),
+			wantTotal:    rub(0),
+		},
+		{
+			name:         "fixed",
+			coupon:       &erp.Coupon{Code: "300", Type: erp.CouponTypeFixed, Value: 300},
+			wantDiscount: rub(30000),
+			wantTotal:    rub(110000),
+		},
+		{
+			name:         "fixed over the total",
+			coupon:       &erp.Coupon{Code: "5000", Type: erp.CouponTypeFixed, Value: 5000},
+			wantDiscount: rub(140000),
+			wantTotal:    rub(0),
+		},
+		{
+			name:         "fixed with a user discount",
+			coupon:       &erp.Coupon{Code: "5000", Type: erp.CouponTypeFixed, Value: 5000},
+			user:         &erp.User{ConstDiscount: 10},
+			wantDiscount: rub(126000),
+			wantTotal:    rub(0),
+		},
+		{
+			name:         "fixed in a price list",
+			coupon:       &erp.Coupon{Code: "300", Type: erp.CouponTypeFixed, Value: 300},
+			list:         usdList,
+			wantDiscount: erp.NewMoney(300, "USD"),
+			wantTotal:    erp.NewMoney(1600, "USD"),
+		},
+		{
+			name:         "restricted to a category",
+			coupon:       &erp.Coupon{Code: "WALL", Type: erp.CouponTypePercent, Value: 50, Categories: []string{"wall-art"}},
+			wantDiscount: rub(50000),
+			wantTotal:    rub(90000),
+		},
+		{
+			name:         "restricted to a SKU",
+			coupon:       &erp.Coupon{Code: "FRAME", Type: erp.CouponTypeFixed, Value: 1000, SKUs: []string{"frame"}},
+			wantDiscount: rub(40000),
+			wantTotal:    rub(100000),
+		},
+		{
+			name:    "restricted to other products",
+			coupon:  &erp.Coupon{Code: "MUGS", Type: erp.CouponTypePercent, Value: 10, Categories: []string{"mugs"}},
+			wantErr: erp.ErrCouponNotApplicable,
+		},
+		{
+			name:    "below the min cart value",
+			coupon:  &erp.Coupon{Code: "BIG", Type: erp.CouponTypePercent, Value: 10, MinCartValue: rub(200000)},
+			wantErr: erp.ErrCouponMinCartValue,
+		},
+		{
+			name:         "free shipping",
+			coupon:       &erp.Coupon{Code: "SHIP", Type: erp.CouponTypeFreeShipping},
+			wantDiscount: rub(0),
+			wantTotal:    rub(140000),
+			wantFree:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine()
+			cart, err := e.Price(context.Background(), products, tt.user, tt.list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			userDiscount := cart.Discount
+			err = e.ApplyCoupon(context.Background(), cart, tt.coupon)
+			if err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if cart.Coupon != nil {
+					t.Errorf("coupon %+v applied", cart.Coupon)
+				}
+				return
+			}
+			if cart.Coupon == nil || cart.Coupon.Code != tt.coupon.Code {
+				t.Fatalf("coupon %+v, want %s", cart.Coupon, tt.coupon.Code)
+			}
+			if discount, _ := cart.Discount.Sub(userDiscount); cart.Coupon.Discount != discount {
+				t.Errorf("coupon discount %v, cart discount %v", cart.Coupon.Discount, discount)
+			}
+			if cart.Coupon.Discount != tt.wantDiscount || cart.Total != tt.wantTotal {
+				t.Errorf("discount %v, total %v, want %v, %v", cart.Coupon.Discount, cart.Total, tt.wantDiscount, tt.wantTotal)
+			}
+			if cart.Coupon.FreeShipping != tt.wantFree {
+				t.Errorf("free shipping %v, want %v", cart.Coupon.FreeShipping, tt.wantFree)
+			}
+		})
+	}
+}
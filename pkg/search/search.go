@@ -0,0 +1,258 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+// Field weights, a match in the name counts more than one in the description.
+const (
+	nameWeight        = 3.0
+	attributeWeight   = 2.0
+	descriptionWeight = 1.0
+)
+
+// Match weights of a query word by how it matches an indexed word.
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.8
+	stemMatch   = 0.7
+	typoMatch   = 0.6
+)
+
+// Source provides the documents to index.
+type Source interface {
+	GetSearchDocuments(ctx context.Context) ([]*erp.SearchDocument, error)
+}
+
+// Index is an in-memory full-text index of the catalog.
+// The catalog is small enough to be kept in memory and rebuilt from scratch,
+// so no external search cluster is needed.
+type Index struct {
+	source Source
+
+	mu       sync.RWMutex
+	docs     []*erp.SearchDocument
+	postings map[string]map[int]float64
+	terms    []string
+}
+
+// New creates an empty index, call Refresh to fill it.
+func New(source Source) *Index {
+	return &Index{
+		source:   source,
+		postings: map[string]map[int]float64{},
+	}
+}
+
+// Refresh rebuilds the index from the source.
+// Searches keep using the previous index until the new one is ready.
+func (i *Index) Refresh(ctx context.Context) error {
+	docs, err := i.source.GetSearchDocuments(ctx)
+	if err != nil {
+		return err
+	}
+
+	postings := map[string]map[int]float64{}
+	add := func(doc int, text string, weight float64) {
+		for _, term := range tokenize(text) {
+			if postings[term] == nil {
+				postings[term] = map[int]float64{}
+			}
+			postings[term][doc] += weight
+		}
+	}
+	for n, doc := range docs {
+		add(n, doc.Name, nameWeight)
+		for _, a := range doc.Attributes {
+			add(n, a, attributeWeight)
+		}
+		for _, d := range doc.Description {
+			add(n, d, descriptionWeight)
+		}
+	}
+	terms := make([]string, 0, len(postings))
+	for term := range postings {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	i.mu.Lock()
+	i.docs = docs
+	i.postings = postings
+	i.terms = terms
+	i.mu.Unlock()
+	return nil
+}
+
+// Search returns the documents matching every word of the query ordered by relevance,
+// starting from offset, and the total number of matching documents.
+// Words match indexed words exactly, as a prefix, or with a typo or two.
+func (i *Index) Search(query string, offset int, limit int) ([]*erp.SearchDocument, int) {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return nil, 0
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var scores map[int]float64
+	for _, word := range words {
+		wordScores := i.lookup(word)
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for doc, score := range scores {
+			if s, ok := wordScores[doc]; ok {
+				scores[doc] = score + s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	hits := make([]int, 0, len(scores))
+	for doc := range scores {
+		hits = append(hits, doc)
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if scores[hits[a]] != scores[hits[b]] {
+			return scores[hits[a]] > scores[hits[b]]
+		}
+		return i.docs[hits[a]].Name < i.docs[hits[b]].Name
+	})
+
+	total := len(hits)
+	if offset >= total {
+		return nil, total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	result := make([]*erp.SearchDocument, 0, end-offset)
+	for _, doc := range hits[offset:end] {
+		result = append(result, i.docs[doc])
+	}
+	return result, total
+}
+
+// lookup scores documents for a single query word.
+// A document gets the score of the best matching term.
+func (i *Index) lookup(word string) map[int]float64 {
+	scores := map[int]float64{}
+	for _, term := range i.terms {
+		match := matchWeight(word, term)
+		if match == 0 {
+			continue
+		}
+		docs := i.postings[term]
+		idf := math.Log(1 + float64(len(i.docs))/float64(len(docs)))
+		for doc, tf := range docs {
+			if score := match * idf * tf; score > scores[doc] {
+				scores[doc] = score
+			}
+		}
+	}
+	return scores
+}
+
+func matchWeight(word string, term string) float64 {
+	if word == term {
+		return exactMatch
+	}
+	wordLen := utf8.RuneCountInString(word)
+	termLen := utf8.RuneCountInString(term)
+	// Unfinished words while typing.
+	if wordLen >= 3 && strings.HasPrefix(term, word) {
+		return prefixMatch
+	}
+	// Inflected words like "постеров" for "постер".
+	if termLen >= 4 && wordLen-termLen <= 3 && strings.HasPrefix(word, term) {
+		return stemMatch
+	}
+	max := maxTypos(wordLen)
+	if max == 0 {
+		return 0
+	}
+	if d := distance(word, term, max); d <= max {
+		return typoMatch / float64(d)
+	}
+	return 0
+}
+
+// maxTypos returns how many typos are tolerated in a word of the given length.
+func maxTypos(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	}
+	return 2
+}
+
+// distance returns the Damerau-Levenshtein distance (optimal string alignment)
+// between a and b, or max+1 if it is greater than max.
+func distance(a string, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// tokenize splits the text into lower case words, "ё" is folded into "е".
+func tokenize(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
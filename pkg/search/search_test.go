@@ -0,0 +1,88 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+type fakeSource []*erp.SearchDocument
+
+func (f fakeSource) GetSearchDocuments(_ context.Context) ([]*erp.SearchDocument, error) {
+	return f, nil
+}
+
+func newTestIndex(t *testing.T) *Index {
+	i := New(fakeSource{
+		{ID: "1", Name: "Постер Ёжик в тумане", Attributes: []string{"A3", "матовая бумага"}},
+		{ID: "2", Name: "Постер Москва", Description: []string{"Ночная Москва, вид на Кремль"}},
+		{ID: "3", Name: "Рамка деревянная", Attributes: []string{"A3"}, Description: []string{"Подходит для постеров"}},
+		{ID: "4", Name: "Frame black", Attributes: []string{"wood"}},
+	})
+	if err := i.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+func TestIndexSearch(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		offset  int
+		limit   int
+		want    []string
+		wantTot int
+	}{
+		{"name first", "постер", 0, 10, []string{"1", "2", "3"}, 3},
+		{"every word matches", "постер москва", 0, 10, []string{"2"}, 1},
+		{"case and ё folding", "ЕЖИК", 0, 10, []string{"1"}, 1},
+		{"prefix while typing", "рам", 0, 10, []string{"3"}, 1},
+		{"inflected word", "постеров", 0, 10, []string{"1", "2", "3"}, 3},
+		{"one typo", "москвв", 0, 10, []string{"2"}, 1},
+		{"swapped letters", "farme", 0, 10, []string{"4"}, 1},
+		{"two typos in a long word", "деревяная", 0, 10, []string{"3"}, 1},
+		{"typo in a short word", "woof", 0, 10, []string{"4"}, 1},
+		{"no typos in words under four letters", "wod", 0, 10, nil, 0},
+		{"attribute", "a3", 0, 10, []string{"1", "3"}, 2},
+		{"page", "постер", 1, 1, []string{"2"}, 3},
+		{"past the end", "постер", 5, 10, nil, 3},
+		{"no match", "кружка", 0, 10, nil, 0},
+		{"empty query", " - ", 0, 10, nil, 0},
+	}
+	i := newTestIndex(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, total := i.Search(tt.query, tt.offset, tt.limit)
+			var ids []string
+			for _, d := range docs {
+				ids = append(ids, d.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) || total != tt.wantTot {
+				t.Errorf("got %v of %d, want %v of %d", ids, total, tt.want, tt.wantTot)
+			}
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"постер", "постер", 1, 0},
+		{"постер", "постр", 1, 1},
+		{"постер", "потсер", 1, 1},
+		{"постер", "пастир", 2, 2},
+		{"постер", "пастир", 1, 2},
+		{"постер", "пост", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("distance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
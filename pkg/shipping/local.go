@@ -0,0 +1,288 @@
+package shipping
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+const LocalCarrierName = "local"
+
+// RateType defines how a rate table prices parcels.
+type RateType string
+
+const (
+	// RateTypeFlat charges the same price for every parcel.
+	RateTypeFlat RateType = "flat"
+	// RateTypeWeight charges the price of the weight band of the parcel.
+	RateTypeWeight RateType = "weight"
+	// RateTypeZone charges the price of the zone of the destination, flat or by weight bands.
+	RateTypeZone RateType = "zone"
+)
+
+// RateTable is a delivery service of the local carrier, prices are in erp.DefaultCurrency.
+// Weights are chargeable weights in kilograms, see erp.Parcel.ChargeableWeight.
+type RateTable struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Type RateType `json:"type"`
+	// Countries limits the service to the destination countries, all of them when empty.
+	Countries []string `json:"countries"`
+	// DeliveryZones limits the service to the destinations in the delivery zones, all of them when empty.
+	DeliveryZones     []string `json:"delivery_zones"`
+	VolumetricDivisor float64  `json:"volumetric_divisor"`
+	// MaxWeight is the heaviest chargeable weight the service takes, no limit when zero.
+	MaxWeight float64       `json:"max_weight"`
+	Price     erp.Money     `json:"price"`
+	Bands     []*WeightBand `json:"bands"`
+	Zones     []*ZoneRate   `json:"zones"`
+	MinDays   int           `json:"min_days"`
+	MaxDays   int           `json:"max_days"`
+}
+
+// WeightBand is the price of parcels up to the weight.
+type WeightBand struct {
+	UpTo  float64   `json:"up_to"`
+	Price erp.Money `json:"price"`
+}
+
+// ZoneRate is the price of a delivery zone. Destinations are matched by the delivery zone
+// they are in, see erp.DeliveryZone, or by their country and postcode prefix. The zone is priced by weight bands when it has them.
+type ZoneRate struct {
+	Zone      string        `json:"zone"`
+	Countries []string      `json:"countries"`
+	Postcodes []string      `json:"postcodes"`
+	Price     erp.Money     `json:"price"`
+	Bands     []*WeightBand `json:"bands"`
+	MinDays   int           `json:"min_days"`
+	MaxDays   int           `json:"max_days"`
+}
+
+// DefaultRateTables are the rate tables of the local carrier used without a rates file.
+func DefaultRateTables() []*RateTable {
+	return []*RateTable{
+		{
+			ID:        "pickup",
+			Name:      "Самовывоз из студии",
+			Type:      RateTypeFlat,
+			Countries: []string{"RU"},
+			Price:     erp.Money{Currency: erp.DefaultCurrency},
+			MinDays:   1,
+			MaxDays:   2,
+		},
+		{
+			ID:                "courier",
+			Name:              "Курьер",
+			Type:              RateTypeWeight,
+			Countries:         []string{"RU"},
+			VolumetricDivisor: 5000,
+			Bands: []*WeightBand{
+				{UpTo: 1, Price: erp.NewMoney(30000, erp.DefaultCurrency)},
+				{UpTo: 5, Price: erp.NewMoney(45000, erp.DefaultCurrency)},
+				{UpTo: 15, Price: erp.NewMoney(70000, erp.DefaultCurrency)},
+			},
+			MinDays: 1,
+			MaxDays: 3,
+		},
+	}
+}
+
+// Local is a carrier pricing parcels with rate tables.
+type Local struct {
+	name   string
+	tables []*RateTable
+}
+
+// NewLocal creates a local carrier with the rate tables.
+func NewLocal(name string, tables []*RateTable) (*Local, error) {
+	ids := map[string]bool{}
+	for i, t := range tables {
+		if t == nil {
+			return nil, fmt.Errorf("rate table %d is empty", i)
+		}
+		if t.ID == "" || ids[t.ID] {
+			return nil, fmt.Errorf("rate table %d: id is empty or duplicated", i)
+		}
+		ids[t.ID] = true
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("rate table %s: %v", t.ID, err)
+		}
+	}
+	return &Local{name: name, tables: tables}, nil
+}
+
+func (l *Local) Name() string {
+	return l.name
+}
+
+// Quote returns an option for every rate table taking the parcel to the destination.
+func (l *Local) Quote(ctx context.Context, parcel *erp.Parcel, destination *erp.ShippingDestination) ([]*erp.DeliveryOption, error) {
+	var options []*erp.DeliveryOption
+	for _, t := range l.tables {
+		if !matchCountry(t.Countries, destination.Country) || !matchZone(t.DeliveryZones, destination.Zone) {
+			continue
+		}
+		weight := parcel.ChargeableWeight(t.VolumetricDivisor)
+		if t.MaxWeight > 0 && weight > t.MaxWeight {
+			continue
+		}
+		option := &erp.DeliveryOption{
+			ID:               l.name + "/" + t.ID,
+			Carrier:          l.name,
+			Name:             t.Name,
+			ChargeableWeight: weight,
+			MinDays:          t.MinDays,
+			MaxDays:          t.MaxDays,
+		}
+		var ok bool
+		switch t.Type {
+		case RateTypeFlat:
+			option.Price, ok = t.Price, true
+		case RateTypeWeight:
+			option.Price, ok = bandPrice(t.Bands, weight)
+		case RateTypeZone:
+			zone := t.zone(destination)
+			if zone == nil {
+				continue
+			}
+			option.Zone = zone.Zone
+			if len(zone.Bands) > 0 {
+				option.Price, ok = bandPrice(zone.Bands, weight)
+			} else {
+				option.Price, ok = zone.Price, true
+			}
+			if zone.MaxDays > 0 {
+				option.MinDays, option.MaxDays = zone.MinDays, zone.MaxDays
+			}
+		}
+		if ok {
+			options = append(options, option)
+		}
+	}
+	return options, nil
+}
+
+func (t *RateTable) validate() error {
+	if t.MinDays < 0 || t.MaxDays < t.MinDays {
+		return fmt.Errorf("invalid delivery days %d-%d", t.MinDays, t.MaxDays)
+	}
+	switch t.Type {
+	case RateTypeFlat:
+		return validatePrice(t.Price)
+	case RateTypeWeight:
+		return validateBands(t.Bands)
+	case RateTypeZone:
+		if len(t.Zones) == 0 {
+			return fmt.Errorf("zones are required")
+		}
+		for _, z := range t.Zones {
+			if z == nil || z.Zone == "" {
+				return fmt.Errorf("zone name is required")
+			}
+			if z.MinDays < 0 || z.MaxDays < z.MinDays {
+				return fmt.Errorf("zone %s: invalid delivery days %d-%d", z.Zone, z.MinDays, z.MaxDays)
+			}
+			var err error
+			if len(z.Bands) > 0 {
+				err = validateBands(z.Bands)
+			} else {
+				err = validatePrice(z.Price)
+			}
+			if err != nil {
+				return fmt.Errorf("zone %s: %v", z.Zone, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown rate type %q", t.Type)
+}
+
+// zone returns the zone rate of the destination, the zone the destination is in first.
+func (t *RateTable) zone(destination *erp.ShippingDestination) *ZoneRate {
+	if destination.Zone != "" {
+		for _, z := range t.Zones {
+			if z.Zone == destination.Zone {
+				return z
+			}
+		}
+	}
+	for _, z := range t.Zones {
+		if matchCountry(z.Countries, destination.Country) && matchPostcode(z.Postcodes, destination.Postcode) {
+			return z
+		}
+	}
+	return nil
+}
+
+func validatePrice(price erp.Money) error {
+	if price.CurrencyOrDefault() != erp.DefaultCurrency {
+		return fmt.Errorf("price must be in %s", erp.DefaultCurrency)
+	}
+	if price.Amount < 0 {
+		return fmt.Errorf("price must not be negative")
+	}
+	return nil
+}
+
+func validateBands(bands []*WeightBand) error {
+	if len(bands) == 0 {
+		return fmt.Errorf("weight bands are required")
+	}
+	for i, b := range bands {
+		if b == nil || b.UpTo <= 0 || (i > 0 && b.UpTo <= bands[i-1].UpTo) {
+			return fmt.Errorf("weight bands must have ascending positive weights")
+		}
+		if err := validatePrice(b.Price); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bandPrice returns the price of the first band the weight fits in.
+func bandPrice(bands []*WeightBand, weight float64) (erp.Money, bool) {
+	for _, b := range bands {
+		if weight <= b.UpTo {
+			return b.Price, true
+		}
+	}
+	return erp.Money{}, false
+}
+
+func matchCountry(countries []string, country string) bool {
+	if len(countries) == 0 {
+		return true
+	}
+	for _, c := range countries {
+		if c == "*" || strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchZone(zones []string, zone string) bool {
+	if len(zones) == 0 {
+		return true
+	}
+	for _, z := range zones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPostcode(prefixes []string, postcode string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(postcode, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package shipping
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+func rub(rubles int64) erp.Money {
+	return erp.NewMoney(rubles*100, erp.DefaultCurrency)
+}
+
+func TestLocalQuote(t *testing.T) {
+	local, err := NewLocal("local", []*RateTable{
+		{
+			ID:                "courier",
+			Type:              RateTypeWeight,
+			Countries:         []string{"RU"},
+			VolumetricDivisor: 5000,
+			Bands: []*WeightBand{
+				{UpTo: 1, Price: rub(300)},
+				{UpTo: 5, Price: rub(450)},
+				{UpTo: 15, Price: rub(700)},
+			},
+			MinDays: 1,
+			MaxDays: 3,
+		},
+		{
+			ID:        "zones",
+			Type:      RateTypeZone,
+			MaxWeight: 10,
+			Zones: []*ZoneRate{
+				{Zone: "center", Countries: []string{"RU"}, Postcodes: []string{"10"}, Price: rub(200), MinDays: 0, MaxDays: 1},
+				{Zone: "spb", Countries: []string{"RU"}, Postcodes: []string{"19"}, Bands: []*WeightBand{
+					{UpTo: 2, Price: rub(500)},
+					{UpTo: 10, Price: rub(900)},
+				}},
+			},
+			MinDays: 2,
+			MaxDays: 5,
+		},
+		{
+			ID:            "express",
+			Type:          RateTypeFlat,
+			DeliveryZones: []string{"center"},
+			Price:         rub(1000),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		parcel      erp.Parcel
+		destination erp.ShippingDestination
+		want        map[string]erp.Money
+	}{
+		{
+			name:        "first weight band",
+			parcel:      erp.Parcel{Weight: 0.5},
+			destination: erp.ShippingDestination{Country: "RU", Postcode: "300000"},
+			want:        map[string]erp.Money{"local/courier": rub(300)},
+		},
+		{
+			name:        "volumetric weight band",
+			parcel:      erp.Parcel{Weight: 0.5, Length: 40, Width: 30, Height: 30},
+			destination: erp.ShippingDestination{Country: "RU", Postcode: "300000"},
+			want:        map[string]erp.Money{"local/courier": rub(700)},
+		},
+		{
+			name:        "delivery zone before postcode",
+			parcel:      erp.Parcel{Weight: 3},
+			destination: erp.ShippingDestination{Country: "RU", Postcode: "190000", Zone: "center"},
+			want: map[string]erp.Money{
+				"local/courier": rub(450),
+				"local/zones":   rub(200),
+				"local/express": rub(1000),
+			},
+		},
+		{
+			name:        "postcode zone by weight",
+			parcel:      erp.Parcel{Weight: 3},
+			destination: erp.ShippingDestination{Country: "RU", Postcode: "190000"},
+			want: map[string]erp.Money{
+				"local/courier": rub(450),
+				"local/zones":   rub(900),
+			},
+		},
+		{
+			name:        "over the max weight",
+			parcel:      erp.Parcel{Weight: 12},
+			destination: erp.ShippingDestination{Country: "RU", Postcode: "101000", Zone: "center"},
+			want: map[string]erp.Money{
+				"local/courier": rub(700),
+				"local/express": rub(1000),
+			},
+		},
+		{
+			name:        "heavier than every band",
+			parcel:      erp.Parcel{Weight: 20},
+			destination: erp.ShippingDestination{Country: "RU", Postcode: "190000"},
+			want:        map[string]erp.Money{},
+		},
+		{
+			name:        "other country",
+			parcel:      erp.Parcel{Weight: 1},
+			destination: erp.ShippingDestination{Country: "DE", Postcode: "10115"},
+			want:        map[string]erp.Money{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := local.Quote(context.Background(), &tt.parcel, &tt.destination)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]erp.Money{}
+			for _, o := range options {
+				got[o.ID] = o.Price
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalQuoteZoneDays(t *testing.T) {
+	local, err := NewLocal("local", []*RateTable{{
+		ID:      "zones",
+		Type:    RateTypeZone,
+		Zones:   []*ZoneRate{{Zone: "center", Price: rub(200), MinDays: 0, MaxDays: 1}, {Zone: "region", Price: rub(400)}},
+		MinDays: 2,
+		MaxDays: 5,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		zone             string
+		minDays, maxDays int
+	}{
+		{"center", 0, 1},
+		{"region", 2, 5},
+	}
+	for _, tt := range tests {
+		options, err := local.Quote(context.Background(), &erp.Parcel{Weight: 1}, &erp.ShippingDestination{Country: "RU", Zone: tt.zone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(options) != 1 {
+			t.Fatalf("%s: got %d options, want 1", tt.zone, len(options))
+		}
+		if o := options[0]; o.Zone != tt.zone || o.MinDays != tt.minDays || o.MaxDays != tt.maxDays {
+			t.Errorf("%s: got zone %s, %d-%d days, want %d-%d", tt.zone, o.Zone, o.MinDays, o.MaxDays, tt.minDays, tt.maxDays)
+		}
+	}
+}
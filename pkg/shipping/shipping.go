@@ -0,0 +1,95 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+)
+
+// Config is a shipping configuration.
+type Config struct {
+	// RatesFile is a JSON file with the rate tables of the local carrier, see deploy/shipping/rates.json.
+	// DefaultRateTables are used when it is empty.
+	RatesFile string
+}
+
+// NewCarriers creates the carriers of the configuration.
+func NewCarriers(cfg Config) ([]erp.Carrier, error) {
+	tables := DefaultRateTables()
+	if cfg.RatesFile != "" {
+		data, err := ioutil.ReadFile(cfg.RatesFile)
+		if err != nil {
+			return nil, err
+		}
+		tables = nil
+		if err := json.Unmarshal(data, &tables); err != nil {
+			return nil, fmt.Errorf("failed to decode rate tables %s: %v", cfg.RatesFile, err)
+		}
+	}
+	local, err := NewLocal(LocalCarrierName, tables)
+	if err != nil {
+		return nil, err
+	}
+	return []erp.Carrier{local}, nil
+}
+
+// Calculator quotes the delivery of carts with all the carriers.
+type Calculator struct {
+	carriers []erp.Carrier
+	now      func() time.Time
+}
+
+// NewCalculator creates a new shipping calculator.
+func NewCalculator(carriers []erp.Carrier) *Calculator {
+	return &Calculator{
+		carriers: carriers,
+		now:      time.Now,
+	}
+}
+
+// Quote returns the delivery options of the cart packed in the parcel to the destination, cheapest first.
+// Prices are converted to the cart currency at the cart exchange rate,
+// a free shipping coupon makes every option free. Options priced in a currency that can't be
+// converted to the cart currency are left out, the order couldn't be charged for them.
+func (c *Calculator) Quote(ctx context.Context, cart *erp.PricedCart, parcel *erp.Parcel, destination *erp.ShippingDestination) (*erp.DeliveryQuote, error) {
+	quote := &erp.DeliveryQuote{
+		Destination: destination,
+		Parcel:      parcel,
+		Currency:    cart.Currency,
+		Options:     []*erp.DeliveryOption{},
+	}
+	now := c.now()
+	freeShipping := cart.Coupon != nil && cart.Coupon.FreeShipping
+	for _, carrier := range c.carriers {
+		options, err := carrier.Quote(ctx, parcel, destination)
+		if err != nil {
+			return nil, fmt.Errorf("carrier %s: %v", carrier.Name(), err)
+		}
+		for _, o := range options {
+			o.Price = cart.ExchangeRate.Convert(o.Price)
+			if !o.Price.IsZero() && o.Price.CurrencyOrDefault() != cart.Currency {
+				continue
+			}
+			if freeShipping {
+				price := o.Price
+				o.RegularPrice = &price
+				o.Price = erp.Money{Currency: cart.Currency}
+			}
+			o.Estimate(now)
+			quote.Options = append(quote.Options, o)
+		}
+	}
+	sort.SliceStable(quote.Options, func(i, j int) bool {
+		a, b := quote.Options[i], quote.Options[j]
+		if c, err := a.Price.Cmp(b.Price); err == nil && c != 0 {
+			return c < 0
+		}
+		return a.MaxDays < b.MaxDays
+	})
+	return quote, nil
+}
@@ -0,0 +1,140 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetAddresses returns the address book of the user, the default address first.
+func (s *Storage) GetAddresses(ctx context.Context, userID string) ([]*erp.Address, error) {
+	opts := options.Find().SetSort(bson.D{{"default", -1}, {"created_on", 1}, {"_id", 1}})
+	cur, err := s.db.Collection("addresses").Find(ctx, bson.D{{"user_id", userID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	addresses := []*erp.Address{}
+	for cur.Next(ctx) {
+		address := &erp.Address{}
+		if err := cur.Decode(address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GetAddress returns the address of the user, addresses of other users are not found.
+func (s *Storage) GetAddress(ctx context.Context, userID string, id string) (*erp.Address, error) {
+	address := &erp.Address{}
+	err := s.db.Collection("addresses").FindOne(ctx, bson.D{{"_id", id}, {"user_id", userID}}).Decode(address)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrAddressNotFound
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+// CreateAddress adds the address to the address book of its user.
+// The first address of the user becomes the default one.
+func (s *Storage) CreateAddress(ctx context.Context, address *erp.Address) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		count, err := s.db.Collection("addresses").CountDocuments(sc, bson.D{{"user_id", address.UserID}})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			address.Default = true
+		}
+		if address.Default {
+			if err := s.unsetDefaultAddress(sc, address.UserID); err != nil {
+				return err
+			}
+		}
+		_, err = s.db.Collection("addresses").InsertOne(sc, address)
+		return err
+	})
+}
+
+// UpdateAddress replaces the address, the creation time is kept.
+// The address stays the default one if it was.
+func (s *Storage) UpdateAddress(ctx context.Context, address *erp.Address) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		current, err := s.GetAddress(sc, address.UserID, address.ID)
+		if err != nil {
+			return err
+		}
+		address.CreatedOn = current.CreatedOn
+		if current.Default {
+			address.Default = true
+		} else if address.Default {
+			if err := s.unsetDefaultAddress(sc, address.UserID); err != nil {
+				return err
+			}
+		}
+		filter := bson.D{{"_id", address.ID}, {"user_id", address.UserID}}
+		_, err = s.db.Collection("addresses").ReplaceOne(sc, filter, address)
+		return err
+	})
+}
+
+// SetDefaultAddress makes the address the default one of its user.
+func (s *Storage) SetDefaultAddress(ctx context.Context, userID string, id string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := s.GetAddress(sc, userID, id); err != nil {
+			return err
+		}
+		if err := s.unsetDefaultAddress(sc, userID); err != nil {
+			return err
+		}
+		filter := bson.D{{"_id", id}, {"user_id", userID}}
+		_, err := s.db.Collection("addresses").UpdateOne(sc, filter, bson.D{{"$set", bson.D{{"default", true}}}})
+		return err
+	})
+}
+
+// DeleteAddress deletes the address, when it is the default one
+// the most recently created address of the user becomes the default.
+func (s *Storage) DeleteAddress(ctx context.Context, userID string, id string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		address, err := s.GetAddress(sc, userID, id)
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Collection("addresses").DeleteOne(sc, bson.D{{"_id", id}, {"user_id", userID}})
+		if err != nil {
+			return err
+		}
+		if !address.Default {
+			return nil
+		}
+		next := &erp.Address{}
+		opts := options.FindOne().SetSort(bson.D{{"created_on", -1}, {"_id", -1}})
+		err = s.db.Collection("addresses").FindOne(sc, bson.D{{"user_id", userID}}, opts).Decode(next)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil
+			}
+			return err
+		}
+		filter := bson.D{{"_id", next.ID}}
+		_, err = s.db.Collection("addresses").UpdateOne(sc, filter, bson.D{{"$set", bson.D{{"default", true}}}})
+		return err
+	})
+}
+
+func (s *Storage) unsetDefaultAddress(ctx context.Context, userID string) error {
+	filter := bson.D{{"user_id", userID}, {"default", true}}
+	_, err := s.db.Collection("addresses").UpdateMany(ctx, filter, bson.D{{"$set", bson.D{{"default", false}}}})
+	return err
+}
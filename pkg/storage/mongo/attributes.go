@@ -0,0 +1,253 @@
+package mongo
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetAttributes returns the attribute dictionary ordered by id, i.e. by attribute and value.
+func (s *Storage) GetAttributes(ctx context.Context) ([]*erp.Attribute, error) {
+	opts := options.Find().SetSort(bson.D{{"_id", 1}})
+	cur, err := s.db.Collection("attributes").Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	attributes := []*erp.Attribute{}
+	for cur.Next(ctx) {
+		attribute := &erp.Attribute{}
+		if err := cur.Decode(attribute); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// CreateAttribute adds a value to the attribute dictionary.
+func (s *Storage) CreateAttribute(ctx context.Context, attribute *erp.Attribute) error {
+	_, err := s.db.Collection("attributes").InsertOne(ctx, attribute)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return erp.ErrDuplicateKeyInStorage
+		}
+		return err
+	}
+	return nil
+}
+
+// AddAttribute adds attribute values to a product or sets an attribute value of a variation.
+// The attribute has to be in the dictionary, the dictionary counts of the values are updated along.
+func (s *Storage) AddAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		name, err := s.attributeName(sc, edit.Name)
+		if err != nil {
+			return err
+		}
+		if edit.SKU != "" {
+			return s.setVariationAttribute(sc, edit.SKU, name, edit.Values[0])
+		}
+
+		attrs, err := s.productAttributes(sc, edit.ProductID)
+		if err != nil {
+			return err
+		}
+		attrs, added := erp.AddAttributeValues(attrs, name, edit.Values)
+		if len(added) == 0 {
+			return nil
+		}
+		if err := s.setProductAttributes(sc, edit.ProductID, attrs); err != nil {
+			return err
+		}
+		return s.countAttribute(sc, name, added, 1)
+	})
+}
+
+// RemoveAttribute removes attribute values of a product, all of them when none are given,
+// or removes an attribute of a variation.
+func (s *Storage) RemoveAttribute(ctx context.Context, edit *erp.AttributeEdit) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		name, err := s.attributeName(sc, edit.Name)
+		if err != nil {
+			return err
+		}
+		if edit.SKU != "" {
+			return s.removeVariationAttribute(sc, edit.SKU, name, edit.Values)
+		}
+
+		attrs, err := s.productAttributes(sc, edit.ProductID)
+		if err != nil {
+			return err
+		}
+		attrs, removed := erp.RemoveAttributeValues(attrs, name, edit.Values)
+		if len(removed) == 0 {
+			return nil
+		}
+		if err := s.setProductAttributes(sc, edit.ProductID, attrs); err != nil {
+			return err
+		}
+		return s.countAttribute(sc, name, removed, -1)
+	})
+}
+
+// attributeName returns the dictionary name of the attribute, the name is matched by its key.
+func (s *Storage) attributeName(ctx context.Context, name string) (string, error) {
+	filter := bson.D{{"_id", primitive.Regex{Pattern: "^" + regexp.QuoteMeta(erp.AttributeID(name, ""))}}}
+	attribute := &erp.Attribute{}
+	err := s.db.Collection("attributes").FindOne(ctx, filter).Decode(attribute)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", erp.ErrAttributeUnknown
+		}
+		return "", err
+	}
+	return attribute.Name, nil
+}
+
+// countAttribute adds delta to the dictionary counts of the values.
+// Values missing from the dictionary are added to it.
+func (s *Storage) countAttribute(ctx context.Context, name string, values []string, delta int) error {
+	for _, value := range values {
+		update := bson.D{
+			{"$inc", bson.D{{"count", delta}}},
+			{"$setOnInsert", bson.D{{"name", name}, {"value", value}}},
+		}
+		opts := options.Update().SetUpsert(delta > 0)
+		_, err := s.db.Collection("attributes").UpdateOne(ctx, bson.D{{"_id", erp.AttributeID(name, value)}}, update, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) productAttributes(ctx context.Context, productID string) ([]*erp.ProductAttribute, error) {
+	product := struct {
+		Attributes []*erp.ProductAttribute `bson:"attributes"`
+	}{}
+	opts := options.FindOne().SetProjection(bson.D{{"attributes", 1}})
+	err := s.db.Collection("products").FindOne(ctx, bson.D{{"_id", productID}}, opts).Decode(&product)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrProductNotFound
+		}
+		return nil, err
+	}
+	return product.Attributes, nil
+}
+
+// setProductAttributes stores the product attributes along with their facet ids,
+// the ids are computed here as $toLower only handles ASCII.
+func (s *Storage) setProductAttributes(ctx context.Context, productID string, attrs []*erp.ProductAttribute) error {
+	if attrs == nil {
+		attrs = []*erp.ProductAttribute{}
+	}
+	update := bson.D{{"$set", bson.D{
+		{"attributes", attrs},
+		{"facets", erp.AttributeIDs(attrs)},
+	}}}
+	_, err := s.db.Collection("products").UpdateOne(ctx, bson.D{{"_id", productID}}, update)
+	return err
+}
+
+// variationAttribute returns the stored name and value of the variation attribute,
+// empty ones if the variation doesn't have it.
+func (s *Storage) variationAttribute(ctx context.Context, sku string, name string) (string, string, error) {
+	variation := struct {
+		Attributes []*erp.NameValue `bson:"attributes"`
+	}{}
+	opts := options.FindOne().SetProjection(bson.D{{"attributes", 1}})
+	err := s.db.Collection("variations").FindOne(ctx, bson.D{{"_id", sku}}, opts).Decode(&variation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", "", erp.ErrVariationNotFound
+		}
+		return "", "", err
+	}
+	for _, a := range variation.Attributes {
+		if a != nil && erp.AttributeKey(a.Name) == erp.AttributeKey(name) {
+			return a.Name, a.Value, nil
+		}
+	}
+	return "", "", nil
+}
+
+// setVariationAttribute sets the attribute value of the variation along with its facet,
+// other fields of the attribute, like the frame family, are kept.
+func (s *Storage) setVariationAttribute(ctx context.Context, sku string, name string, value string) error {
+	stored, old, err := s.variationAttribute(ctx, sku, name)
+	if err != nil {
+		return err
+	}
+	if stored != "" && old == value {
+		return nil
+	}
+
+	variations := s.db.Collection("variations")
+	if stored == "" {
+		update := bson.D{{"$push", bson.D{{"attributes", bson.D{{"name", name}, {"value", value}}}}}}
+		if _, err := variations.UpdateOne(ctx, bson.D{{"_id", sku}}, update); err != nil {
+			return err
+		}
+	} else {
+		filter := bson.D{{"_id", sku}, {"attributes.name", stored}}
+		update := bson.D{{"$set", bson.D{{"attributes.$.value", value}}}}
+		if _, err := variations.UpdateOne(ctx, filter, update); err != nil {
+			return err
+		}
+		update = bson.D{{"$pull", bson.D{{"attrs", erp.AttributeID(name, old)}}}}
+		if _, err := variations.UpdateOne(ctx, bson.D{{"_id", sku}}, update); err != nil {
+			return err
+		}
+		if err := s.countAttribute(ctx, name, []string{old}, -1); err != nil {
+			return err
+		}
+	}
+	update := bson.D{{"$addToSet", bson.D{{"attrs", erp.AttributeID(name, value)}}}}
+	if _, err := variations.UpdateOne(ctx, bson.D{{"_id", sku}}, update); err != nil {
+		return err
+	}
+	return s.countAttribute(ctx, name, []string{value}, 1)
+}
+
+// removeVariationAttribute removes the attribute of the variation along with its facet.
+// If values are given the attribute is only removed when it has one of them.
+func (s *Storage) removeVariationAttribute(ctx context.Context, sku string, name string, values []string) error {
+	stored, old, err := s.variationAttribute(ctx, sku, name)
+	if err != nil {
+		return err
+	}
+	if stored == "" {
+		return nil
+	}
+	if len(values) > 0 {
+		found := false
+		for _, v := range values {
+			if v == old {
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	update := bson.D{{"$pull", bson.D{
+		{"attributes", bson.D{{"name", stored}}},
+		{"attrs", erp.AttributeID(name, old)},
+	}}}
+	if _, err := s.db.Collection("variations").UpdateOne(ctx, bson.D{{"_id", sku}}, update); err != nil {
+		return err
+	}
+	return s.countAttribute(ctx, name, []string{old}, -1)
+}
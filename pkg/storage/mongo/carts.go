@@ -5,9 +5,10 @@ import (
 	"errors"
 	"time"
 
-	"github.com/anabiozz/core/lapkins/pkg/model"
+	"github.com/anabiozz/core/lapkins/pkg/erp"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -41,9 +42,9 @@ func (s *Storage) AddProductToCard(ctx context.Context, sku string, userID strin
 		}
 	}
 
-	cart := &model.Cart{}
-	product := &model.Product{}
-	//variation := &model.Variation{}
+	cart := &erp.Cart{}
+	product := &erp.Product{}
+	//variation := &erp.Variation{}
 
 	err = s.db.Collection("products").FindOne(ctx, bson.D{{"variations.sku", sku}}).Decode(product)
 	if err != nil {
@@ -65,7 +66,7 @@ func (s *Storage) AddProductToCard(ctx context.Context, sku string, userID strin
 			}
 
 			// Корзина не найдена
-			cartProduct := &model.CartProduct{}
+			cartProduct := &erp.CartProduct{}
 			cartProduct.Name = product.Name
 			//cartProduct.Price = variation.Pricing.Retail
 			cartProduct.Quantity = 1
@@ -90,6 +91,12 @@ func (s *Storage) AddProductToCard(ctx context.Context, sku string, userID strin
 						{"products", cartProduct},
 					},
 				},
+				{
+					"$inc",
+					bson.D{
+						{"version", 1},
+					},
+				},
 			}
 			opts := options.Update().SetUpsert(true)
 			_, err := s.db.Collection("cart").UpdateOne(ctx, filter, update, opts)
@@ -112,12 +119,12 @@ func (s *Storage) AddProductToCard(ctx context.Context, sku string, userID strin
 				"$inc",
 				bson.D{
 					{"products.$.quantity", 1},
+					{"version", 1},
 				},
 			},
 		}
 
-		opts := options.Update().SetUpsert(true)
-		_, err := s.db.Collection("cart").UpdateOne(ctx, filter, update, opts)
+		_, err := s.db.Collection("cart").UpdateOne(ctx, filter, update)
 		if err != nil {
 			return false, "", err
 		}
@@ -126,62 +133,84 @@ func (s *Storage) AddProductToCard(ctx context.Context, sku string, userID strin
 	return setTmpUserIDCookie, userID, nil
 }
 
-// IncreaseProductQuantity ..
-func (s *Storage) IncreaseProductQuantity(ctx context.Context, userID string, sku string) error {
+// SetProductQuantity sets the quantity of the SKU in the active cart of the user,
+// zero quantity removes the product from the cart.
+// The update is applied only to the given version of the cart unless it is erp.AnyCartVersion.
+func (s *Storage) SetProductQuantity(ctx context.Context, userID string, sku string, qty int, version int64) error {
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return err
 	}
 	filter := bson.D{{"_id", objID}, {"status", "active"}, {"products.sku", sku}}
-	update := bson.D{
-		{
-			"$set",
-			bson.D{
-				{"products.$.updated_at", time.Now()},
+	versioned := filter
+	if version != erp.AnyCartVersion {
+		versioned = append(bson.D{{"version", cartVersion(version)}}, filter...)
+	}
+
+	var update bson.D
+	if qty > 0 {
+		update = bson.D{
+			{
+				"$set",
+				bson.D{
+					{"updated_at", time.Now()},
+					{"products.$.updated_at", time.Now()},
+					{"products.$.quantity", qty},
+				},
 			},
-		},
-		{
-			"$inc",
-			bson.D{
-				{"products.$.quantity", 1},
+			{
+				"$inc",
+				bson.D{
+					{"version", 1},
+				},
 			},
-		},
-	}
-	opts := options.Update().SetUpsert(true)
-	_, err = s.db.Collection("cart").UpdateOne(ctx, filter, update, opts)
-	if err != nil {
-		return err
+		}
+	} else {
+		update = bson.D{
+			{
+				"$pull",
+				bson.D{
+					{"products", bson.D{{"sku", sku}}},
+				},
+			},
+			{
+				"$set",
+				bson.D{
+					{"updated_at", time.Now()},
+				},
+			},
+			{
+				"$inc",
+				bson.D{
+					{"version", 1},
+				},
+			},
+		}
 	}
-	return nil
-}
 
-// DecreaseProductQuantity ..
-func (s *Storage) DecreaseProductQuantity(ctx context.Context, userID string, sku string) error {
-	objID, err := primitive.ObjectIDFromHex(userID)
+	result, err := s.db.Collection("cart").UpdateOne(ctx, versioned, update)
 	if err != nil {
 		return err
 	}
-	filter := bson.D{{"_id", objID}, {"status", "active"}, {"products.sku", sku}}
-	update := bson.D{
-		{
-			"$set",
-			bson.D{
-				{"products.$.updated_at", time.Now()},
-			},
-		},
-		{
-			"$inc",
-			bson.D{
-				{"products.$.quantity", -1},
-			},
-		},
+	if result.MatchedCount > 0 {
+		return nil
 	}
-	opts := options.Update().SetUpsert(true)
-	_, err = s.db.Collection("cart").UpdateOne(ctx, filter, update, opts)
+	n, err := s.db.Collection("cart").CountDocuments(ctx, filter)
 	if err != nil {
 		return err
 	}
-	return nil
+	if n > 0 {
+		return erp.ErrModifiedInStorage
+	}
+	return erp.ErrNotFoundInStorage
+}
+
+// cartVersion matches the cart version, carts created before versioning have version 0.
+func cartVersion(version int64) interface{} {
+	if version == 0 {
+		return bson.D{{"$in", bson.A{0, nil}}}
+	}
+	return version
 }
 
 // RemoveProduct ..
@@ -204,9 +233,14 @@ func (s *Storage) RemoveProduct(ctx context.Context, userID string, sku string)
 				{"updated_at", time.Now()},
 			},
 		},
+		{
+			"$inc",
+			bson.D{
+				{"version", 1},
+			},
+		},
 	}
-	opts := options.Update().SetUpsert(true)
-	_, err = s.db.Collection("cart").UpdateOne(ctx, filter, update, opts)
+	_, err = s.db.Collection("cart").UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
@@ -214,9 +248,8 @@ func (s *Storage) RemoveProduct(ctx context.Context, userID string, sku string)
 }
 
 // LoadCart ..
-func (s *Storage) LoadCart(ctx context.Context, userID string) ([]*model.CartProduct, error) {
-	cart := &model.Cart{}
-	var cartProducts []*model.CartProduct
+func (s *Storage) LoadCart(ctx context.Context, userID string) (*erp.Cart, error) {
+	cart := &erp.Cart{}
 
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -224,10 +257,183 @@ func (s *Storage) LoadCart(ctx context.Context, userID string) ([]*model.CartPro
 	}
 	err = s.db.Collection("cart").FindOne(ctx, bson.D{{"_id", objID}, {"status", "active"}}).Decode(cart)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrNotFoundInStorage
+		}
 		return nil, err
 	}
-	for _, product := range cart.Products {
-		cartProducts = append(cartProducts, product)
+	return cart, nil
+}
+
+// ExpireCarts expires active carts that were not updated since before and returns them.
+func (s *Storage) ExpireCarts(ctx context.Context, before time.Time) ([]*erp.Cart, error) {
+	filter := bson.D{{"status", erp.CartStatusActive}, {"updated_at", bson.D{{"$lt", before}}}}
+	cur, err := s.db.Collection("cart").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var carts []*erp.Cart
+	for cur.Next(ctx) {
+		cart := &erp.Cart{}
+		if err := cur.Decode(cart); err != nil {
+			return carts, err
+		}
+		var archived erp.Cart
+		err := s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+			// Work on a copy, the transaction may be retried.
+			archived = *cart
+			return s.archiveCart(sc, &archived, erp.CartStatusExpired)
+		})
+		if err == erp.ErrModifiedInStorage {
+			// Customer came back in the meantime.
+			continue
+		}
+		if err != nil {
+			return carts, err
+		}
+		carts = append(carts, &archived)
+	}
+	if err := cur.Err(); err != nil {
+		return carts, err
+	}
+	return carts, nil
+}
+
+// PurgeExpiredCarts deletes anonymous carts that expired before the given time.
+func (s *Storage) PurgeExpiredCarts(ctx context.Context, before time.Time) (int64, error) {
+	filter := bson.D{
+		{"status", erp.CartStatusExpired},
+		{"type", bson.D{{"$ne", "logged_in"}}},
+		{"updated_at", bson.D{{"$lt", before}}},
+	}
+	result, err := s.db.Collection("cart").DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// archiveCart closes the active cart with the given status.
+// Active carts are keyed by the user id, so the closed one is moved under a new id
+// to let the user start a fresh cart. It must run inside a transaction.
+func (s *Storage) archiveCart(ctx context.Context, cart *erp.Cart, status string) error {
+	filter := bson.D{{"_id", cart.ID}, {"status", erp.CartStatusActive}}
+	if !cart.UpdatedAt.IsZero() {
+		filter = append(filter, bson.E{"updated_at", cart.UpdatedAt})
+	}
+	result, err := s.db.Collection("cart").DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return erp.ErrModifiedInStorage
+	}
+
+	cart.UserID = cart.ID.Hex()
+	cart.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	cart.Status = status
+	cart.UpdatedAt = time.Now()
+	_, err = s.db.Collection("cart").InsertOne(ctx, cart)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MergeCarts moves the products of the anonymous cart into the active cart of the user
+// summing quantities per SKU, and deletes the anonymous cart in the same transaction.
+// The anonymous cart becomes the user cart if the user has none.
+// Merging an already merged cart returns erp.ErrNotFoundInStorage.
+func (s *Storage) MergeCarts(ctx context.Context, tmpUserID string, userID string) error {
+	tmpID, err := primitive.ObjectIDFromHex(tmpUserID)
+	if err != nil {
+		return err
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		tmpCart := &erp.Cart{}
+		err := s.db.Collection("cart").FindOne(sc, bson.D{{"_id", tmpID}, {"status", erp.CartStatusActive}}).Decode(tmpCart)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return erp.ErrNotFoundInStorage
+			}
+			return err
+		}
+		result, err := s.db.Collection("cart").DeleteOne(sc, bson.D{{"_id", tmpID}, {"status", erp.CartStatusActive}})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return erp.ErrModifiedInStorage
+		}
+
+		cart := &erp.Cart{}
+		err = s.db.Collection("cart").FindOne(sc, bson.D{{"_id", userObjID}, {"status", erp.CartStatusActive}}).Decode(cart)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				return err
+			}
+			tmpCart.ID = userObjID
+			tmpCart.Type = "logged_in"
+			tmpCart.UpdatedAt = time.Now()
+			tmpCart.Version++
+			_, err = s.db.Collection("cart").InsertOne(sc, tmpCart)
+			return err
+		}
+
+		products := mergeCartProducts(cart.Products, tmpCart.Products)
+		set := bson.D{
+			{"updated_at", time.Now()},
+			{"products", products},
+		}
+		if cart.Coupon == "" && tmpCart.Coupon != "" {
+			set = append(set, bson.E{"coupon", tmpCart.Coupon})
+		}
+		filter := bson.D{{"_id", userObjID}, {"status", erp.CartStatusActive}, {"version", cartVersion(cart.Version)}}
+		update := bson.D{
+			{
+				"$set",
+				set,
+			},
+			{
+				"$inc",
+				bson.D{
+					{"version", 1},
+				},
+			},
+		}
+		updated, err := s.db.Collection("cart").UpdateOne(sc, filter, update)
+		if err != nil {
+			return err
+		}
+		if updated.MatchedCount == 0 {
+			return erp.ErrModifiedInStorage
+		}
+		return nil
+	})
+}
+
+// mergeCartProducts adds the products of src to dst summing quantities of the same SKU.
+func mergeCartProducts(dst []*erp.CartProduct, src []*erp.CartProduct) []*erp.CartProduct {
+	bySKU := make(map[string]*erp.CartProduct, len(dst))
+	for _, p := range dst {
+		bySKU[p.SKU] = p
+	}
+	for _, p := range src {
+		if existing, ok := bySKU[p.SKU]; ok {
+			existing.Quantity += p.Quantity
+			existing.UpdatedAt = time.Now()
+			continue
+		}
+		p.UpdatedAt = time.Now()
+		dst = append(dst, p)
+		bySKU[p.SKU] = p
 	}
-	return cartProducts, nil
+	return dst
 }
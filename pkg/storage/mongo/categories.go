@@ -2,64 +2,473 @@ package mongo
 
 import (
 	"context"
-	"fmt"
+	"regexp"
+	"time"
 
-	"github.com/anabiozz/core/lapkins/pkg/model"
+	"github.com/anabiozz/core/lapkins/pkg/erp"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// GetCategories ..
-func (s *Storage) GetCategories(ctx context.Context) ([]*model.Category, error) {
-	cursor, err := s.db.Collection("categories").Find(ctx, bson.D{{"parents", nil}})
+// categorySorts are the sort fields of the category list.
+var categorySorts = map[string]string{
+	"id":       "_id",
+	"position": "position",
+}
+
+// GetCategories returns a page of root categories with their subcategory trees.
+func (s *Storage) GetCategories(ctx context.Context, req *erp.PageRequest) (*erp.CategoryPage, error) {
+	result := &erp.CategoryPage{}
+	page, err := s.findPage(ctx, "categories", bson.D{{"parents", nil}}, req, categorySorts, func(cur *mongo.Cursor) error {
+		category := &erp.Category{}
+		if err := cur.Decode(category); err != nil {
+			return err
+		}
+		result.Categories = append(result.Categories, category)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Page = *page
+	if len(result.Categories) == 0 {
+		return result, nil
+	}
+
+	ids := make([]string, 0, len(result.Categories))
+	for _, category := range result.Categories {
+		ids = append(ids, category.ID)
+	}
+	subcategories, err := s.findCategories(ctx, bson.D{{"ancestors", bson.D{{"$in", ids}}}})
+	if err != nil {
+		return nil, err
+	}
+	erp.BuildCategoryTree(append(append([]*erp.Category{}, result.Categories...), subcategories...))
+	return result, nil
+}
+
+// GetCategory returns the category with its subcategory tree.
+func (s *Storage) GetCategory(ctx context.Context, id string) (*erp.Category, error) {
+	categories, err := s.findCategories(ctx, bson.D{{"$or", bson.A{
+		bson.D{{"_id", id}},
+		bson.D{{"ancestors", id}},
+	}}})
+	if err != nil {
+		return nil, err
+	}
+	for _, category := range erp.BuildCategoryTree(categories) {
+		if category.ID == id {
+			return category, nil
+		}
+	}
+	return nil, erp.ErrNotFoundInStorage
+}
+
+func (s *Storage) findCategories(ctx context.Context, filter bson.D) ([]*erp.Category, error) {
+	opts := options.Find().SetSort(bson.D{{"position", 1}, {"_id", 1}})
+	cur, err := s.db.Collection("categories").Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-	var categories []*model.Category
-	for cursor.Next(ctx) {
+	defer cur.Close(ctx)
 
-		var category *model.Category
-		if err = cursor.Decode(&category); err != nil {
+	var categories []*erp.Category
+	for cur.Next(ctx) {
+		category := &erp.Category{}
+		if err := cur.Decode(category); err != nil {
 			return nil, err
 		}
+		categories = append(categories, category)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
 
-		cursor, err := s.db.Collection("categories").Find(ctx, bson.D{{"parents", category.ID}})
+// getCategory returns the category without its subcategories.
+func (s *Storage) getCategory(ctx context.Context, id string) (*erp.Category, error) {
+	category := &erp.Category{}
+	err := s.db.Collection("categories").FindOne(ctx, bson.D{{"_id", id}}).Decode(category)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrNotFoundInStorage
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+// CreateCategory adds the category as the last subcategory of its parent,
+// or as the last root category if it has no parent.
+func (s *Storage) CreateCategory(ctx context.Context, category *erp.Category) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		category.Ancestors = []string{}
+		if parentID := category.Parent(); parentID != "" {
+			parent, err := s.getCategory(sc, parentID)
+			if err != nil {
+				if err == erp.ErrNotFoundInStorage {
+					return erp.ErrCategoryParentNotFound
+				}
+				return err
+			}
+			category.Ancestors = append(parent.Ancestors, parent.ID)
+		}
+		position, err := s.nextCategoryPosition(sc, category.Parent())
 		if err != nil {
-			return nil, err
+			return err
 		}
-		defer cursor.Close(ctx)
+		category.Position = position
 
-		for cursor.Next(ctx) {
-			var subcategory *model.Subcategory
-			if err = cursor.Decode(&subcategory); err != nil {
-				return nil, err
+		if _, err := s.db.Collection("categories").InsertOne(sc, category); err != nil {
+			if isDuplicateKeyError(err) {
+				return erp.ErrDuplicateKeyInStorage
 			}
-			category.Ancestors = append(category.Ancestors, subcategory)
+			return err
 		}
+		return nil
+	})
+}
 
-		categories = append(categories, category)
+// nextCategoryPosition returns the position after the last subcategory of the parent.
+func (s *Storage) nextCategoryPosition(ctx context.Context, parentID string) (int, error) {
+	opts := options.FindOne().SetSort(bson.D{{"position", -1}}).SetProjection(bson.D{{"position", 1}})
+	last := &erp.Category{}
+	err := s.db.Collection("categories").FindOne(ctx, childrenFilter(parentID), opts).Decode(last)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
 	}
-	return categories, nil
+	return last.Position + 1, nil
+}
+
+// childrenFilter matches the subcategories of the parent, or the root categories if it is empty.
+func childrenFilter(parentID string) bson.D {
+	if parentID == "" {
+		return bson.D{{"parents", nil}}
+	}
+	return bson.D{{"parents", parentID}}
 }
 
-func (s *Storage) AddCategory(ctx context.Context, sku string, category *model.Category) error {
-	filter := bson.D{{"status", "active"}, {"products.sku", sku}}
-	update := bson.D{
-		{
-			"$push",
-			bson.D{
-				{"products", category},
-			},
-		},
+// RenameCategory changes the name and the description of the category.
+// The id stays the same as it is a part of the category paths.
+func (s *Storage) RenameCategory(ctx context.Context, id string, name []*erp.LangValue, description []*erp.LangValue) error {
+	set := bson.D{{"name", name}, {"modified_on", time.Now()}}
+	if description != nil {
+		set = append(set, bson.E{Key: "description", Value: description})
 	}
-	_, err := s.db.Collection("categories").UpdateOne(ctx, filter, update, nil)
+	result, err := s.db.Collection("categories").UpdateOne(ctx, bson.D{{"_id", id}}, bson.D{{"$set", set}})
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return erp.ErrNotFoundInStorage
+	}
 	return nil
 }
 
-func (s *Storage) RemoveCategory(ctx context.Context, sku string, category *model.Category) error {
-	fmt.Println("RemoveCategory")
+// MoveCategory moves the category with its subtree under the new parent,
+// or to the root if the parent is empty, as the last subcategory.
+// The ancestors of the subtree and the category paths of its variations follow.
+func (s *Storage) MoveCategory(ctx context.Context, id string, parentID string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		category, err := s.getCategory(sc, id)
+		if err != nil {
+			return err
+		}
+		if category.Parent() == parentID {
+			return nil
+		}
+
+		var parent *erp.Category
+		if parentID != "" {
+			parent, err = s.getCategory(sc, parentID)
+			if err != nil {
+				if err == erp.ErrNotFoundInStorage {
+					return erp.ErrCategoryParentNotFound
+				}
+				return err
+			}
+		}
+		ancestors, err := category.MoveTo(parent)
+		if err != nil {
+			return err
+		}
+		position, err := s.nextCategoryPosition(sc, parentID)
+		if err != nil {
+			return err
+		}
+
+		set := bson.D{{"ancestors", ancestors}, {"position", position}, {"modified_on", time.Now()}}
+		if parentID == "" {
+			set = append(set, bson.E{Key: "parents", Value: nil})
+		} else {
+			set = append(set, bson.E{Key: "parents", Value: []string{parentID}})
+		}
+		_, err = s.db.Collection("categories").UpdateOne(sc, bson.D{{"_id", id}}, bson.D{{"$set", set}})
+		if err != nil {
+			return err
+		}
+
+		// Subcategories keep their ancestors below the moved category
+		// and get the new ones above it.
+		depth := len(category.Ancestors)
+		_, err = s.db.Collection("categories").UpdateMany(sc, bson.D{{"ancestors", id}}, bson.A{
+			bson.D{{"$set", bson.D{{"ancestors", bson.D{{"$concatArrays", bson.A{
+				ancestors,
+				bson.D{{"$slice", bson.A{"$ancestors", depth, bson.D{{"$size", "$ancestors"}}}}},
+			}}}}}}},
+		})
+		if err != nil {
+			return err
+		}
+
+		oldPath := category.Path()
+		newPath := erp.CategoryPath(append(append([]string{}, ancestors...), id))
+		_, err = s.db.Collection("variations").UpdateMany(sc, categoryPathFilter(oldPath), bson.A{
+			bson.D{{"$set", bson.D{{"category", bson.D{{"$concat", bson.A{
+				newPath,
+				bson.D{{"$substrBytes", bson.A{"$category", len(oldPath), bson.D{{"$strLenBytes", "$category"}}}}},
+			}}}}}}},
+		})
+		return err
+	})
+}
+
+// categoryPathFilter matches the variations in the category with the path or in its subcategories.
+func categoryPathFilter(path string) bson.D {
+	return bson.D{{"category", primitive.Regex{Pattern: "^" + regexp.QuoteMeta(path) + "(/|$)"}}}
+}
+
+// ReorderCategories sets the order of the subcategories of the parent,
+// or of the root categories if the parent is empty.
+// ids must list all of them.
+func (s *Storage) ReorderCategories(ctx context.Context, parentID string, ids []string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		children, err := s.findCategories(sc, childrenFilter(parentID))
+		if err != nil {
+			return err
+		}
+		if len(children) != len(ids) {
+			return erp.ErrCategoryOrderMismatch
+		}
+		positions := make(map[string]int, len(ids))
+		for i, id := range ids {
+			positions[id] = i
+		}
+		for _, child := range children {
+			if _, ok := positions[child.ID]; !ok {
+				return erp.ErrCategoryOrderMismatch
+			}
+		}
+
+		now := time.Now()
+		for id, position := range positions {
+			_, err := s.db.Collection("categories").UpdateOne(sc, bson.D{{"_id", id}},
+				bson.D{{"$set", bson.D{{"position", position}, {"modified_on", now}}}})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteCategory deletes the category if it has neither subcategories nor products.
+func (s *Storage) DeleteCategory(ctx context.Context, id string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		category, err := s.getCategory(sc, id)
+		if err != nil {
+			return err
+		}
+		n, err := s.db.Collection("categories").CountDocuments(sc, bson.D{{"parents", id}})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return erp.ErrCategoryHasSubcategories
+		}
+		n, err = s.db.Collection("products").CountDocuments(sc, bson.D{{"categories", id}})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return erp.ErrCategoryHasProducts
+		}
+		n, err = s.db.Collection("variations").CountDocuments(sc, categoryPathFilter(category.Path()))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return erp.ErrCategoryHasProducts
+		}
+		_, err = s.db.Collection("categories").DeleteOne(sc, bson.D{{"_id", id}})
+		return err
+	})
+}
+
+// categorySubtreeIDs returns the ids of the category and of all its subcategories.
+func (s *Storage) categorySubtreeIDs(ctx context.Context, id string) ([]string, error) {
+	filter := bson.D{{"$or", bson.A{
+		bson.D{{"_id", id}},
+		bson.D{{"ancestors", id}},
+	}}}
+	opts := options.Find().SetProjection(bson.D{{"_id", 1}})
+	cur, err := s.db.Collection("categories").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var ids []string
+	for cur.Next(ctx) {
+		category := &erp.Category{}
+		if err := cur.Decode(category); err != nil {
+			return nil, err
+		}
+		ids = append(ids, category.ID)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, erp.ErrCategoryNotFound
+	}
+	return ids, nil
+}
+
+// AddProductCategory lists the product in the category.
+// The category becomes the primary one if asked to or if the product has none yet.
+func (s *Storage) AddProductCategory(ctx context.Context, productID string, categoryID string, primary bool) error {
+	if _, err := s.getCategory(ctx, categoryID); err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return erp.ErrCategoryNotFound
+		}
+		return err
+	}
+
+	categories := bson.D{{"$ifNull", bson.A{"$categories", bson.A{}}}}
+	primaryCategory := interface{}(categoryID)
+	if !primary {
+		primaryCategory = bson.D{{"$cond", bson.A{
+			bson.D{{"$gt", bson.A{"$primary_category", ""}}},
+			"$primary_category",
+			categoryID,
+		}}}
+	}
+	update := bson.A{
+		bson.D{{"$set", bson.D{
+			{"categories", bson.D{{"$cond", bson.A{
+				bson.D{{"$in", bson.A{categoryID, categories}}},
+				categories,
+				bson.D{{"$concatArrays", bson.A{categories, bson.A{categoryID}}}},
+			}}}},
+			{"primary_category", primaryCategory},
+		}}},
+	}
+	result, err := s.db.Collection("products").UpdateOne(ctx, bson.D{{"_id", productID}}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return erp.ErrProductNotFound
+	}
 	return nil
 }
+
+// RemoveProductCategory unlists the product from the category.
+// If it was the primary category the first one left becomes primary.
+func (s *Storage) RemoveProductCategory(ctx context.Context, productID string, categoryID string) error {
+	left := bson.D{{"$filter", bson.D{
+		{"input", bson.D{{"$ifNull", bson.A{"$categories", bson.A{}}}}},
+		{"cond", bson.D{{"$ne", bson.A{"$$this", categoryID}}}},
+	}}}
+	update := bson.A{
+		bson.D{{"$set", bson.D{
+			{"categories", left},
+			{"primary_category", bson.D{{"$cond", bson.A{
+				bson.D{{"$eq", bson.A{"$primary_category", categoryID}}},
+				bson.D{{"$ifNull", bson.A{bson.D{{"$arrayElemAt", bson.A{left, 0}}}, ""}}},
+				"$primary_category",
+			}}}},
+		}}},
+	}
+	result, err := s.db.Collection("products").UpdateOne(ctx, bson.D{{"_id", productID}}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return erp.ErrProductNotFound
+	}
+	return nil
+}
+
+// GetCategoryProducts returns a page of the products listed in the category or in its subcategories.
+func (s *Storage) GetCategoryProducts(ctx context.Context, categoryID string, req *erp.PageRequest) (*erp.CatalogPage, error) {
+	ids, err := s.categorySubtreeIDs(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &erp.CatalogPage{Products: []*erp.CatalogProduct{}}
+	filter := bson.D{{"categories", bson.D{{"$in", ids}}}}
+	page, err := s.findPage(ctx, "products", filter, req, productSorts, func(cur *mongo.Cursor) error {
+		product := &erp.CatalogProduct{}
+		if err := cur.Decode(product); err != nil {
+			return err
+		}
+		result.Products = append(result.Products, product)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Page = *page
+	if err := s.fillCatalogProducts(ctx, result.Products); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fillCatalogProducts sets the lowest current price and the thumbnail of the variations
+// to the product cards.
+func (s *Storage) fillCatalogProducts(ctx context.Context, products []*erp.CatalogProduct) error {
+	if len(products) == 0 {
+		return nil
+	}
+	byID := make(map[string]*erp.CatalogProduct, len(products))
+	ids := make([]string, 0, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+
+	opts := options.Find().SetProjection(bson.D{{"productId", 1}, {"pricing", 1}, {"assets", 1}})
+	cur, err := s.db.Collection("variations").Find(ctx, bson.D{{"productId", bson.D{{"$in", ids}}}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	now := time.Now()
+	for cur.Next(ctx) {
+		v := &searchVariation{}
+		if err := cur.Decode(v); err != nil {
+			return err
+		}
+		p := byID[v.ProductID]
+		if v.Pricing != nil {
+			if price := v.Pricing.Current(now); p.Price.IsZero() || price.Less(p.Price) {
+				p.Price = price
+			}
+		}
+		if p.Thumbnail == "" {
+			p.Thumbnail = v.Assets.Thumbnail.Src
+		}
+	}
+	return cur.Err()
+}
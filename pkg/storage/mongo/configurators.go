@@ -0,0 +1,48 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetConfigurator returns the configurator of the product.
+func (s *Storage) GetConfigurator(ctx context.Context, productID string) (*erp.Configurator, error) {
+	configurator := &erp.Configurator{}
+	err := s.db.Collection("configurators").FindOne(ctx, bson.D{{"_id", productID}}).Decode(configurator)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrConfiguratorNotFound
+		}
+		return nil, err
+	}
+	return configurator, nil
+}
+
+// SetConfigurator creates or replaces the configurator of the product.
+func (s *Storage) SetConfigurator(ctx context.Context, configurator *erp.Configurator) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := s.getProductDocument(sc, configurator.ProductID); err != nil {
+			return err
+		}
+		opts := options.Replace().SetUpsert(true)
+		filter := bson.D{{"_id", configurator.ProductID}}
+		_, err := s.db.Collection("configurators").ReplaceOne(sc, filter, configurator, opts)
+		return err
+	})
+}
+
+// DeleteConfigurator deletes the configurator of the product, the variations it created are kept.
+func (s *Storage) DeleteConfigurator(ctx context.Context, productID string) error {
+	result, err := s.db.Collection("configurators").DeleteOne(ctx, bson.D{{"_id", productID}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return erp.ErrConfiguratorNotFound
+	}
+	return nil
+}
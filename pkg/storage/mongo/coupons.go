@@ -0,0 +1,147 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (s *Storage) GetCoupon(ctx context.Context, code string) (*erp.Coupon, error) {
+	coupon := &erp.Coupon{}
+	err := s.db.Collection("coupons").FindOne(ctx, bson.D{{"_id", code}}).Decode(coupon)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrNotFoundInStorage
+		}
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// SetCartCoupon stores the coupon code on the active cart of the user,
+// an empty code removes the coupon.
+func (s *Storage) SetCartCoupon(ctx context.Context, userID string, code string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	filter := bson.D{{"_id", objID}, {"status", erp.CartStatusActive}}
+	set := bson.D{{"updated_at", time.Now()}}
+	if code != "" {
+		set = append(set, bson.E{"coupon", code})
+	}
+	update := bson.D{{"$set", set}, {"$inc", bson.D{{"version", 1}}}}
+	if code == "" {
+		update = append(update, bson.E{"$unset", bson.D{{"coupon", ""}}})
+	}
+	result, err := s.db.Collection("cart").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return erp.ErrNotFoundInStorage
+	}
+	return nil
+}
+
+// redeemCoupon records the use of the order coupon.
+// Usage limits are checked by the update filter, so concurrent checkouts can't exceed them.
+func (s *Storage) redeemCoupon(ctx context.Context, order *erp.Order) error {
+	redemptions := bson.D{{"$ifNull", bson.A{"$redemptions", bson.A{}}}}
+	userRedemptions := bson.D{
+		{
+			"$filter",
+			bson.D{
+				{"input", redemptions},
+				{"as", "r"},
+				{"cond", bson.D{{"$eq", bson.A{"$$r.user_id", order.UserID}}}},
+			},
+		},
+	}
+	underLimit := func(limit string, used interface{}) bson.D {
+		return bson.D{
+			{
+				"$or",
+				bson.A{
+					bson.D{{"$lte", bson.A{"$" + limit, 0}}},
+					bson.D{{"$lt", bson.A{bson.D{{"$size", used}}, "$" + limit}}},
+				},
+			},
+		}
+	}
+	filter := bson.D{
+		{"_id", order.Coupon.Code},
+		{
+			"$expr",
+			bson.D{
+				{
+					"$and",
+					bson.A{
+						underLimit("usage_limit", redemptions),
+						underLimit("usage_limit_per_user", userRedemptions),
+					},
+				},
+			},
+		},
+	}
+	redemption := &erp.CouponRedemption{
+		OrderID:    order.ID,
+		UserID:     order.UserID,
+		RedeemedOn: time.Now(),
+	}
+	update := bson.D{
+		{
+			"$push",
+			bson.D{
+				{"redemptions", redemption},
+			},
+		},
+		{
+			"$set",
+			bson.D{
+				{"modified_on", time.Now()},
+			},
+		},
+	}
+	result, err := s.db.Collection("coupons").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return erp.ErrCouponExhausted
+	}
+	return nil
+}
+
+// releaseCoupon gives back the coupon redemption of the order, so it counts no more
+// against the usage limits.
+func (s *Storage) releaseCoupon(ctx context.Context, orderID string) error {
+	filter := bson.D{{"redemptions.order_id", orderID}}
+	update := bson.D{
+		{
+			"$pull",
+			bson.D{
+				{"redemptions", bson.D{{"order_id", orderID}}},
+			},
+		},
+		{
+			"$set",
+			bson.D{
+				{"modified_on", time.Now()},
+			},
+		},
+	}
+	_, err := s.db.Collection("coupons").UpdateMany(ctx, filter, update)
+	return err
+}
+
+func couponCode(order *erp.Order) string {
+	if order.Coupon == nil {
+		return ""
+	}
+	return order.Coupon.Code
+}
@@ -0,0 +1,221 @@
+package mongo
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// authorFacet is the facet made of the product brand,
+// the other facets are listed on the variations, see deploy/mongo/variations.json,
+// and on the products for the product attributes.
+const authorFacet = "author"
+
+// GetFacets returns the facet value definitions in their display order.
+func (s *Storage) GetFacets(ctx context.Context) ([]*erp.Facet, error) {
+	cur, err := s.db.Collection("facets").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var facets []*erp.Facet
+	for cur.Next(ctx) {
+		facet := &erp.Facet{}
+		if err := cur.Decode(facet); err != nil {
+			return nil, err
+		}
+		facets = append(facets, facet)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+type facetedProduct struct {
+	ID        string         `bson:"_id"`
+	Name      string         `bson:"name"`
+	Thumbnail string         `bson:"thumbnail"`
+	Pricing   []*erp.Pricing `bson:"pricing"`
+}
+
+type facetCount struct {
+	ID    string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+// FilterProducts returns a page of products having a variation that matches the filter,
+// the total number of them and the facet counts for the filter.
+// The count of a value is computed without the selection of its own facet,
+// so it is the number of products the storefront would show once the value is selected too.
+func (s *Storage) FilterProducts(ctx context.Context, filter *erp.FacetFilter) (*erp.FacetedProducts, error) {
+	names := make([]string, 0, len(filter.Selected))
+	for name := range filter.Selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// match returns the stage matching the variations with the selected values
+	// of every facet except the given one.
+	match := func(except string) bson.D {
+		and := bson.A{}
+		for _, name := range names {
+			if name != except {
+				and = append(and, bson.D{{"facets", bson.D{{"$in", filter.SelectedIDs(name)}}}})
+			}
+		}
+		if len(and) == 0 {
+			return bson.D{{"$match", bson.D{}}}
+		}
+		return bson.D{{"$match", bson.D{{"$and", and}}}}
+	}
+	count := func(except string, prefix string) bson.A {
+		stages := bson.A{
+			match(except),
+			bson.D{{"$unwind", "$facets"}},
+		}
+		if prefix != "" {
+			stages = append(stages, bson.D{{"$match", bson.D{{"facets", primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}}}}})
+		}
+		return append(stages,
+			bson.D{{"$group", bson.D{{"_id", "$facets"}, {"products", bson.D{{"$addToSet", "$productId"}}}}}},
+			bson.D{{"$project", bson.D{{"count", bson.D{{"$size", "$products"}}}}}},
+		)
+	}
+
+	products := bson.A{
+		match(""),
+		bson.D{{"$group", bson.D{
+			{"_id", "$productId"},
+			{"name", bson.D{{"$first", "$name"}}},
+			{"thumbnail", bson.D{{"$first", "$thumbnail"}}},
+			{"pricing", bson.D{{"$push", "$pricing"}}},
+		}}},
+		bson.D{{"$sort", bson.D{{"name", 1}, {"_id", 1}}}},
+		bson.D{{"$skip", filter.Offset}},
+	}
+	if filter.Limit > 0 {
+		products = append(products, bson.D{{"$limit", filter.Limit}})
+	}
+	facets := bson.D{
+		{"products", products},
+		{"total", bson.A{
+			match(""),
+			bson.D{{"$group", bson.D{{"_id", "$productId"}}}},
+			bson.D{{"$count", "n"}},
+		}},
+		// Values of the facets without a selection are counted with the whole filter.
+		{"counts", count("", "")},
+	}
+	for i, name := range names {
+		facets = append(facets, bson.E{Key: "counts_" + strconv.Itoa(i), Value: count(name, erp.FacetID(name, ""))})
+	}
+
+	pipeline := bson.A{
+		bson.D{{"$lookup", bson.D{
+			{"from", "products"},
+			{"localField", "productId"},
+			{"foreignField", "_id"},
+			{"as", "product"},
+		}}},
+		bson.D{{"$unwind", "$product"}},
+	}
+	if filter.Category != "" {
+		ids, err := s.categorySubtreeIDs(ctx, filter.Category)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, bson.D{{"$match", bson.D{{"product.categories", bson.D{{"$in", ids}}}}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{"$project", bson.D{
+			{"productId", 1},
+			{"pricing", 1},
+			{"name", "$product.name"},
+			{"thumbnail", "$assets.thumbnail.src"},
+			{"facets", bson.D{{"$concatArrays", bson.A{
+				bson.D{{"$ifNull", bson.A{"$attrs", bson.A{}}}},
+				bson.D{{"$ifNull", bson.A{"$product.facets", bson.A{}}}},
+				bson.D{{"$cond", bson.A{
+					bson.D{{"$gt", bson.A{"$product.brand.name", ""}}},
+					bson.A{bson.D{{"$concat", bson.A{authorFacet + "=", bson.D{{"$toLower", "$product.brand.name"}}}}}},
+					bson.A{},
+				}}},
+			}}}},
+		}}},
+		bson.D{{"$facet", facets}},
+	)
+
+	cur, err := s.db.Collection("variations").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	result := &erp.FacetedProducts{
+		Products: []*erp.CatalogProduct{},
+		Counts:   erp.FacetCounts{},
+	}
+	if !cur.Next(ctx) {
+		return result, cur.Err()
+	}
+	raw := cur.Current
+
+	var page []*facetedProduct
+	if err := raw.Lookup("products").Unmarshal(&page); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, p := range page {
+		product := &erp.CatalogProduct{
+			ID:        p.ID,
+			Name:      p.Name,
+			Thumbnail: p.Thumbnail,
+		}
+		for _, pricing := range p.Pricing {
+			if pricing == nil {
+				continue
+			}
+			if price := pricing.Current(now); product.Price.IsZero() || price.Less(product.Price) {
+				product.Price = price
+			}
+		}
+		result.Products = append(result.Products, product)
+	}
+
+	var total []struct {
+		N int `bson:"n"`
+	}
+	if err := raw.Lookup("total").Unmarshal(&total); err != nil {
+		return nil, err
+	}
+	if len(total) > 0 {
+		result.Total = total[0].N
+	}
+
+	var counts []facetCount
+	if err := raw.Lookup("counts").Unmarshal(&counts); err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		if name, _, ok := erp.ParseFacetID(c.ID); ok && len(filter.Selected[name]) == 0 {
+			result.Counts[c.ID] = c.Count
+		}
+	}
+	for i := range names {
+		var counts []facetCount
+		if err := raw.Lookup("counts_" + strconv.Itoa(i)).Unmarshal(&counts); err != nil {
+			return nil, err
+		}
+		for _, c := range counts {
+			result.Counts[c.ID] = c.Count
+		}
+	}
+	return result, nil
+}
@@ -2,24 +2,191 @@ package mongo
 
 import (
 	"context"
+	"sort"
+	"time"
 
-	"github.com/anabiozz/core/lapkins/pkg/model"
+	"github.com/anabiozz/core/lapkins/pkg/erp"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func (s *Storage) AddReservation(ctx context.Context, sku string, category *model.Category) error {
-	filter := bson.D{{"status", "active"}, {"products.sku", sku}}
+func (s *Storage) GetInventory(ctx context.Context, sku string) (*erp.Inventory, error) {
+	inventory := &erp.Inventory{}
+	err := s.db.Collection("inventory").FindOne(ctx, bson.D{{"_id", sku}}).Decode(inventory)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrNotFoundInStorage
+		}
+		return nil, err
+	}
+	return inventory, nil
+}
+
+// ReserveStock reserves quantities per SKU for the given reservation in one transaction.
+// Either every SKU is reserved or none of them.
+func (s *Storage) ReserveStock(ctx context.Context, id string, ownerID string, quantities map[string]int, expiresOn time.Time) error {
+	skus := make([]string, 0, len(quantities))
+	for sku := range quantities {
+		skus = append(skus, sku)
+	}
+	sort.Strings(skus)
+
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		for _, sku := range skus {
+			qty := quantities[sku]
+			reservation := &erp.Reservation{
+				ID:         id,
+				OwnerID:    ownerID,
+				Quantity:   qty,
+				ExpiresOn:  expiresOn,
+				CreatedOn:  time.Now(),
+				ModifiedOn: time.Now(),
+			}
+			available := bson.D{{"$subtract", bson.A{"$quantity", bson.D{{"$ifNull", bson.A{"$reserved", 0}}}}}}
+			filter := bson.D{
+				{"_id", sku},
+				{"reservations._id", bson.D{{"$ne", id}}},
+				{"$expr", bson.D{{"$gte", bson.A{available, qty}}}},
+			}
+			update := bson.D{
+				{
+					"$inc",
+					bson.D{
+						{"reserved", qty},
+					},
+				},
+				{
+					"$push",
+					bson.D{
+						{"reservations", reservation},
+					},
+				},
+				{
+					"$set",
+					bson.D{
+						{"modifiedon", time.Now()},
+					},
+				},
+			}
+			result, err := s.db.Collection("inventory").UpdateOne(sc, filter, update)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return erp.ErrInsufficientStock
+			}
+		}
+		return nil
+	})
+}
+
+// CommitReservation takes the reserved quantities out of the stock.
+func (s *Storage) CommitReservation(ctx context.Context, id string) error {
+	return s.settleReservation(ctx, id, true)
+}
+
+// ReleaseReservation returns the reserved quantities to the available stock.
+func (s *Storage) ReleaseReservation(ctx context.Context, id string) error {
+	return s.settleReservation(ctx, id, false)
+}
+
+func (s *Storage) settleReservation(ctx context.Context, id string, commit bool) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		cur, err := s.db.Collection("inventory").Find(sc, bson.D{{"reservations._id", id}})
+		if err != nil {
+			return err
+		}
+		var inventories []*erp.Inventory
+		if err := cur.All(sc, &inventories); err != nil {
+			return err
+		}
+		if len(inventories) == 0 {
+			return erp.ErrNotFoundInStorage
+		}
+
+		for _, inventory := range inventories {
+			for _, reservation := range inventory.Reservations {
+				if reservation.ID != id {
+					continue
+				}
+				err := s.removeReservation(sc, inventory.ID, reservation, commit)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ReleaseExpiredReservations releases reservations that expired before now
+// and returns how many of them were released.
+func (s *Storage) ReleaseExpiredReservations(ctx context.Context, now time.Time) (int, error) {
+	cur, err := s.db.Collection("inventory").Find(ctx, bson.D{{"reservations.expires_on", bson.D{{"$lt", now}}}})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var released int
+	for cur.Next(ctx) {
+		inventory := &erp.Inventory{}
+		if err := cur.Decode(inventory); err != nil {
+			return released, err
+		}
+		for _, reservation := range inventory.Reservations {
+			if !reservation.ExpiresOn.Before(now) {
+				continue
+			}
+			err := s.removeReservation(ctx, inventory.ID, reservation, false)
+			if err == erp.ErrNotFoundInStorage {
+				// Committed or released concurrently.
+				continue
+			}
+			if err != nil {
+				return released, err
+			}
+			released++
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return released, err
+	}
+	return released, nil
+}
+
+// removeReservation pulls the reservation from the inventory of the SKU
+// and takes its quantity out of the stock if commit is set.
+func (s *Storage) removeReservation(ctx context.Context, sku string, reservation *erp.Reservation, commit bool) error {
+	inc := bson.D{{"reserved", -reservation.Quantity}}
+	if commit {
+		inc = append(inc, bson.E{"quantity", -reservation.Quantity})
+	}
+	filter := bson.D{{"_id", sku}, {"reservations._id", reservation.ID}}
 	update := bson.D{
 		{
-			"$push",
+			"$inc",
+			inc,
+		},
+		{
+			"$pull",
 			bson.D{
-				{"products", category},
+				{"reservations", bson.D{{"_id", reservation.ID}}},
+			},
+		},
+		{
+			"$set",
+			bson.D{
+				{"modifiedon", time.Now()},
 			},
 		},
 	}
-	_, err := s.db.Collection("reservation").UpdateOne(ctx, filter, update, nil)
+	result, err := s.db.Collection("inventory").UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return erp.ErrNotFoundInStorage
+	}
 	return nil
 }
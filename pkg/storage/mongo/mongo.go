@@ -16,13 +16,15 @@ type Config struct {
 }
 
 type Storage struct {
+	client *mongo.Client
 	db     *mongo.Database
 	logger log.Logger
 	mu     sync.Mutex
 }
 
 func New(cfg Config) (*Storage, error) {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
 	if err != nil {
 		return nil, err
@@ -32,9 +34,36 @@ func New(cfg Config) (*Storage, error) {
 		return nil, err
 	}
 	m := &Storage{
+		client: client,
 		db:     client.Database("lapkins"),
 		logger: cfg.Logger,
 	}
 	level.Info(cfg.Logger).Log("msg", "mongo was up")
 	return m, nil
 }
+
+// withTransaction runs fn inside a multi-document transaction.
+// The transaction is retried by the driver on transient errors.
+func (s *Storage) withTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := s.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// isDuplicateKeyError reports whether the write failed on a unique index.
+func isDuplicateKeyError(err error) bool {
+	if e, ok := err.(mongo.WriteException); ok {
+		for _, we := range e.WriteErrors {
+			if we.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
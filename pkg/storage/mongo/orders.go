@@ -3,13 +3,204 @@ package mongo
 import (
 	"context"
 
-	"github.com/anabiozz/core/lapkins/pkg/model"
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func (s *Storage) AddOrder(ctx context.Context, order *model.Order) error {
+func (s *Storage) AddOrder(ctx context.Context, order *erp.Order) error {
 	_, err := s.db.Collection("orders").InsertOne(ctx, order, nil)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// CheckoutCart completes the active cart of the user and stores the order in one transaction.
+func (s *Storage) CheckoutCart(ctx context.Context, userID string, order *erp.Order) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		cart := &erp.Cart{}
+		err := s.db.Collection("cart").FindOne(sc, bson.D{{"_id", objID}, {"status", erp.CartStatusActive}}).Decode(cart)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return erp.ErrNotFoundInStorage
+			}
+			return err
+		}
+
+		// Cart was changed after the order had been built from it.
+		if !sameProducts(cart.Products, order.Products) {
+			return erp.ErrModifiedInStorage
+		}
+
+		if cart.Coupon != couponCode(order) {
+			return erp.ErrModifiedInStorage
+		}
+		if order.Coupon != nil {
+			err = s.redeemCoupon(sc, order)
+			if err != nil {
+				return err
+			}
+		}
+
+		cart.OrderID = order.ID
+		err = s.archiveCart(sc, cart, erp.CartStatusCompleted)
+		if err != nil {
+			return err
+		}
+
+		order.CartID = cart.ID.Hex()
+		_, err = s.db.Collection("orders").InsertOne(sc, order)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func sameProducts(a []*erp.CartProduct, b []*erp.CartProduct) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	quantities := make(map[string]int, len(a))
+	for _, p := range a {
+		quantities[p.SKU] += p.Quantity
+	}
+	for _, p := range b {
+		quantities[p.SKU] -= p.Quantity
+	}
+	for _, qty := range quantities {
+		if qty != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Storage) GetOrder(ctx context.Context, id string) (*erp.Order, error) {
+	order := &erp.Order{}
+	err := s.db.Collection("orders").FindOne(ctx, bson.D{{"_id", id}}).Decode(order)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrNotFoundInStorage
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// orderSorts are the sort fields of the order list, totals are sorted by their minor units.
+var orderSorts = map[string]string{
+	"id":          "_id",
+	"created_on":  "created_on",
+	"total_price": "total_price.amount",
+}
+
+// GetOrders returns a page of orders in the given status, of all orders if status is empty.
+func (s *Storage) GetOrders(ctx context.Context, status erp.OrderStatus, req *erp.PageRequest) (*erp.OrderPage, error) {
+	filter := bson.D{}
+	if status != "" {
+		filter = bson.D{{"status", status}}
+	}
+	return s.findOrders(ctx, filter, req)
+}
+
+// GetUserOrders returns a page of the orders of the user in the given status, of all of them if status is empty.
+func (s *Storage) GetUserOrders(ctx context.Context, userID string, status erp.OrderStatus, req *erp.PageRequest) (*erp.OrderPage, error) {
+	filter := bson.D{{"user_id", userID}}
+	if status != "" {
+		filter = append(filter, bson.E{"status", status})
+	}
+	return s.findOrders(ctx, filter, req)
+}
+
+// MergeOrders hands the orders the anonymous user placed without an account over to the user.
+// Orders that belong to an account are never moved.
+func (s *Storage) MergeOrders(ctx context.Context, tmpUserID string, userID string) error {
+	filter := bson.D{{"user_id", tmpUserID}, {"anonymous", true}}
+	update := bson.D{
+		{"$set", bson.D{{"user_id", userID}}},
+		{"$unset", bson.D{{"anonymous", ""}}},
+	}
+	_, err := s.db.Collection("orders").UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (s *Storage) findOrders(ctx context.Context, filter bson.D, req *erp.PageRequest) (*erp.OrderPage, error) {
+	result := &erp.OrderPage{}
+	page, err := s.findPage(ctx, "orders", filter, req, orderSorts, func(cur *mongo.Cursor) error {
+		order := &erp.Order{}
+		if err := cur.Decode(order); err != nil {
+			return err
+		}
+		result.Orders = append(result.Orders, order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Page = *page
+	return result, nil
+}
+
+// UpdateOrderStatus applies the status change only if the order is still in change.From,
+// so two concurrent transitions can't both succeed.
+// Cancelled and refunded orders give their coupon redemption back in the same transaction.
+func (s *Storage) UpdateOrderStatus(ctx context.Context, id string, change *erp.OrderStatusChange) error {
+	filter := bson.D{{"_id", id}, {"status", change.From}}
+	update := bson.D{
+		{
+			"$set",
+			bson.D{
+				{"status", change.To},
+				{"modified_on", change.At},
+			},
+		},
+		{
+			"$push",
+			bson.D{
+				{"history", change},
+			},
+		},
+	}
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := s.db.Collection("orders").UpdateOne(sc, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return erp.ErrModifiedInStorage
+		}
+		if change.To == erp.OrderStatusCancelled || change.To == erp.OrderStatusRefunded {
+			return s.releaseCoupon(sc, id)
+		}
+		return nil
+	})
+}
+
+func (s *Storage) UpdateOrderPayment(ctx context.Context, id string, payment *erp.Payment) error {
+	filter := bson.D{{"_id", id}}
+	update := bson.D{
+		{
+			"$set",
+			bson.D{
+				{"payment", payment},
+				{"modified_on", payment.ModifiedOn},
+			},
+		},
+	}
+	result, err := s.db.Collection("orders").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return erp.ErrNotFoundInStorage
+	}
+	return nil
+}
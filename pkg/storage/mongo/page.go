@@ -0,0 +1,141 @@
+package mongo
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pageCursor is the position after the last document of a page.
+// It is kept as BSON so the sort values keep their types.
+type pageCursor struct {
+	Sort  string        `bson:"s"`
+	Desc  bool          `bson:"d"`
+	Value bson.RawValue `bson:"v"`
+	ID    bson.RawValue `bson:"id"`
+}
+
+// findPage finds a page of documents matching the filter in keyset order,
+// i.e. by the sort field and then by _id, so pages do not shift when documents are added.
+// sorts maps the sort names of erp.PageRequest to document fields.
+// decode is called for every document of the page.
+func (s *Storage) findPage(ctx context.Context, collection string, filter bson.D, req *erp.PageRequest,
+	sorts map[string]string, decode func(cur *mongo.Cursor) error) (*erp.Page, error) {
+
+	field := "_id"
+	if req.Sort != "" {
+		var ok bool
+		if field, ok = sorts[req.Sort]; !ok {
+			return nil, erp.ErrInvalidCursor
+		}
+	}
+	order := 1
+	cmp := "$gt"
+	if req.Desc {
+		order = -1
+		cmp = "$lt"
+	}
+
+	page := &erp.Page{}
+	if req.Cursor == "" {
+		total, err := s.db.Collection(collection).CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	} else {
+		after, err := decodePageCursor(req.Cursor)
+		if err != nil || after.Sort != req.Sort || after.Desc != req.Desc {
+			return nil, erp.ErrInvalidCursor
+		}
+		var keyset bson.D
+		switch {
+		case field == "_id":
+			keyset = bson.D{{"_id", bson.D{{cmp, after.ID}}}}
+		case after.Value.Type == bsontype.Null:
+			// Documents without the field come first in ascending order
+			// and are not matched by comparisons with other values.
+			same := bson.D{{field, nil}, {"_id", bson.D{{cmp, after.ID}}}}
+			if req.Desc {
+				keyset = same
+			} else {
+				keyset = bson.D{{"$or", bson.A{same, bson.D{{field, bson.D{{"$ne", nil}}}}}}}
+			}
+		default:
+			or := bson.A{
+				bson.D{{field, bson.D{{cmp, after.Value}}}},
+				bson.D{{field, after.Value}, {"_id", bson.D{{cmp, after.ID}}}},
+			}
+			if req.Desc {
+				or = append(or, bson.D{{field, nil}})
+			}
+			keyset = bson.D{{"$or", or}}
+		}
+		filter = bson.D{{"$and", bson.A{filter, keyset}}}
+	}
+
+	sort := bson.D{{"_id", order}}
+	if field != "_id" {
+		sort = bson.D{{field, order}, {"_id", order}}
+	}
+	// One more document tells whether there is a next page.
+	opts := options.Find().SetSort(sort).SetLimit(int64(req.Limit) + 1)
+	cur, err := s.db.Collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var last bson.Raw
+	for n := 0; cur.Next(ctx); n++ {
+		if n == req.Limit {
+			next := &pageCursor{
+				Sort:  req.Sort,
+				Desc:  req.Desc,
+				Value: last.Lookup(strings.Split(field, ".")...),
+				ID:    last.Lookup("_id"),
+			}
+			if next.Value.Type == 0 {
+				next.Value = bson.RawValue{Type: bsontype.Null}
+			}
+			if page.NextCursor, err = encodePageCursor(next); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err := decode(cur); err != nil {
+			return nil, err
+		}
+		last = append(last[:0], cur.Current...)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func encodePageCursor(c *pageCursor) (string, error) {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageCursor(s string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	c := &pageCursor{}
+	if err := bson.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
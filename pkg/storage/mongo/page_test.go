@@ -0,0 +1,71 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func rawValue(t *testing.T, v interface{}) bson.RawValue {
+	t.Helper()
+	typ, data, err := bson.MarshalValue(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bson.RawValue{Type: typ, Value: data}
+}
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name   string
+		cursor *pageCursor
+	}{
+		{"string value", &pageCursor{Sort: "name", Value: rawValue(t, "Постер"), ID: rawValue(t, "poster-1")}},
+		{"int value descending", &pageCursor{Sort: "total_price", Desc: true, Value: rawValue(t, int64(6999)), ID: rawValue(t, id)}},
+		{"time value", &pageCursor{Sort: "created_on", Value: rawValue(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)), ID: rawValue(t, id)}},
+		{"missing value", &pageCursor{Sort: "price", Value: bson.RawValue{Type: bsontype.Null}, ID: rawValue(t, "1")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := encodePageCursor(tt.cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodePageCursor(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Sort != tt.cursor.Sort || got.Desc != tt.cursor.Desc {
+				t.Errorf("got sort %s desc %v, want %s desc %v", got.Sort, got.Desc, tt.cursor.Sort, tt.cursor.Desc)
+			}
+			if !got.Value.Equal(tt.cursor.Value) {
+				t.Errorf("got value %v, want %v", got.Value, tt.cursor.Value)
+			}
+			if !got.ID.Equal(tt.cursor.ID) {
+				t.Errorf("got id %v, want %v", got.ID, tt.cursor.ID)
+			}
+		})
+	}
+}
+
+func TestDecodePageCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"not BSON", "bm90IGJzb24"},
+		{"padded base64", "AAAA=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := decodePageCursor(tt.cursor); err == nil {
+				t.Errorf("got %+v, want an error", c)
+			}
+		})
+	}
+}
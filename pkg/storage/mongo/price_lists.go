@@ -0,0 +1,205 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// priceListPrice is the price of a SKU in a price list, see deploy/mongo/price_list_prices.json.
+type priceListPrice struct {
+	ID        string       `bson:"_id"`
+	PriceList string       `bson:"price_list"`
+	SKU       string       `bson:"sku"`
+	Pricing   *erp.Pricing `bson:"pricing"`
+}
+
+func priceListPriceID(id string, sku string) string {
+	return id + "/" + sku
+}
+
+// GetPriceLists returns all the price lists ordered by id.
+func (s *Storage) GetPriceLists(ctx context.Context) ([]*erp.PriceList, error) {
+	opts := options.Find().SetSort(bson.D{{"_id", 1}})
+	cur, err := s.db.Collection("price_lists").Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	lists := []*erp.PriceList{}
+	for cur.Next(ctx) {
+		list := &erp.PriceList{}
+		if err := cur.Decode(list); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// GetPriceList returns the price list with the given id.
+func (s *Storage) GetPriceList(ctx context.Context, id string) (*erp.PriceList, error) {
+	list := &erp.PriceList{}
+	err := s.db.Collection("price_lists").FindOne(ctx, bson.D{{"_id", id}}).Decode(list)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrPriceListNotFound
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreatePriceList adds a price list without prices, its SKUs are priced at the exchange rate.
+func (s *Storage) CreatePriceList(ctx context.Context, list *erp.PriceList) error {
+	_, err := s.db.Collection("price_lists").InsertOne(ctx, list)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return erp.ErrDuplicateKeyInStorage
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdatePriceList writes the name and the exchange rate of the price list.
+func (s *Storage) UpdatePriceList(ctx context.Context, list *erp.PriceList) error {
+	update := bson.D{{"$set", bson.D{
+		{"name", list.Name},
+		{"exchange_rate", list.ExchangeRate},
+		{"rate_updated_on", list.RateUpdatedOn},
+		{"modified_on", list.ModifiedOn},
+	}}}
+	result, err := s.db.Collection("price_lists").UpdateOne(ctx, bson.D{{"_id", list.ID}}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return erp.ErrPriceListNotFound
+	}
+	return nil
+}
+
+// DeletePriceList deletes the price list with its prices.
+func (s *Storage) DeletePriceList(ctx context.Context, id string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		result, err := s.db.Collection("price_lists").DeleteOne(sc, bson.D{{"_id", id}})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return erp.ErrPriceListNotFound
+		}
+		_, err = s.db.Collection("price_list_prices").DeleteMany(sc, bson.D{{"price_list", id}})
+		return err
+	})
+}
+
+// GetPriceListPrices returns the prices the price list has for the given SKUs keyed by SKU,
+// all of its prices when no SKUs are given.
+func (s *Storage) GetPriceListPrices(ctx context.Context, id string, skus []string) (map[string]*erp.Pricing, error) {
+	filter := bson.D{{"price_list", id}}
+	if skus != nil {
+		filter = append(filter, bson.E{Key: "sku", Value: bson.D{{"$in", skus}}})
+	}
+	cur, err := s.db.Collection("price_list_prices").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	prices := make(map[string]*erp.Pricing, len(skus))
+	for cur.Next(ctx) {
+		price := &priceListPrice{}
+		if err := cur.Decode(price); err != nil {
+			return nil, err
+		}
+		if price.Pricing != nil {
+			prices[price.SKU] = price.Pricing
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// SetPriceListPrices sets the prices of the SKUs in the price list, other prices are kept.
+func (s *Storage) SetPriceListPrices(ctx context.Context, id string, prices map[string]*erp.Pricing) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := s.GetPriceList(sc, id); err != nil {
+			return err
+		}
+		for sku, pricing := range prices {
+			price := &priceListPrice{
+				ID:        priceListPriceID(id, sku),
+				PriceList: id,
+				SKU:       sku,
+				Pricing:   pricing,
+			}
+			opts := options.Replace().SetUpsert(true)
+			_, err := s.db.Collection("price_list_prices").ReplaceOne(sc, bson.D{{"_id", price.ID}}, price, opts)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemovePriceListPrice removes the price of the SKU from the price list,
+// the SKU is priced at the exchange rate again.
+func (s *Storage) RemovePriceListPrice(ctx context.Context, id string, sku string) error {
+	result, err := s.db.Collection("price_list_prices").DeleteOne(ctx, bson.D{{"_id", priceListPriceID(id, sku)}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return erp.ErrNotFoundInStorage
+	}
+	return nil
+}
+
+// GetProductPrices returns the base pricing of the variations of the given products
+// keyed by product id and SKU. Variations without pricing are left out.
+func (s *Storage) GetProductPrices(ctx context.Context, productIDs []string) (map[string]map[string]*erp.Pricing, error) {
+	filter := bson.D{{"productId", bson.D{{"$in", productIDs}}}}
+	opts := options.Find().SetProjection(bson.D{{"productId", 1}, {"pricing", 1}})
+	cur, err := s.db.Collection("variations").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	prices := make(map[string]map[string]*erp.Pricing, len(productIDs))
+	for cur.Next(ctx) {
+		variation := &variationDocument{}
+		if err := cur.Decode(variation); err != nil {
+			return nil, err
+		}
+		if variation.Pricing == nil {
+			continue
+		}
+		if prices[variation.ProductID] == nil {
+			prices[variation.ProductID] = map[string]*erp.Pricing{}
+		}
+		prices[variation.ProductID][variation.ID] = variation.Pricing
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// deleteSKUPrices removes the prices of the SKUs from all the price lists.
+func (s *Storage) deleteSKUPrices(ctx context.Context, skus []string) error {
+	_, err := s.db.Collection("price_list_prices").DeleteMany(ctx, bson.D{{"sku", bson.D{{"$in", skus}}}})
+	return err
+}
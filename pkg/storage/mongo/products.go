@@ -3,142 +3,418 @@ package mongo
 import (
 	"context"
 	"strconv"
+	"time"
 
-	"github.com/anabiozz/core/lapkins/pkg/model"
+	"github.com/anabiozz/core/lapkins/pkg/erp"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func (s *Storage) GetProducts(ctx context.Context) ([]*model.Product, error) {
-	var products []*model.Product
-	productsCur, err := s.db.Collection("products").Find(ctx, bson.M{}, nil)
+// productSorts are the sort fields of the product list.
+var productSorts = map[string]string{
+	"id":   "_id",
+	"name": "name",
+}
+
+// GetProducts returns a page of products.
+func (s *Storage) GetProducts(ctx context.Context, req *erp.PageRequest) (*erp.ProductPage, error) {
+	result := &erp.ProductPage{}
+	page, err := s.findPage(ctx, "products", bson.D{}, req, productSorts, func(cur *mongo.Cursor) error {
+		product := &erp.Product{}
+		if err := cur.Decode(product); err != nil {
+			return err
+		}
+		result.Products = append(result.Products, product)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer productsCur.Close(ctx)
+	result.Page = *page
+	return result, nil
+}
 
-	for productsCur.Next(ctx) {
-		product := &model.Product{}
-		err := productsCur.Decode(&product)
+// productDocument is a product as stored, see deploy/mongo/products.json.
+type productDocument struct {
+	ID              string                  `bson:"_id"`
+	Name            string                  `bson:"name"`
+	Description     []*erp.LangValue        `bson:"desc"`
+	Brand           *erp.Brand              `bson:"brand,omitempty"`
+	Categories      []string                `bson:"categories"`
+	PrimaryCategory string                  `bson:"primary_category"`
+	Attrs           []*erp.ProductAttr      `bson:"attrs"`
+	Attributes      []*erp.ProductAttribute `bson:"attributes"`
+	Facets          []string                `bson:"facets"`
+}
+
+// CreateProduct adds the product with its variations.
+// The dictionary counts of the attribute values are updated along.
+func (s *Storage) CreateProduct(ctx context.Context, product *erp.ProductInput) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		for _, id := range product.Categories {
+			if _, err := s.getCategory(sc, id); err != nil {
+				if err == erp.ErrNotFoundInStorage {
+					return erp.ErrCategoryNotFound
+				}
+				return err
+			}
+		}
+		category, err := s.variationCategory(sc, product.PrimaryCategory)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		products = append(products, product)
-	}
-	if err := productsCur.Err(); err != nil {
-		return nil, err
-	}
-	return products, nil
+
+		attributes := product.Attributes
+		if attributes == nil {
+			attributes = []*erp.ProductAttribute{}
+		}
+		doc := &productDocument{
+			ID:              product.ID,
+			Name:            product.Name,
+			Description:     product.Description,
+			Brand:           product.Brand,
+			Categories:      product.Categories,
+			PrimaryCategory: product.PrimaryCategory,
+			Attributes:      attributes,
+			Facets:          erp.AttributeIDs(attributes),
+		}
+		if doc.Categories == nil {
+			doc.Categories = []string{}
+		}
+		for _, v := range product.Variations {
+			doc.Attrs = addVariationTypes(doc.Attrs, v.Attributes)
+		}
+		if _, err := s.db.Collection("products").InsertOne(sc, doc); err != nil {
+			if isDuplicateKeyError(err) {
+				return erp.ErrDuplicateKeyInStorage
+			}
+			return err
+		}
+		for _, a := range attributes {
+			if err := s.countAttribute(sc, a.Name, a.Value, 1); err != nil {
+				return err
+			}
+		}
+
+		for _, v := range product.Variations {
+			if err := s.insertVariation(sc, product.ID, category, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (s *Storage) UpdateProduct(ctx context.Context, product *model.Product) error {
-	filter := bson.M{"id": bson.M{"$eq": product.ID}}
-	update := bson.M{
-		"$set": product,
+// UpdateProduct changes the product fields given in the patch.
+func (s *Storage) UpdateProduct(ctx context.Context, id string, patch *erp.ProductPatch) error {
+	set := bson.D{}
+	if patch.Name != nil {
+		set = append(set, bson.E{Key: "name", Value: *patch.Name})
 	}
-	_, err := s.db.Collection("products").UpdateOne(ctx, filter, update)
+	if patch.Description != nil {
+		set = append(set, bson.E{Key: "desc", Value: patch.Description})
+	}
+	if patch.Brand != nil {
+		set = append(set, bson.E{Key: "brand", Value: patch.Brand})
+	}
+	if len(set) == 0 {
+		count, err := s.db.Collection("products").CountDocuments(ctx, bson.D{{"_id", id}})
+		if err == nil && count == 0 {
+			err = erp.ErrProductNotFound
+		}
+		return err
+	}
+
+	result, err := s.db.Collection("products").UpdateOne(ctx, bson.D{{"_id", id}}, bson.D{{"$set", set}})
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return erp.ErrProductNotFound
+	}
 	return nil
 }
 
+// DeleteProduct deletes the product with its variations, their price list prices and its configurator.
+// The dictionary counts of the attribute values are updated along.
+func (s *Storage) DeleteProduct(ctx context.Context, id string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		attributes, err := s.productAttributes(sc, id)
+		if err != nil {
+			return err
+		}
+		variations, err := s.productVariations(sc, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.db.Collection("variations").DeleteMany(sc, bson.D{{"productId", id}}); err != nil {
+			return err
+		}
+		if _, err := s.db.Collection("products").DeleteOne(sc, bson.D{{"_id", id}}); err != nil {
+			return err
+		}
+		if _, err := s.db.Collection("configurators").DeleteOne(sc, bson.D{{"_id", id}}); err != nil {
+			return err
+		}
+		for _, a := range attributes {
+			if err := s.countAttribute(sc, a.Name, a.Value, -1); err != nil {
+				return err
+			}
+		}
+		skus := make([]string, 0, len(variations))
+		for _, v := range variations {
+			if err := s.countVariationAttributes(sc, v.Attributes, -1); err != nil {
+				return err
+			}
+			skus = append(skus, v.ID)
+		}
+		return s.deleteSKUPrices(sc, skus)
+	})
+}
+
 // GetVariation ..
-func (s *Storage) GetProduct(ctx context.Context, sku string) (*model.Product, error) {
-	var products []*model.Product
-	resultProduct := &model.Product{}
+func (s *Storage) GetProduct(ctx context.Context, sku string) (*erp.Product, error) {
+	skuInt, err := strconv.Atoi(sku)
+	if err != nil {
+		return nil, erp.ErrNotFoundInStorage
+	}
 
-	productsCur, err := s.db.Collection("products").Find(ctx, bson.M{}, nil)
+	product := &erp.Product{}
+	err = s.db.Collection("products").FindOne(ctx, bson.D{{"variations.sku", skuInt}}).Decode(product)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrNotFoundInStorage
+		}
 		return nil, err
 	}
-	defer productsCur.Close(ctx)
+	for _, variation := range product.Variations {
+		if variation.SKU == skuInt {
+			product.Variation = variation
+		}
+	}
+	return product, nil
+}
 
-	for productsCur.Next(ctx) {
-		product := &model.Product{}
-		err := productsCur.Decode(&product)
-		if err != nil {
+// GetPrices returns the current pricing of the given SKUs keyed by SKU.
+// SKUs without pricing are left out.
+func (s *Storage) GetPrices(ctx context.Context, skus []string) (map[string]*erp.Pricing, error) {
+	filter := bson.D{{"_id", bson.D{{"$in", skus}}}}
+	opts := options.Find().SetProjection(bson.D{{"pricing", 1}})
+	cur, err := s.db.Collection("variations").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	prices := make(map[string]*erp.Pricing, len(skus))
+	for cur.Next(ctx) {
+		variation := struct {
+			SKU     string       `bson:"_id"`
+			Pricing *erp.Pricing `bson:"pricing"`
+		}{}
+		if err := cur.Decode(&variation); err != nil {
 			return nil, err
 		}
-		products = append(products, product)
+		if variation.Pricing != nil {
+			prices[variation.SKU] = variation.Pricing
+		}
 	}
-	if err := productsCur.Err(); err != nil {
+	if err := cur.Err(); err != nil {
 		return nil, err
 	}
+	return prices, nil
+}
 
-	skuInt, err := strconv.Atoi(sku)
+// GetVariationCategories returns the ids of the categories the products of the given SKUs
+// are listed in, with the ancestors of those categories, keyed by SKU.
+func (s *Storage) GetVariationCategories(ctx context.Context, skus []string) (map[string][]string, error) {
+	filter := bson.D{{"_id", bson.D{{"$in", skus}}}}
+	opts := options.Find().SetProjection(bson.D{{"productId", 1}})
+	cur, err := s.db.Collection("variations").Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
+	defer cur.Close(ctx)
 
-	for _, product := range products {
-		for _, variation := range product.Variations {
-			if variation.SKU == skuInt {
-				resultProduct.ID = product.ID
-				resultProduct.Name = product.Name
-				resultProduct.Category = product.Category
-				resultProduct.Description = product.Description
-				resultProduct.Attributes = product.Attributes
-				resultProduct.Variation = variation
-				resultProduct.Variations = product.Variations
-			}
+	productIDs := make(map[string]string, len(skus))
+	var ids []string
+	for cur.Next(ctx) {
+		variation := struct {
+			SKU       string `bson:"_id"`
+			ProductID string `bson:"productId"`
+		}{}
+		if err := cur.Decode(&variation); err != nil {
+			return nil, err
 		}
+		productIDs[variation.SKU] = variation.ProductID
+		ids = append(ids, variation.ProductID)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
 	}
 
-	return resultProduct, nil
+	listed, err := s.productCategories(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	categories := make(map[string][]string, len(skus))
+	for sku, productID := range productIDs {
+		categories[sku] = listed[productID]
+	}
+	return categories, nil
 }
 
-func (s *Storage) AddAttribute(ctx context.Context, sku string, attribute *model.NameValue) error {
-	filter := bson.D{{"status", "active"}, {"products.sku", sku}}
-	update := bson.D{
-		{
-			"$push",
-			bson.D{
-				{"products", attribute},
-			},
-		},
-	}
-	_, err := s.db.Collection("cart").UpdateOne(ctx, filter, update, nil)
+// productCategories returns the ids of the categories the products are listed in
+// together with their ancestors, keyed by product id.
+func (s *Storage) productCategories(ctx context.Context, productIDs []string) (map[string][]string, error) {
+	filter := bson.D{{"_id", bson.D{{"$in", productIDs}}}}
+	opts := options.Find().SetProjection(bson.D{{"categories", 1}})
+	cur, err := s.db.Collection("products").Find(ctx, filter, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
-}
+	defer cur.Close(ctx)
 
-func (s *Storage) RemoveAttribute(ctx context.Context, sku string, attribute string) error {
-	filter := bson.D{{"status", "active"}, {"products.sku", sku}}
-	update := bson.D{
-		{
-			"$pull",
-			bson.D{
-				{"products", attribute},
-			},
-		},
-	}
-	_, err := s.db.Collection("cart").UpdateOne(ctx, filter, update, nil)
+	listed := make(map[string][]string, len(productIDs))
+	var categoryIDs []string
+	for cur.Next(ctx) {
+		product := struct {
+			ID         string   `bson:"_id"`
+			Categories []string `bson:"categories"`
+		}{}
+		if err := cur.Decode(&product); err != nil {
+			return nil, err
+		}
+		listed[product.ID] = product.Categories
+		categoryIDs = append(categoryIDs, product.Categories...)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	if len(categoryIDs) == 0 {
+		return listed, nil
+	}
+
+	opts = options.Find().SetProjection(bson.D{{"ancestors", 1}})
+	cur, err = s.db.Collection("categories").Find(ctx, bson.D{{"_id", bson.D{{"$in", categoryIDs}}}}, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	defer cur.Close(ctx)
+
+	ancestors := make(map[string][]string, len(categoryIDs))
+	for cur.Next(ctx) {
+		category := &erp.Category{}
+		if err := cur.Decode(category); err != nil {
+			return nil, err
+		}
+		ancestors[category.ID] = category.Ancestors
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	for productID, ids := range listed {
+		var subtree []string
+		for _, id := range ids {
+			subtree = append(subtree, id)
+			subtree = append(subtree, ancestors[id]...)
+		}
+		listed[productID] = subtree
+	}
+	return listed, nil
+}
+
+// searchProduct and searchVariation are the parts of catalog documents used by the search.
+type searchProduct struct {
+	ID          string `bson:"_id"`
+	Name        string `bson:"name"`
+	Description []struct {
+		Value string `bson:"value"`
+	} `bson:"desc"`
+	Attributes []*erp.ProductAttribute `bson:"attributes"`
+	Brand      struct {
+		Name string `bson:"name"`
+	} `bson:"brand"`
 }
 
-//func (s *Storage) AddCategory(ctx context.Context, sku string, category model.Category) error {
-//	filter := bson.D{{"status", "active"}, {"products.sku", sku}}
-//	update := bson.D{
-//		{
-//			"$push",
-//			bson.D{
-//				{"products", category},
-//			},
-//		},
-//	}
-//	_, err := s.db.Collection("cart").UpdateOne(ctx, filter, update, nil)
-//	if err != nil {
-//		return err
-//	}
-//	return nil
-//}
-//
-//func (s *Storage) RemoveCategory(ctx context.Context, sku string) error {
-//	fmt.Println("RemoveCategory")
-//	return nil
-//}
+type searchVariation struct {
+	ProductID  string           `bson:"productId"`
+	Pricing    *erp.Pricing     `bson:"pricing"`
+	Attributes []*erp.NameValue `bson:"attributes"`
+	Assets     struct {
+		Thumbnail struct {
+			Src string `bson:"src"`
+		} `bson:"thumbnail"`
+	} `bson:"assets"`
+}
+
+// GetSearchDocuments returns every product with the texts to search in,
+// the lowest current price and the thumbnail of its variations.
+func (s *Storage) GetSearchDocuments(ctx context.Context) ([]*erp.SearchDocument, error) {
+	cur, err := s.db.Collection("variations").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	variations := map[string][]*searchVariation{}
+	for cur.Next(ctx) {
+		v := &searchVariation{}
+		if err := cur.Decode(v); err != nil {
+			return nil, err
+		}
+		variations[v.ProductID] = append(variations[v.ProductID], v)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	products, err := s.db.Collection("products").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	defer products.Close(ctx)
+
+	now := time.Now()
+	var docs []*erp.SearchDocument
+	for products.Next(ctx) {
+		p := &searchProduct{}
+		if err := products.Decode(p); err != nil {
+			return nil, err
+		}
+		doc := &erp.SearchDocument{
+			ID:   p.ID,
+			Name: p.Name,
+		}
+		for _, d := range p.Description {
+			doc.Description = append(doc.Description, d.Value)
+		}
+		if p.Brand.Name != "" {
+			doc.Attributes = append(doc.Attributes, p.Brand.Name)
+		}
+		for _, a := range p.Attributes {
+			doc.Attributes = append(doc.Attributes, a.Value...)
+		}
+		for _, v := range variations[p.ID] {
+			for _, a := range v.Attributes {
+				doc.Attributes = append(doc.Attributes, a.Value)
+			}
+			if v.Pricing != nil {
+				if price := v.Pricing.Current(now); doc.Price.IsZero() || price.Less(doc.Price) {
+					doc.Price = price
+				}
+			}
+			if doc.Thumbnail == "" {
+				doc.Thumbnail = v.Assets.Thumbnail.Src
+			}
+		}
+		docs = append(docs, doc)
+	}
+	if err := products.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
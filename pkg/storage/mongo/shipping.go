@@ -0,0 +1,123 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetShippingDetails returns the package dimensions and weight of the given SKUs keyed by SKU.
+// Variations without package details are left out.
+func (s *Storage) GetShippingDetails(ctx context.Context, skus []string) (map[string]*erp.Shipping, error) {
+	filter := bson.D{{"_id", bson.D{{"$in", skus}}}}
+	opts := options.Find().SetProjection(bson.D{{"shipping", 1}})
+	cur, err := s.db.Collection("variations").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	details := make(map[string]*erp.Shipping, len(skus))
+	for cur.Next(ctx) {
+		variation := struct {
+			ID       string        `bson:"_id"`
+			Shipping *erp.Shipping `bson:"shipping"`
+		}{}
+		if err := cur.Decode(&variation); err != nil {
+			return nil, err
+		}
+		if variation.Shipping != nil && variation.Shipping.Dimensions != nil && variation.Shipping.Weight != nil {
+			details[variation.ID] = variation.Shipping
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// GetDeliveryZones returns all the delivery zones ordered by priority.
+func (s *Storage) GetDeliveryZones(ctx context.Context) ([]*erp.DeliveryZone, error) {
+	opts := options.Find().SetSort(bson.D{{"priority", 1}, {"_id", 1}})
+	cur, err := s.db.Collection("delivery_zones").Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	zones := []*erp.DeliveryZone{}
+	for cur.Next(ctx) {
+		zone := &erp.DeliveryZone{}
+		if err := cur.Decode(zone); err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// SetDeliveryZone creates or replaces the delivery zone.
+func (s *Storage) SetDeliveryZone(ctx context.Context, zone *erp.DeliveryZone) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.db.Collection("delivery_zones").ReplaceOne(ctx, bson.D{{"_id", zone.ID}}, zone, opts)
+	return err
+}
+
+// DeleteDeliveryZone deletes the delivery zone.
+func (s *Storage) DeleteDeliveryZone(ctx context.Context, id string) error {
+	result, err := s.db.Collection("delivery_zones").DeleteOne(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return erp.ErrDeliveryZoneNotFound
+	}
+	return nil
+}
+
+// GetPickupPoints returns all the pickup points ordered by id.
+func (s *Storage) GetPickupPoints(ctx context.Context) ([]*erp.PickupPoint, error) {
+	opts := options.Find().SetSort(bson.D{{"_id", 1}})
+	cur, err := s.db.Collection("pickup_points").Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	points := []*erp.PickupPoint{}
+	for cur.Next(ctx) {
+		point := &erp.PickupPoint{}
+		if err := cur.Decode(point); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// SetPickupPoint creates or replaces the pickup point.
+func (s *Storage) SetPickupPoint(ctx context.Context, point *erp.PickupPoint) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.db.Collection("pickup_points").ReplaceOne(ctx, bson.D{{"_id", point.ID}}, point, opts)
+	return err
+}
+
+// DeletePickupPoint deletes the pickup point.
+func (s *Storage) DeletePickupPoint(ctx context.Context, id string) error {
+	result, err := s.db.Collection("pickup_points").DeleteOne(ctx, bson.D{{"_id", id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return erp.ErrPickupPointNotFound
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// variationDocument is a variation as stored, see deploy/mongo/variations.json.
+type variationDocument struct {
+	ID        string `bson:"_id"`
+	ProductID string `bson:"productId"`
+	Default   bool   `bson:"default"`
+	// Category is the path of the primary category of the product.
+	Category   string           `bson:"category,omitempty"`
+	Pricing    *erp.Pricing     `bson:"pricing"`
+	Assets     variationAssets  `bson:"assets"`
+	Attributes []*erp.NameValue `bson:"attributes"`
+	Attrs      []string         `bson:"attrs"`
+}
+
+type variationAssets struct {
+	Thumbnail struct {
+		Src string `bson:"src"`
+	} `bson:"thumbnail"`
+}
+
+func (d *variationDocument) input() *erp.VariationInput {
+	variation := &erp.VariationInput{
+		SKU:        d.ID,
+		Default:    d.Default,
+		Thumbnail:  d.Assets.Thumbnail.Src,
+		Attributes: d.Attributes,
+	}
+	if d.Pricing != nil {
+		variation.Price = d.Pricing.Price.String()
+		if sale := d.Pricing.Sale; sale != nil && sale.SalePrice.IsPositive() {
+			variation.SalePrice = sale.SalePrice.String()
+			variation.SaleEndDate = sale.SaleEndDate
+		}
+	}
+	return variation
+}
+
+// FindSKUs returns the given SKUs that are already used by variations.
+func (s *Storage) FindSKUs(ctx context.Context, skus []string) ([]string, error) {
+	filter := bson.D{{"_id", bson.D{{"$in", skus}}}}
+	opts := options.Find().SetProjection(bson.D{{"_id", 1}})
+	cur, err := s.db.Collection("variations").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var found []string
+	for cur.Next(ctx) {
+		variation := &variationDocument{}
+		if err := cur.Decode(variation); err != nil {
+			return nil, err
+		}
+		found = append(found, variation.ID)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// GetProductVariations returns the variations of the product in the form they are created in.
+func (s *Storage) GetProductVariations(ctx context.Context, productID string) ([]*erp.VariationInput, error) {
+	if _, err := s.getProductDocument(ctx, productID); err != nil {
+		return nil, err
+	}
+	docs, err := s.productVariations(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	variations := make([]*erp.VariationInput, 0, len(docs))
+	for _, doc := range docs {
+		variations = append(variations, doc.input())
+	}
+	return variations, nil
+}
+
+// CreateVariation adds a variation to the product.
+// A default variation takes over from the current default one.
+func (s *Storage) CreateVariation(ctx context.Context, productID string, variation *erp.VariationInput) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		product, err := s.getProductDocument(sc, productID)
+		if err != nil {
+			return err
+		}
+		category, err := s.variationCategory(sc, product.PrimaryCategory)
+		if err != nil {
+			return err
+		}
+		if variation.Default {
+			if err := s.unsetDefaultVariation(sc, productID); err != nil {
+				return err
+			}
+		}
+		if err := s.insertVariation(sc, productID, category, variation); err != nil {
+			return err
+		}
+		return s.setVariationTypes(sc, product, variation.Attributes)
+	})
+}
+
+// UpdateVariation writes the fields given in the patch, taking them from the patched variation.
+// A variation made default takes over from the current default one.
+func (s *Storage) UpdateVariation(ctx context.Context, productID string, variation *erp.VariationInput, patch *erp.VariationPatch) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		current := &variationDocument{}
+		filter := bson.D{{"_id", variation.SKU}, {"productId", productID}}
+		if err := s.db.Collection("variations").FindOne(sc, filter).Decode(current); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return erp.ErrVariationNotFound
+			}
+			return err
+		}
+
+		set := bson.D{}
+		if patch.Default != nil {
+			if !variation.Default && current.Default {
+				return erp.ErrNoDefaultVariation
+			}
+			if variation.Default && !current.Default {
+				if err := s.unsetDefaultVariation(sc, productID); err != nil {
+					return err
+				}
+			}
+			set = append(set, bson.E{Key: "default", Value: variation.Default})
+		}
+		if patch.Price != nil || patch.SalePrice != nil || patch.SaleEndDate != nil {
+			set = append(set, bson.E{Key: "pricing", Value: variation.Pricing()})
+		}
+		if patch.Thumbnail != nil {
+			set = append(set, bson.E{Key: "assets.thumbnail.src", Value: variation.Thumbnail})
+		}
+		if patch.Attributes != nil {
+			set = append(set,
+				bson.E{Key: "attributes", Value: variation.Attributes},
+				bson.E{Key: "attrs", Value: variation.Facets()},
+			)
+		}
+		if len(set) == 0 {
+			return nil
+		}
+		if _, err := s.db.Collection("variations").UpdateOne(sc, filter, bson.D{{"$set", set}}); err != nil {
+			return err
+		}
+
+		if patch.Attributes == nil {
+			return nil
+		}
+		if err := s.countVariationAttributes(sc, current.Attributes, -1); err != nil {
+			return err
+		}
+		if err := s.countVariationAttributes(sc, variation.Attributes, 1); err != nil {
+			return err
+		}
+		product, err := s.getProductDocument(sc, productID)
+		if err != nil {
+			return err
+		}
+		return s.setVariationTypes(sc, product, variation.Attributes)
+	})
+}
+
+// DeleteVariation deletes a variation of the product with its price list prices,
+// the default and the last variations are kept.
+func (s *Storage) DeleteVariation(ctx context.Context, productID string, sku string) error {
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		variation := &variationDocument{}
+		filter := bson.D{{"_id", sku}, {"productId", productID}}
+		if err := s.db.Collection("variations").FindOne(sc, filter).Decode(variation); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return erp.ErrVariationNotFound
+			}
+			return err
+		}
+		count, err := s.db.Collection("variations").CountDocuments(sc, bson.D{{"productId", productID}})
+		if err != nil {
+			return err
+		}
+		if count == 1 {
+			return erp.ErrLastVariation
+		}
+		if variation.Default {
+			return erp.ErrVariationIsDefault
+		}
+
+		if _, err := s.db.Collection("variations").DeleteOne(sc, filter); err != nil {
+			return err
+		}
+		if err := s.deleteSKUPrices(sc, []string{sku}); err != nil {
+			return err
+		}
+		return s.countVariationAttributes(sc, variation.Attributes, -1)
+	})
+}
+
+func (s *Storage) getProductDocument(ctx context.Context, productID string) (*productDocument, error) {
+	product := &productDocument{}
+	err := s.db.Collection("products").FindOne(ctx, bson.D{{"_id", productID}}).Decode(product)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrProductNotFound
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+func (s *Storage) productVariations(ctx context.Context, productID string) ([]*variationDocument, error) {
+	opts := options.Find().SetSort(bson.D{{"_id", 1}})
+	cur, err := s.db.Collection("variations").Find(ctx, bson.D{{"productId", productID}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var variations []*variationDocument
+	for cur.Next(ctx) {
+		variation := &variationDocument{}
+		if err := cur.Decode(variation); err != nil {
+			return nil, err
+		}
+		variations = append(variations, variation)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return variations, nil
+}
+
+// insertVariation stores a validated variation and counts its attribute values.
+func (s *Storage) insertVariation(ctx context.Context, productID string, category string, variation *erp.VariationInput) error {
+	doc := &variationDocument{
+		ID:         variation.SKU,
+		ProductID:  productID,
+		Default:    variation.Default,
+		Category:   category,
+		Pricing:    variation.Pricing(),
+		Attributes: variation.Attributes,
+		Attrs:      variation.Facets(),
+	}
+	doc.Assets.Thumbnail.Src = variation.Thumbnail
+	if _, err := s.db.Collection("variations").InsertOne(ctx, doc); err != nil {
+		if isDuplicateKeyError(err) {
+			return erp.ErrDuplicateKeyInStorage
+		}
+		return err
+	}
+	return s.countVariationAttributes(ctx, variation.Attributes, 1)
+}
+
+func (s *Storage) unsetDefaultVariation(ctx context.Context, productID string) error {
+	filter := bson.D{{"productId", productID}, {"default", true}}
+	_, err := s.db.Collection("variations").UpdateMany(ctx, filter, bson.D{{"$set", bson.D{{"default", false}}}})
+	return err
+}
+
+func (s *Storage) countVariationAttributes(ctx context.Context, attributes []*erp.NameValue, delta int) error {
+	for _, a := range attributes {
+		if a == nil {
+			continue
+		}
+		if err := s.countAttribute(ctx, a.Name, []string{a.Value}, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// variationCategory returns the category path variations of a product with the given primary category refer to.
+func (s *Storage) variationCategory(ctx context.Context, primary string) (string, error) {
+	if primary == "" {
+		return "", nil
+	}
+	category, err := s.getCategory(ctx, primary)
+	if err != nil {
+		if err == erp.ErrNotFoundInStorage {
+			return "", erp.ErrCategoryNotFound
+		}
+		return "", err
+	}
+	return category.Path(), nil
+}
+
+// setVariationTypes adds the attributes the product variations differ in to the product.
+func (s *Storage) setVariationTypes(ctx context.Context, product *productDocument, attributes []*erp.NameValue) error {
+	attrs := addVariationTypes(product.Attrs, attributes)
+	if len(attrs) == len(product.Attrs) {
+		return nil
+	}
+	update := bson.D{{"$set", bson.D{{"attrs", attrs}}}}
+	_, err := s.db.Collection("products").UpdateOne(ctx, bson.D{{"_id", product.ID}}, update)
+	return err
+}
+
+// addVariationTypes adds the missing attributes to the variation types of a product,
+// the storefront shows them as radio buttons, see deploy/mongo/products.json.
+func addVariationTypes(attrs []*erp.ProductAttr, attributes []*erp.NameValue) []*erp.ProductAttr {
+	for _, a := range attributes {
+		key := erp.AttributeKey(a.Name)
+		found := false
+		for _, attr := range attrs {
+			if attr.Name == key {
+				found = true
+			}
+		}
+		if !found {
+			attrs = append(attrs, &erp.ProductAttr{Type: "RADIO", Name: key})
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,199 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/anabiozz/core/lapkins/pkg/erp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *Storage) GetWishlist(ctx context.Context, userID string) (*erp.Wishlist, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.findWishlist(ctx, bson.D{{"_id", objID}})
+}
+
+func (s *Storage) GetWishlistByShareToken(ctx context.Context, token string) (*erp.Wishlist, error) {
+	return s.findWishlist(ctx, bson.D{{"share_token", token}})
+}
+
+func (s *Storage) findWishlist(ctx context.Context, filter bson.D) (*erp.Wishlist, error) {
+	wishlist := &erp.Wishlist{}
+	err := s.db.Collection("wishlists").FindOne(ctx, filter).Decode(wishlist)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, erp.ErrNotFoundInStorage
+		}
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// AddToWishlist adds the SKU to the wishlist of the user creating the wishlist if needed.
+// Adding a SKU that is already in the wishlist does nothing.
+func (s *Storage) AddToWishlist(ctx context.Context, userID string, sku string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	product := &erp.WishlistProduct{
+		SKU:     sku,
+		AddedAt: time.Now(),
+	}
+	filter := bson.D{{"_id", objID}, {"products.sku", bson.D{{"$ne", sku}}}}
+	update := bson.D{
+		{
+			"$set",
+			bson.D{
+				{"updated_at", time.Now()},
+			},
+		},
+		{
+			"$setOnInsert",
+			bson.D{
+				{"created_at", time.Now()},
+			},
+		},
+		{
+			"$push",
+			bson.D{
+				{"products", product},
+			},
+		},
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err = s.db.Collection("wishlists").UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		// The upsert collides with the existing wishlist that already has the SKU.
+		if isDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) RemoveFromWishlist(ctx context.Context, userID string, sku string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	filter := bson.D{{"_id", objID}, {"products.sku", sku}}
+	update := bson.D{
+		{
+			"$pull",
+			bson.D{
+				{"products", bson.D{{"sku", sku}}},
+			},
+		},
+		{
+			"$set",
+			bson.D{
+				{"updated_at", time.Now()},
+			},
+		},
+	}
+	result, err := s.db.Collection("wishlists").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return erp.ErrNotFoundInStorage
+	}
+	return nil
+}
+
+// SetWishlistShareToken sets the share token unless the wishlist already has one
+// and returns the token in effect.
+func (s *Storage) SetWishlistShareToken(ctx context.Context, userID string, token string) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", err
+	}
+	filter := bson.D{{"_id", objID}, {"share_token", bson.D{{"$exists", false}}}}
+	update := bson.D{
+		{
+			"$set",
+			bson.D{
+				{"share_token", token},
+				{"updated_at", time.Now()},
+			},
+		},
+	}
+	_, err = s.db.Collection("wishlists").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return "", err
+	}
+	wishlist, err := s.GetWishlist(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return wishlist.ShareToken, nil
+}
+
+// MergeWishlists moves the products of the anonymous wishlist into the wishlist
+// of the user and deletes the anonymous one in the same transaction.
+// Merging an already merged wishlist returns erp.ErrNotFoundInStorage.
+func (s *Storage) MergeWishlists(ctx context.Context, tmpUserID string, userID string) error {
+	tmpID, err := primitive.ObjectIDFromHex(tmpUserID)
+	if err != nil {
+		return err
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	return s.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		tmpWishlist := &erp.Wishlist{}
+		err := s.db.Collection("wishlists").FindOneAndDelete(sc, bson.D{{"_id", tmpID}}).Decode(tmpWishlist)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return erp.ErrNotFoundInStorage
+			}
+			return err
+		}
+
+		wishlist := &erp.Wishlist{}
+		err = s.db.Collection("wishlists").FindOne(sc, bson.D{{"_id", userObjID}}).Decode(wishlist)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				return err
+			}
+			tmpWishlist.ID = userObjID
+			// Links shared before login stop working, they pointed to the anonymous wishlist.
+			tmpWishlist.ShareToken = ""
+			tmpWishlist.UpdatedAt = time.Now()
+			_, err = s.db.Collection("wishlists").InsertOne(sc, tmpWishlist)
+			return err
+		}
+
+		skus := make(map[string]bool, len(wishlist.Products))
+		for _, p := range wishlist.Products {
+			skus[p.SKU] = true
+		}
+		for _, p := range tmpWishlist.Products {
+			if !skus[p.SKU] {
+				wishlist.Products = append(wishlist.Products, p)
+				skus[p.SKU] = true
+			}
+		}
+		update := bson.D{
+			{
+				"$set",
+				bson.D{
+					{"products", wishlist.Products},
+					{"updated_at", time.Now()},
+				},
+			},
+		}
+		_, err = s.db.Collection("wishlists").UpdateOne(sc, bson.D{{"_id", userObjID}}, update)
+		return err
+	})
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Worker runs a background job of a service periodically.
+type Worker struct {
+	name     string
+	interval time.Duration
+	job      func(ctx context.Context) error
+	logger   log.Logger
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates a worker running the job every interval.
+func New(name string, interval time.Duration, logger log.Logger, job func(ctx context.Context) error) *Worker {
+	return &Worker{
+		name:     name,
+		interval: interval,
+		job:      job,
+		logger:   logger,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run runs the job every interval until Stop is called.
+func (w *Worker) Run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), w.interval)
+			if err := w.job(ctx); err != nil {
+				level.Error(w.logger).Log("worker", w.name, "err", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Stop stops the worker and waits for the running job to finish.
+func (w *Worker) Stop() {
+	close(w.stop)
+	<-w.done
+}